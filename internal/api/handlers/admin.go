@@ -228,7 +228,7 @@ func AdminStats(c *gin.Context) {
 		})
 	}()
 	go func() {
-		runQuery(func() error { return db.Model(&models.Node{}).Where("is_active = ?", true).Count(&nodeCount).Error })
+		runQuery(func() error { return db.Model(&models.Node{}).Scopes(services.LiveNodeScope).Count(&nodeCount).Error })
 	}()
 	go func() {
 		runQuery(func() error {
@@ -370,8 +370,15 @@ func AdminGetUser(c *gin.Context) {
 		return
 	}
 
+	subscriptions := services.ListUserSubscriptions(db, uint(id))
 	var subscription models.Subscription
-	db.Where("user_id = ?", id).First(&subscription)
+	subIDs := make([]uint, 0, len(subscriptions))
+	for _, s := range subscriptions {
+		subIDs = append(subIDs, s.ID)
+	}
+	if len(subscriptions) > 0 {
+		subscription = subscriptions[0]
+	}
 
 	var (
 		orders          []models.Order
@@ -387,7 +394,9 @@ func AdminGetUser(c *gin.Context) {
 	go func() { defer wg.Done(); db.Where("user_id = ?", id).Order("created_at DESC").Limit(20).Find(&orders) }()
 	go func() {
 		defer wg.Done()
-		db.Where("subscription_id = ?", subscription.ID).Order("last_access DESC").Limit(50).Find(&devices)
+		if len(subIDs) > 0 {
+			db.Where("subscription_id IN ?", subIDs).Order("last_access DESC").Limit(50).Find(&devices)
+		}
 	}()
 	go func() { defer wg.Done(); db.Where("user_id = ?", id).Order("created_at DESC").Limit(20).Find(&resets) }()
 	go func() {
@@ -406,7 +415,7 @@ func AdminGetUser(c *gin.Context) {
 
 	// Build subscription URLs
 	baseURL := getSubscriptionBaseURL()
-	subURLs := buildSubscriptionURLs(baseURL, subscription.SubscriptionURL)
+	subURLs := buildSubscriptionURLs(baseURL, &subscription, "")
 
 	// Package name
 	var packageName string
@@ -420,6 +429,7 @@ func AdminGetUser(c *gin.Context) {
 	utils.Success(c, gin.H{
 		"user":              user,
 		"subscription":      subscription,
+		"subscriptions":     subscriptions,
 		"subscription_urls": subURLs,
 		"package_name":      packageName,
 		"recent_orders":     orders,
@@ -672,8 +682,12 @@ func AdminUpdateUser(c *gin.Context) {
 
 	// Update subscription fields if provided
 	if len(subscriptionUpdates) > 0 {
-		var subscription models.Subscription
-		if err := db.Where("user_id = ?", user.ID).First(&subscription).Error; err == nil {
+		// subscription_id 指定要修改的订阅，缺省为主订阅
+		var subID uint
+		if v, ok := req["subscription_id"].(float64); ok && v > 0 {
+			subID = uint(v)
+		}
+		if subscription, err := services.FindUserSubscription(db, user.ID, subID); err == nil {
 			// 处理 expire_time 的时间格式转换
 			if expireTimeStr, ok := subscriptionUpdates["expire_time"].(string); ok && expireTimeStr != "" {
 				if expireTime, err := time.Parse(time.RFC3339, expireTimeStr); err == nil {
@@ -697,7 +711,7 @@ func AdminUpdateUser(c *gin.Context) {
 				}
 			}
 
-			if err := db.Model(&subscription).Updates(subscriptionUpdates).Error; err != nil {
+			if err := db.Model(subscription).Updates(subscriptionUpdates).Error; err != nil {
 				utils.InternalError(c, "更新订阅信息失败")
 				return
 			}
@@ -737,6 +751,15 @@ func AdminDeleteUser(c *gin.Context) {
 		if err := tx.Where("user_id = ?", uid).Delete(&models.Subscription{}).Error; err != nil {
 			return err
 		}
+		if err := tx.Where("user_id = ?", uid).Delete(&models.SubscriptionPause{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", uid).Delete(&models.SubscriptionProfile{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", uid).Delete(&models.UserCustomRules{}).Error; err != nil {
+			return err
+		}
 		if err := tx.Where("user_id = ?", uid).Delete(&models.Order{}).Error; err != nil {
 			return err
 		}
@@ -793,13 +816,13 @@ func AdminDeleteUserDevice(c *gin.Context) {
 		utils.NotFound(c, "设备不存在")
 		return
 	}
-	// Verify device belongs to this user's subscription
+	// Verify device belongs to one of this user's subscriptions
 	var sub models.Subscription
-	if err := db.Where("user_id = ?", userID).First(&sub).Error; err != nil {
+	if err := db.First(&sub, device.SubscriptionID).Error; err != nil {
 		utils.NotFound(c, "用户订阅不存在")
 		return
 	}
-	if device.SubscriptionID != sub.ID {
+	if sub.UserID != uint(userID) {
 		utils.Forbidden(c, "设备不属于该用户")
 		return
 	}
@@ -848,25 +871,13 @@ func AdminToggleUserActive(c *gin.Context) {
 	// Sync subscription status
 	if newStatus {
 		// Re-enable: set subscription status based on expire time
-		var sub models.Subscription
-		if db.Where("user_id = ?", id).First(&sub).Error == nil {
-			updates := map[string]interface{}{"is_active": true}
-			if sub.ExpireTime.After(time.Now()) {
-				updates["status"] = "active"
-			} else {
-				updates["status"] = "expired"
-			}
-			if err := db.Model(&sub).Updates(updates).Error; err != nil {
-				utils.InternalError(c, "同步订阅状态失败")
-				return
-			}
+		if err := services.EnableUserSubscriptions(db, []uint{uint(id)}); err != nil {
+			utils.InternalError(c, "同步订阅状态失败")
+			return
 		}
 	} else {
 		// Disable: set subscription to disabled
-		if err := db.Model(&models.Subscription{}).Where("user_id = ?", id).Updates(map[string]interface{}{
-			"is_active": false,
-			"status":    "disabled",
-		}).Error; err != nil {
+		if err := services.DisableUserSubscriptions(db, []uint{uint(id)}); err != nil {
 			utils.InternalError(c, "同步订阅状态失败")
 			return
 		}
@@ -1374,7 +1385,11 @@ func AdminRefundOrder(c *gin.Context) {
 
 	// Cancel/rollback the subscription that was activated by this order
 	var sub models.Subscription
-	if tx.Where("user_id = ?", order.UserID).First(&sub).Error == nil {
+	subQuery := tx.Where("user_id = ?", order.UserID)
+	if order.SubscriptionID != nil {
+		subQuery = subQuery.Where("id = ?", *order.SubscriptionID)
+	}
+	if subQuery.Order("id ASC").First(&sub).Error == nil {
 		shouldCancel := false
 		if order.PackageID == 0 {
 			// Custom package order — always cancel
@@ -1547,7 +1562,7 @@ func AdminUpdatePackage(c *gin.Context) {
 	}
 	allowed := map[string]bool{
 		"name": true, "description": true, "price": true, "duration_days": true,
-		"device_limit": true, "is_active": true, "sort_order": true, "features": true,
+		"device_limit": true, "traffic_gb": true, "speed_limit": true, "traffic_reset_mode": true, "is_active": true, "sort_order": true, "features": true,
 		"original_price": true, "discount_text": true, "badge": true,
 	}
 	updates := make(map[string]interface{})
@@ -1612,7 +1627,7 @@ func AdminListNodes(c *gin.Context) {
 	query.Count(&total)
 
 	var nodes []models.Node
-	query.Select("id, name, region, type, status, load, speed, uptime, latency, description, is_recommended, is_active, is_manual, source_index, order_index, last_test, created_at, updated_at").
+	query.Select("id, name, region, type, status, load, speed, uptime, latency, description, is_recommended, is_active, is_manual, source_index, order_index, rate, last_test, created_at, updated_at").
 		Order(p.OrderClause()).Offset(p.Offset()).Limit(p.PageSize).Find(&nodes)
 
 	utils.SuccessPage(c, nodes, total, p.Page, p.PageSize)
@@ -1627,16 +1642,27 @@ func AdminCreateNode(c *gin.Context) {
 		Description   *string `json:"description"`
 		Config        *string `json:"config"`
 		IsRecommended bool    `json:"is_recommended"`
+		IsServer      bool    `json:"is_server"`
+		GroupID       *uint   `json:"group_id"`
+		Rate          float64 `json:"rate"`
 		OrderIndex    int     `json:"order_index"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
 		utils.BadRequest(c, "参数错误: "+err.Error())
 		return
 	}
+	// 未填写倍率时按 1 倍创建，与更新接口一样只接受正数
+	if req.Rate == 0 {
+		req.Rate = 1
+	}
+	if req.Rate < 0 {
+		utils.BadRequest(c, "流量倍率必须为正数")
+		return
+	}
 	node := models.Node{
 		Name: req.Name, Region: req.Region, Type: req.Type, Status: req.Status,
 		Description: req.Description, Config: req.Config, IsRecommended: req.IsRecommended,
-		OrderIndex: req.OrderIndex, IsManual: true,
+		OrderIndex: req.OrderIndex, IsServer: req.IsServer, GroupID: req.GroupID, Rate: req.Rate, IsManual: true,
 	}
 	if err := database.GetDB().Create(&node).Error; err != nil {
 		utils.InternalError(c, "创建节点失败")
@@ -1667,7 +1693,8 @@ func AdminUpdateNode(c *gin.Context) {
 	allowed := map[string]bool{
 		"name": true, "region": true, "type": true, "status": true, "description": true,
 		"config": true, "is_recommended": true, "is_active": true, "is_manual": true,
-		"order_index": true, "source_index": true,
+		"order_index": true, "source_index": true, "is_server": true, "group_id": true,
+		"rate": true,
 	}
 	updates := make(map[string]interface{})
 	for k, v := range req {
@@ -1679,6 +1706,12 @@ func AdminUpdateNode(c *gin.Context) {
 		utils.BadRequest(c, "无有效更新字段")
 		return
 	}
+	if v, ok := updates["rate"]; ok {
+		if rate, isNum := v.(float64); !isNum || rate <= 0 {
+			utils.BadRequest(c, "流量倍率必须为正数")
+			return
+		}
+	}
 	if err := db.Model(&node).Updates(updates).Error; err != nil {
 		utils.InternalError(c, "更新节点失败")
 		return
@@ -1739,18 +1772,24 @@ func AdminImportNodes(c *gin.Context) {
 		return
 	}
 
-	nodes, err := services.ParseSubscriptionContent(content)
+	nodes, report, err := services.ParseSubscriptionContentWithReport(content)
 	if err != nil {
 		utils.BadRequest(c, "解析节点失败: "+err.Error())
 		return
 	}
 
 	if len(nodes) == 0 {
-		utils.BadRequest(c, "未找到有效的节点")
+		utils.BadRequest(c, noValidNodesMessage(report))
 		return
 	}
 
 	db := database.GetDB()
+	renamer, err := services.LoadNodeRenamer(db)
+	if err != nil {
+		utils.SysError("node", fmt.Sprintf("加载节点重命名规则失败: %v", err))
+	}
+	nodes = renamer.Apply(nodes)
+
 	successCount := 0
 	for _, node := range nodes {
 		node.IsManual = true // 管理员手动导入的节点，自动更新时不会被删除
@@ -1762,12 +1801,22 @@ func AdminImportNodes(c *gin.Context) {
 	cache.ClearAllSubscriptionCache()
 	utils.CreateAuditLog(c, "import_nodes", "node", 0, "从链接导入节点")
 	utils.Success(c, gin.H{
-		"total":   len(nodes),
-		"success": successCount,
-		"message": "导入完成",
+		"total":    len(nodes),
+		"success":  successCount,
+		"message":  "导入完成",
+		"format":   report.Format,
+		"rejected": report.Rejected,
 	})
 }
 
+// noValidNodesMessage explains an import that produced no nodes, including the first parse failure.
+func noValidNodesMessage(report *services.NodeParseReport) string {
+	if summary := report.Summary(); summary != "" {
+		return "未找到有效的节点（" + summary + "）"
+	}
+	return "未找到有效的节点"
+}
+
 func AdminTestNode(c *gin.Context) {
 	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
 	if err != nil {
@@ -2063,13 +2112,13 @@ func AdminImportCustomNodeLinks(c *gin.Context) {
 		return
 	}
 
-	nodes, err := services.ParseSubscriptionContent(req.Links)
+	nodes, report, err := services.ParseSubscriptionContentWithReport(req.Links)
 	if err != nil {
 		utils.BadRequest(c, "解析节点失败: "+err.Error())
 		return
 	}
 	if len(nodes) == 0 {
-		utils.BadRequest(c, "未找到有效的节点")
+		utils.BadRequest(c, noValidNodesMessage(report))
 		return
 	}
 
@@ -2107,9 +2156,11 @@ func AdminImportCustomNodeLinks(c *gin.Context) {
 	utils.CreateAuditLog(c, "import_custom_node_links", "custom_node", 0, "导入专线节点链接")
 	cache.ClearAllSubscriptionCache()
 	utils.Success(c, gin.H{
-		"total":   len(nodes),
-		"success": successCount,
-		"message": "导入完成",
+		"total":    len(nodes),
+		"success":  successCount,
+		"message":  "导入完成",
+		"format":   report.Format,
+		"rejected": report.Rejected,
 	})
 }
 
@@ -2264,7 +2315,7 @@ func AdminListSubscriptions(c *gin.Context) {
 	items := make([]SubItem, 0, len(subs))
 	for _, sub := range subs {
 		item := SubItem{Subscription: sub}
-		subURLs := buildSubscriptionURLs(baseURL, sub.SubscriptionURL)
+		subURLs := buildSubscriptionURLs(baseURL, &sub, "")
 		item.UniversalURL, _ = subURLs["universal_url"].(string)
 		item.ClashURL, _ = subURLs["clash_url"].(string)
 		if u, ok := userMap[sub.UserID]; ok {
@@ -2368,30 +2419,37 @@ func AdminGetSubscription(c *gin.Context) {
 	wg.Wait()
 
 	result := gin.H{
-		"id":               sub.ID,
-		"user_id":          sub.UserID,
-		"package_id":       sub.PackageID,
-		"subscription_url": sub.SubscriptionURL,
-		"device_limit":     sub.DeviceLimit,
-		"current_devices":  sub.CurrentDevices,
-		"universal_count":  sub.UniversalCount,
-		"clash_count":      sub.ClashCount,
-		"is_active":        sub.IsActive,
-		"status":           sub.Status,
-		"expire_time":      sub.ExpireTime,
-		"created_at":       sub.CreatedAt,
-		"updated_at":       sub.UpdatedAt,
-		"devices":          devices,
-		"recent_orders":    orders,
-		"balance_logs":     balanceLogs,
-		"login_history":    loginHistory,
-		"resets":           resets,
-		"recharge_records": rechargeRecords,
+		"id":                 sub.ID,
+		"user_id":            sub.UserID,
+		"package_id":         sub.PackageID,
+		"subscription_url":   sub.SubscriptionURL,
+		"device_limit":       sub.DeviceLimit,
+		"current_devices":    sub.CurrentDevices,
+		"universal_count":    sub.UniversalCount,
+		"clash_count":        sub.ClashCount,
+		"upload_traffic":     sub.UploadTraffic,
+		"download_traffic":   sub.DownloadTraffic,
+		"traffic_quota":      sub.TrafficQuota,
+		"speed_limit":        sub.SpeedLimit,
+		"traffic_reset_mode": sub.TrafficResetMode,
+		"next_traffic_reset": services.SubscriptionNextTrafficReset(&sub),
+		"traffic_exhausted":  sub.TrafficExhausted,
+		"is_active":          sub.IsActive,
+		"status":             sub.Status,
+		"expire_time":        sub.ExpireTime,
+		"created_at":         sub.CreatedAt,
+		"updated_at":         sub.UpdatedAt,
+		"devices":            devices,
+		"recent_orders":      orders,
+		"balance_logs":       balanceLogs,
+		"login_history":      loginHistory,
+		"resets":             resets,
+		"recharge_records":   rechargeRecords,
 	}
 
 	// Build full subscription URLs
 	baseURL := getSubscriptionBaseURL()
-	for key, value := range buildSubscriptionURLs(baseURL, sub.SubscriptionURL) {
+	for key, value := range buildSubscriptionURLs(baseURL, &sub, "") {
 		result[key] = value
 	}
 
@@ -2440,8 +2498,9 @@ func AdminResetSubscription(c *gin.Context) {
 			return err
 		}
 		if err := tx.Model(&sub).Updates(map[string]interface{}{
-			"subscription_url": newURL,
-			"current_devices":  0,
+			"subscription_url":   newURL,
+			"current_devices":    0,
+			"leak_blocked_until": nil,
 		}).Error; err != nil {
 			return err
 		}
@@ -2459,6 +2518,7 @@ func AdminResetSubscription(c *gin.Context) {
 		utils.InternalError(c, "重置订阅失败")
 		return
 	}
+	services.ClearSubscriptionIPs(sub.ID)
 
 	// 通知用户订阅已重置
 	go services.NotifyUser(sub.UserID, "subscription_reset", map[string]string{"reset_by": "管理员"})
@@ -2499,16 +2559,8 @@ func AdminExtendSubscription(c *gin.Context) {
 		return
 	}
 
-	newExpire := sub.ExpireTime
-	if newExpire.Before(time.Now()) {
-		newExpire = time.Now()
-	}
-	newExpire = newExpire.AddDate(0, 0, req.Days)
-	if err := db.Model(&sub).Updates(map[string]interface{}{
-		"expire_time": newExpire,
-		"is_active":   true,
-		"status":      "active",
-	}).Error; err != nil {
+	newExpire, err := services.ExtendSubscriptionDays(db, &sub, req.Days)
+	if err != nil {
 		utils.InternalError(c, "延长订阅失败")
 		return
 	}
@@ -3168,6 +3220,51 @@ func AdminSubscriptionLogs(c *gin.Context) {
 	utils.SuccessPage(c, items, total, p.Page, p.PageSize)
 }
 
+// AdminSubscriptionAccessLogs GET /admin/subscriptions/:id/access-logs
+// 支持 status / format / ip / cache_hit / start_date / end_date 过滤
+func AdminSubscriptionAccessLogs(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.BadRequest(c, "无效的订阅ID")
+		return
+	}
+	p := utils.GetPagination(c)
+	db := database.GetDB().Model(&models.SubscriptionAccessLog{}).Where("subscription_id = ?", id)
+	if status := c.Query("status"); status != "" {
+		db = db.Where("status = ?", status)
+	}
+	if format := c.Query("format"); format != "" {
+		db = db.Where("format = ?", format)
+	}
+	if ip := c.Query("ip"); ip != "" {
+		db = db.Where("ip_address = ?", ip)
+	}
+	if cacheHit := c.Query("cache_hit"); cacheHit != "" {
+		db = db.Where("cache_hit = ?", cacheHit == "true" || cacheHit == "1")
+	}
+	if startDate := c.Query("start_date"); startDate != "" {
+		start, err := time.ParseInLocation("2006-01-02", startDate, time.Local)
+		if err != nil {
+			utils.BadRequest(c, "start_date 格式错误，应为 YYYY-MM-DD")
+			return
+		}
+		db = db.Where("created_at >= ?", start)
+	}
+	if endDate := c.Query("end_date"); endDate != "" {
+		end, err := time.ParseInLocation("2006-01-02", endDate, time.Local)
+		if err != nil {
+			utils.BadRequest(c, "end_date 格式错误，应为 YYYY-MM-DD")
+			return
+		}
+		db = db.Where("created_at < ?", end.AddDate(0, 0, 1))
+	}
+	var total int64
+	db.Count(&total)
+	var items []models.SubscriptionAccessLog
+	db.Order("created_at DESC, id DESC").Offset(p.Offset()).Limit(p.PageSize).Find(&items)
+	utils.SuccessPage(c, items, total, p.Page, p.PageSize)
+}
+
 func AdminBalanceLogs(c *gin.Context) {
 	p := utils.GetPagination(c)
 	var items []models.BalanceLog
@@ -3931,6 +4028,8 @@ func AdminUpdateSubscription(c *gin.Context) {
 
 	allowed := map[string]bool{
 		"device_limit": true, "is_active": true, "expire_time": true, "protocol_filter": true,
+		"traffic_quota": true, "upload_traffic": true, "download_traffic": true, "speed_limit": true,
+		"traffic_reset_mode": true, "traffic_reset_day": true,
 	}
 	updates := make(map[string]interface{})
 	for k, v := range req {
@@ -4006,8 +4105,9 @@ func AdminSendSubscriptionEmail(c *gin.Context) {
 		utils.BadRequest(c, "系统未配置域名")
 		return
 	}
-	universalURL := fmt.Sprintf("%s/api/v1/sub/%s", baseURL, sub.SubscriptionURL)
-	clashURL := fmt.Sprintf("%s/api/v1/sub/clash/%s", baseURL, sub.SubscriptionURL)
+	subURLs := buildSubscriptionURLs(baseURL, &sub, "")
+	universalURL, _ := subURLs["universal_url"].(string)
+	clashURL, _ := subURLs["clash_url"].(string)
 	subject, body := services.RenderEmail("subscription", map[string]string{
 		"clash_url": clashURL, "universal_url": universalURL,
 		"expire_time": sub.ExpireTime.Format("2006-01-02 15:04"),
@@ -4101,6 +4201,15 @@ func AdminDeleteUserFull(c *gin.Context) {
 	if rollbackWithErr(tx.Where("user_id = ?", id).Delete(&models.Subscription{}).Error) {
 		return
 	}
+	if rollbackWithErr(tx.Where("user_id = ?", id).Delete(&models.SubscriptionPause{}).Error) {
+		return
+	}
+	if rollbackWithErr(tx.Where("user_id = ?", id).Delete(&models.SubscriptionProfile{}).Error) {
+		return
+	}
+	if rollbackWithErr(tx.Where("user_id = ?", id).Delete(&models.UserCustomRules{}).Error) {
+		return
+	}
 	if rollbackWithErr(tx.Where("user_id = ?", id).Delete(&models.Ticket{}).Error) {
 		return
 	}
@@ -4663,27 +4772,14 @@ func AdminBatchUserAction(c *gin.Context) {
 		result := db.Model(&models.User{}).Where("id IN ?", req.UserIDs).Update("is_active", true)
 		affected = result.RowsAffected
 		// Sync subscription status
-		for _, uid := range req.UserIDs {
-			var sub models.Subscription
-			if db.Where("user_id = ?", uid).First(&sub).Error == nil {
-				updates := map[string]interface{}{"is_active": true}
-				if sub.ExpireTime.After(time.Now()) {
-					updates["status"] = "active"
-				} else {
-					updates["status"] = "expired"
-				}
-				if err := db.Model(&sub).Updates(updates).Error; err != nil {
-					utils.InternalError(c, "同步订阅状态失败")
-					return
-				}
-			}
+		if err := services.EnableUserSubscriptions(db, req.UserIDs); err != nil {
+			utils.InternalError(c, "同步订阅状态失败")
+			return
 		}
 	case "disable":
 		result := db.Model(&models.User{}).Where("id IN ? AND is_admin = ?", req.UserIDs, false).Update("is_active", false)
 		affected = result.RowsAffected
-		if err := db.Model(&models.Subscription{}).Where("user_id IN ?", req.UserIDs).Updates(map[string]interface{}{
-			"is_active": false, "status": "disabled",
-		}).Error; err != nil {
+		if err := services.DisableUserSubscriptions(db, req.UserIDs); err != nil {
 			utils.InternalError(c, "同步订阅状态失败")
 			return
 		}
@@ -5013,8 +5109,9 @@ func AdminImportUsersCSV(c *gin.Context) {
 
 func AdminBatchNodeAction(c *gin.Context) {
 	var req struct {
-		IDs    []uint `json:"ids" binding:"required"`
-		Action string `json:"action" binding:"required"`
+		IDs     []uint `json:"ids" binding:"required"`
+		Action  string `json:"action" binding:"required"`
+		GroupID *uint  `json:"group_id"` // set_group 使用，nil 表示移出分组
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
 		utils.BadRequest(c, "参数错误")
@@ -5044,6 +5141,9 @@ func AdminBatchNodeAction(c *gin.Context) {
 	case "delete":
 		result := db.Where("id IN ?", req.IDs).Delete(&models.Node{})
 		affected = result.RowsAffected
+	case "set_group":
+		result := db.Model(&models.Node{}).Where("id IN ?", req.IDs).Update("group_id", req.GroupID)
+		affected = result.RowsAffected
 	default:
 		utils.BadRequest(c, "不支持的操作: "+req.Action)
 		return
@@ -5059,12 +5159,16 @@ func AdminBatchNodeAction(c *gin.Context) {
 var defaultProtocolFilter = map[string][]string{
 	"clash_protocols":     {"vmess", "vless", "trojan", "ss", "ssr", "hysteria", "hysteria2", "tuic", "anytls", "socks5", "http", "wireguard"},
 	"universal_protocols": {"vmess", "vless", "trojan", "ss", "ssr", "hysteria", "hysteria2", "tuic", "anytls", "socks", "socks5", "http", "wireguard"},
+	"surfboard_protocols": {"vmess", "trojan", "ss", "socks5", "http"},
+	"egern_protocols":     {"vmess", "vless", "trojan", "ss", "hysteria2", "tuic", "socks5", "http"},
 }
 
+var protocolFilterKeys = []string{"clash_protocols", "universal_protocols", "surfboard_protocols", "egern_protocols"}
+
 func AdminGetProtocolFilter(c *gin.Context) {
 	db := database.GetDB()
 	result := make(map[string][]string)
-	for _, key := range []string{"clash_protocols", "universal_protocols"} {
+	for _, key := range protocolFilterKeys {
 		var cfg models.SystemConfig
 		if err := db.Where("category = ? AND `key` = ?", "protocol_filter", key).First(&cfg).Error; err == nil && cfg.Value != "" {
 			var protocols []string
@@ -5085,7 +5189,7 @@ func AdminUpdateProtocolFilter(c *gin.Context) {
 		return
 	}
 	db := database.GetDB()
-	for _, key := range []string{"clash_protocols", "universal_protocols"} {
+	for _, key := range protocolFilterKeys {
 		protocols, ok := req[key]
 		if !ok {
 			continue
@@ -62,6 +62,7 @@ func AdminConfigUpdateStatus(c *gin.Context) {
 	utils.Success(c, gin.H{
 		"running":   svc.IsRunning(),
 		"scheduled": svc.IsScheduled(),
+		"sources":   svc.SourceStatuses(),
 	})
 }
 
@@ -82,6 +83,10 @@ func AdminSaveConfigUpdateConfig(c *gin.Context) {
 		return
 	}
 	svc := services.GetConfigUpdateService()
+	if err := svc.PrepareConfig(&cfg); err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
 	if svc.IsScheduled() {
 		svc.StopSchedule()
 	}
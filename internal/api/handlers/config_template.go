@@ -0,0 +1,411 @@
+package handlers
+
+import (
+	"fmt"
+	"strconv"
+
+	"cboard/v2/internal/cache"
+	"cboard/v2/internal/database"
+	"cboard/v2/internal/models"
+	"cboard/v2/internal/services"
+	"cboard/v2/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ==================== Config Templates ====================
+
+// configTemplateBindingIDs groups a template's bindings by target type.
+func configTemplateBindingIDs(bindings []models.ConfigTemplateBinding) gin.H {
+	packageIDs, levelIDs := []uint{}, []uint{}
+	for _, b := range bindings {
+		switch b.TargetType {
+		case services.ConfigTemplateTargetPackage:
+			packageIDs = append(packageIDs, b.TargetID)
+		case services.ConfigTemplateTargetUserLevel:
+			levelIDs = append(levelIDs, b.TargetID)
+		}
+	}
+	return gin.H{"package_ids": packageIDs, "user_level_ids": levelIDs}
+}
+
+// AdminListConfigTemplates GET /admin/config-templates
+// 列表不返回模板内容；format 可筛选格式。
+func AdminListConfigTemplates(c *gin.Context) {
+	db := database.GetDB()
+	query := db.Model(&models.ConfigTemplate{}).Omit("content")
+	if format := c.Query("format"); format != "" {
+		query = query.Where("format = ?", format)
+	}
+	var templates []models.ConfigTemplate
+	query.Order("format ASC, id ASC").Find(&templates)
+
+	var bindings []models.ConfigTemplateBinding
+	db.Find(&bindings)
+	bindingMap := make(map[uint][]models.ConfigTemplateBinding)
+	for _, b := range bindings {
+		bindingMap[b.TemplateID] = append(bindingMap[b.TemplateID], b)
+	}
+
+	items := make([]gin.H, 0, len(templates))
+	for _, t := range templates {
+		items = append(items, gin.H{
+			"id":          t.ID,
+			"name":        t.Name,
+			"format":      t.Format,
+			"description": t.Description,
+			"version":     t.Version,
+			"is_default":  t.IsDefault,
+			"bindings":    configTemplateBindingIDs(bindingMap[t.ID]),
+			"created_at":  t.CreatedAt,
+			"updated_at":  t.UpdatedAt,
+		})
+	}
+	utils.Success(c, items)
+}
+
+// AdminGetConfigTemplate GET /admin/config-templates/:id
+func AdminGetConfigTemplate(c *gin.Context) {
+	tpl, ok := loadConfigTemplate(c)
+	if !ok {
+		return
+	}
+	var bindings []models.ConfigTemplateBinding
+	database.GetDB().Where("template_id = ?", tpl.ID).Find(&bindings)
+	utils.Success(c, gin.H{"template": tpl, "bindings": configTemplateBindingIDs(bindings)})
+}
+
+func loadConfigTemplate(c *gin.Context) (*models.ConfigTemplate, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.BadRequest(c, "无效的模板ID")
+		return nil, false
+	}
+	var tpl models.ConfigTemplate
+	if err := database.GetDB().First(&tpl, id).Error; err != nil {
+		utils.NotFound(c, "模板不存在")
+		return nil, false
+	}
+	return &tpl, true
+}
+
+// setDefaultConfigTemplate makes tpl the only default template of its format.
+func setDefaultConfigTemplate(tx *gorm.DB, tpl *models.ConfigTemplate) error {
+	if err := tx.Model(&models.ConfigTemplate{}).Where("format = ? AND id <> ?", tpl.Format, tpl.ID).Update("is_default", false).Error; err != nil {
+		return err
+	}
+	return tx.Model(&models.ConfigTemplate{}).Where("id = ?", tpl.ID).Update("is_default", true).Error
+}
+
+// AdminCreateConfigTemplate POST /admin/config-templates
+func AdminCreateConfigTemplate(c *gin.Context) {
+	var req struct {
+		Name        string  `json:"name" binding:"required"`
+		Format      string  `json:"format" binding:"required"`
+		Description *string `json:"description"`
+		Content     string  `json:"content" binding:"required"`
+		IsDefault   bool    `json:"is_default"`
+		Comment     string  `json:"comment"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, "参数错误")
+		return
+	}
+	if err := services.ValidateConfigTemplate(req.Format, req.Content); err != nil {
+		utils.BadRequest(c, "模板校验失败: "+err.Error())
+		return
+	}
+	adminID := c.GetUint("user_id")
+	tpl := models.ConfigTemplate{Name: req.Name, Format: req.Format, Description: req.Description, Content: req.Content}
+	if req.Comment == "" {
+		req.Comment = "创建模板"
+	}
+	if err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&tpl).Error; err != nil {
+			return err
+		}
+		if req.IsDefault {
+			if err := setDefaultConfigTemplate(tx, &tpl); err != nil {
+				return err
+			}
+			tpl.IsDefault = true
+		}
+		return services.SaveConfigTemplateVersion(tx, &tpl, req.Comment, &adminID)
+	}); err != nil {
+		utils.InternalError(c, "创建模板失败")
+		return
+	}
+	utils.CreateAuditLog(c, "create_config_template", "config_template", tpl.ID, fmt.Sprintf("创建%s模板: %s", tpl.Format, tpl.Name))
+	cache.ClearAllSubscriptionCache()
+	utils.Success(c, tpl)
+}
+
+// AdminUpdateConfigTemplate PUT /admin/config-templates/:id
+// 修改内容时会校验并生成新版本；is_default=true 会取消同格式其他模板的默认标记。
+func AdminUpdateConfigTemplate(c *gin.Context) {
+	tpl, ok := loadConfigTemplate(c)
+	if !ok {
+		return
+	}
+	var req struct {
+		Name        *string `json:"name"`
+		Description *string `json:"description"`
+		Content     *string `json:"content"`
+		IsDefault   *bool   `json:"is_default"`
+		Comment     string  `json:"comment"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, "参数错误")
+		return
+	}
+	contentChanged := req.Content != nil && *req.Content != tpl.Content
+	if contentChanged {
+		if err := services.ValidateConfigTemplate(tpl.Format, *req.Content); err != nil {
+			utils.BadRequest(c, "模板校验失败: "+err.Error())
+			return
+		}
+	}
+	adminID := c.GetUint("user_id")
+	if err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		updates := map[string]interface{}{}
+		if req.Name != nil && *req.Name != "" {
+			updates["name"] = *req.Name
+		}
+		if req.Description != nil {
+			updates["description"] = *req.Description
+		}
+		if contentChanged {
+			updates["content"] = *req.Content
+		}
+		if req.IsDefault != nil && !*req.IsDefault {
+			updates["is_default"] = false
+		}
+		if len(updates) > 0 {
+			if err := tx.Model(tpl).Updates(updates).Error; err != nil {
+				return err
+			}
+		}
+		if req.IsDefault != nil && *req.IsDefault {
+			if err := setDefaultConfigTemplate(tx, tpl); err != nil {
+				return err
+			}
+			tpl.IsDefault = true
+		}
+		if contentChanged {
+			tpl.Content = *req.Content
+			return services.SaveConfigTemplateVersion(tx, tpl, req.Comment, &adminID)
+		}
+		return nil
+	}); err != nil {
+		utils.InternalError(c, "更新模板失败")
+		return
+	}
+	utils.CreateAuditLog(c, "update_config_template", "config_template", tpl.ID, fmt.Sprintf("更新%s模板: %s (版本 %d)", tpl.Format, tpl.Name, tpl.Version))
+	cache.ClearAllSubscriptionCache()
+	utils.Success(c, tpl)
+}
+
+// AdminDeleteConfigTemplate DELETE /admin/config-templates/:id
+// 删除模板及其历史版本和绑定，受影响的套餐/等级回退到默认模板。
+func AdminDeleteConfigTemplate(c *gin.Context) {
+	tpl, ok := loadConfigTemplate(c)
+	if !ok {
+		return
+	}
+	if err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("template_id = ?", tpl.ID).Delete(&models.ConfigTemplateBinding{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("template_id = ?", tpl.ID).Delete(&models.ConfigTemplateVersion{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(tpl).Error
+	}); err != nil {
+		utils.InternalError(c, "删除模板失败")
+		return
+	}
+	utils.CreateAuditLog(c, "delete_config_template", "config_template", tpl.ID, fmt.Sprintf("删除%s模板: %s", tpl.Format, tpl.Name))
+	cache.ClearAllSubscriptionCache()
+	utils.SuccessMessage(c, "删除成功")
+}
+
+// AdminListConfigTemplateVersions GET /admin/config-templates/:id/versions
+func AdminListConfigTemplateVersions(c *gin.Context) {
+	tpl, ok := loadConfigTemplate(c)
+	if !ok {
+		return
+	}
+	p := utils.GetPagination(c)
+	query := database.GetDB().Model(&models.ConfigTemplateVersion{}).Where("template_id = ?", tpl.ID)
+	var total int64
+	query.Count(&total)
+	var versions []models.ConfigTemplateVersion
+	query.Order("version DESC").Offset(p.Offset()).Limit(p.PageSize).Find(&versions)
+	utils.SuccessPage(c, versions, total, p.Page, p.PageSize)
+}
+
+// AdminRollbackConfigTemplate POST /admin/config-templates/:id/rollback
+// 将指定历史版本的内容保存为一个新版本，历史记录不会被删除。
+func AdminRollbackConfigTemplate(c *gin.Context) {
+	tpl, ok := loadConfigTemplate(c)
+	if !ok {
+		return
+	}
+	var req struct {
+		Version int `json:"version" binding:"required,min=1"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, "参数错误")
+		return
+	}
+	db := database.GetDB()
+	var target models.ConfigTemplateVersion
+	if err := db.Where("template_id = ? AND version = ?", tpl.ID, req.Version).First(&target).Error; err != nil {
+		utils.NotFound(c, "版本不存在")
+		return
+	}
+	if err := services.ValidateConfigTemplate(tpl.Format, target.Content); err != nil {
+		utils.BadRequest(c, "该版本未通过校验，无法回滚: "+err.Error())
+		return
+	}
+	adminID := c.GetUint("user_id")
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(tpl).Update("content", target.Content).Error; err != nil {
+			return err
+		}
+		tpl.Content = target.Content
+		return services.SaveConfigTemplateVersion(tx, tpl, fmt.Sprintf("回滚到版本 %d", req.Version), &adminID)
+	}); err != nil {
+		utils.InternalError(c, "回滚模板失败")
+		return
+	}
+	utils.CreateAuditLog(c, "rollback_config_template", "config_template", tpl.ID, fmt.Sprintf("回滚%s模板 %s 到版本 %d", tpl.Format, tpl.Name, req.Version))
+	cache.ClearAllSubscriptionCache()
+	utils.Success(c, tpl)
+}
+
+// AdminPreviewConfigTemplate POST /admin/config-templates/preview
+// 使用当前在线节点渲染模板；可直接传 content，或传 template_id（及可选 version）预览已保存的模板。
+func AdminPreviewConfigTemplate(c *gin.Context) {
+	var req struct {
+		Format     string `json:"format"`
+		Content    string `json:"content"`
+		TemplateID uint   `json:"template_id"`
+		Version    int    `json:"version"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, "参数错误")
+		return
+	}
+	db := database.GetDB()
+	if req.Content == "" && req.TemplateID > 0 {
+		var tpl models.ConfigTemplate
+		if err := db.First(&tpl, req.TemplateID).Error; err != nil {
+			utils.NotFound(c, "模板不存在")
+			return
+		}
+		req.Format, req.Content = tpl.Format, tpl.Content
+		if req.Version > 0 {
+			var v models.ConfigTemplateVersion
+			if err := db.Where("template_id = ? AND version = ?", tpl.ID, req.Version).First(&v).Error; err != nil {
+				utils.NotFound(c, "版本不存在")
+				return
+			}
+			req.Content = v.Content
+		}
+	}
+
+	nodes := []models.Node{createInfoNode("📢 官网: 预览"), createInfoNode("⏰ 到期: 2099-12-31")}
+	var onlineNodes []models.Node
+	db.Scopes(services.LiveNodeScope).Where("status = ?", "online").Order("order_index ASC").Limit(20).Find(&onlineNodes)
+	nodes = append(nodes, onlineNodes...)
+
+	rendered, err := services.RenderConfigTemplate(req.Format, req.Content, nodes, "模板预览")
+	if err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+	utils.Success(c, gin.H{"content": rendered, "node_count": len(onlineNodes)})
+}
+
+// AdminSetConfigTemplateBindings PUT /admin/config-templates/:id/bindings
+// 以请求中的列表整体替换模板绑定的套餐与用户等级；已绑定到同格式其他模板的对象会改绑到本模板。
+func AdminSetConfigTemplateBindings(c *gin.Context) {
+	tpl, ok := loadConfigTemplate(c)
+	if !ok {
+		return
+	}
+	var req struct {
+		PackageIDs   []uint `json:"package_ids"`
+		UserLevelIDs []uint `json:"user_level_ids"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, "参数错误")
+		return
+	}
+	db := database.GetDB()
+	targets := map[string][]uint{
+		services.ConfigTemplateTargetPackage:   uniqueUints(req.PackageIDs),
+		services.ConfigTemplateTargetUserLevel: uniqueUints(req.UserLevelIDs),
+	}
+	var count int64
+	if ids := targets[services.ConfigTemplateTargetPackage]; len(ids) > 0 {
+		db.Model(&models.Package{}).Where("id IN ?", ids).Count(&count)
+		if int(count) != len(ids) {
+			utils.BadRequest(c, "包含不存在的套餐")
+			return
+		}
+	}
+	if ids := targets[services.ConfigTemplateTargetUserLevel]; len(ids) > 0 {
+		db.Model(&models.UserLevel{}).Where("id IN ?", ids).Count(&count)
+		if int(count) != len(ids) {
+			utils.BadRequest(c, "包含不存在的用户等级")
+			return
+		}
+	}
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("template_id = ?", tpl.ID).Delete(&models.ConfigTemplateBinding{}).Error; err != nil {
+			return err
+		}
+		for targetType, ids := range targets {
+			if len(ids) == 0 {
+				continue
+			}
+			if err := tx.Where("format = ? AND target_type = ? AND target_id IN ?", tpl.Format, targetType, ids).
+				Delete(&models.ConfigTemplateBinding{}).Error; err != nil {
+				return err
+			}
+			for _, id := range ids {
+				if err := tx.Create(&models.ConfigTemplateBinding{
+					TemplateID: tpl.ID, Format: tpl.Format, TargetType: targetType, TargetID: id,
+				}).Error; err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}); err != nil {
+		utils.InternalError(c, "保存模板绑定失败")
+		return
+	}
+	utils.CreateAuditLog(c, "update_config_template_bindings", "config_template", tpl.ID,
+		fmt.Sprintf("设置模板 %s 的绑定: 套餐 %v, 用户等级 %v", tpl.Name, targets[services.ConfigTemplateTargetPackage], targets[services.ConfigTemplateTargetUserLevel]))
+	cache.ClearAllSubscriptionCache()
+	utils.Success(c, gin.H{
+		"package_ids":    targets[services.ConfigTemplateTargetPackage],
+		"user_level_ids": targets[services.ConfigTemplateTargetUserLevel],
+	})
+}
+
+func uniqueUints(ids []uint) []uint {
+	seen := make(map[uint]bool, len(ids))
+	out := make([]uint, 0, len(ids))
+	for _, id := range ids {
+		if id > 0 && !seen[id] {
+			seen[id] = true
+			out = append(out, id)
+		}
+	}
+	return out
+}
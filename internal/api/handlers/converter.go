@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"cboard/v2/internal/database"
+	"cboard/v2/internal/models"
+	"cboard/v2/internal/services"
+	"cboard/v2/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ==================== Subscription Converter ====================
+
+// ConvertSubscription POST /tools/convert（管理员另有 /admin/tools/convert）
+// 拉取 url 或使用粘贴的 content，按 include / exclude 筛选、按 rename 规则改名后转换为 target 格式。
+// 普通用户需开启 sub_converter_user_enabled 才能使用。
+func ConvertSubscription(c *gin.Context) {
+	user := c.MustGet("user").(*models.User)
+	if !user.IsAdmin && !utils.IsBoolSetting("sub_converter_user_enabled") {
+		utils.Forbidden(c, "订阅转换功能未开放")
+		return
+	}
+
+	var req struct {
+		URL     string `json:"url"`
+		Content string `json:"content"`
+		Target  string `json:"target" binding:"required"`
+		Include string `json:"include"`
+		Exclude string `json:"exclude"`
+		Rename  string `json:"rename"`
+		Name    string `json:"name"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, "参数错误: "+err.Error())
+		return
+	}
+	target, ok := services.LookupConvertTarget(req.Target)
+	if !ok {
+		utils.BadRequest(c, "不支持的目标格式")
+		return
+	}
+
+	content := req.Content
+	if req.URL != "" {
+		fetched, err := services.FetchSubscriptionContent(req.URL)
+		if err != nil {
+			utils.BadRequest(c, "获取订阅内容失败: "+err.Error())
+			return
+		}
+		content = fetched
+	}
+	if content == "" {
+		utils.BadRequest(c, "订阅URL和内容不能同时为空")
+		return
+	}
+
+	nodes, err := services.ParseSubscriptionContent(content)
+	if err != nil {
+		utils.BadRequest(c, "解析节点失败: "+err.Error())
+		return
+	}
+	if len(nodes) == 0 {
+		utils.BadRequest(c, "未找到有效的节点")
+		return
+	}
+	filtered, err := services.FilterAndRenameNodes(nodes, req.Include, req.Exclude, req.Rename)
+	if err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+	if len(filtered) == 0 {
+		utils.BadRequest(c, "筛选后没有剩余节点")
+		return
+	}
+
+	if req.Name == "" {
+		req.Name = "订阅转换"
+	}
+	result, err := services.ConvertNodes(database.GetDB(), filtered, target, req.Name)
+	if err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	if user.IsAdmin {
+		utils.CreateAuditLog(c, "convert_subscription", "subscription", 0, "订阅转换为 "+target.Format)
+	}
+	utils.Success(c, gin.H{
+		"content":      result,
+		"target":       target.Format,
+		"content_type": target.ContentType,
+		"file_suffix":  target.FileSuffix,
+		"total":        len(nodes),
+		"matched":      len(filtered),
+	})
+}
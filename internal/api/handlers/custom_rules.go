@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"strings"
+
+	"cboard/v2/internal/database"
+	"cboard/v2/internal/models"
+	"cboard/v2/internal/services"
+	"cboard/v2/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ==================== User Custom Rules ====================
+
+func customRulesEnabled() bool {
+	return utils.IsBoolSettingDefault("user_custom_rules_enabled", true)
+}
+
+func customRulesLimit() int {
+	return utils.GetIntSetting("user_custom_rules_max", 100)
+}
+
+// GetCustomRules GET /users/custom-rules
+func GetCustomRules(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+	var record models.UserCustomRules
+	database.GetDB().Where("user_id = ?", userID).First(&record)
+	rules := []string{}
+	if record.Rules != "" {
+		rules = strings.Split(record.Rules, "\n")
+	}
+	utils.Success(c, gin.H{
+		"rules":      rules,
+		"enabled":    customRulesEnabled(),
+		"max_rules":  customRulesLimit(),
+		"updated_at": record.UpdatedAt,
+	})
+}
+
+// UpdateCustomRules PUT /users/custom-rules
+// 规则使用 Clash 语法，保存时逐行校验，下发 Clash / Stash、Surge、sing-box 订阅时插入到模板规则之前。
+func UpdateCustomRules(c *gin.Context) {
+	if !customRulesEnabled() {
+		utils.Forbidden(c, "自定义规则功能已关闭")
+		return
+	}
+	userID := c.MustGet("user_id").(uint)
+	var req struct {
+		Rules []string `json:"rules"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, "参数错误")
+		return
+	}
+	rules, err := services.ValidateUserRules(req.Rules, customRulesLimit())
+	if err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	db := database.GetDB()
+	var record models.UserCustomRules
+	db.Where("user_id = ?", userID).First(&record)
+	record.UserID = userID
+	record.Rules = strings.Join(rules, "\n")
+	if err := db.Save(&record).Error; err != nil {
+		utils.InternalError(c, "保存规则失败")
+		return
+	}
+	if rules == nil {
+		rules = []string{}
+	}
+	utils.Success(c, gin.H{"rules": rules})
+}
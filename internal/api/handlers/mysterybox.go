@@ -10,6 +10,7 @@ import (
 
 	"cboard/v2/internal/database"
 	"cboard/v2/internal/models"
+	"cboard/v2/internal/services"
 	"cboard/v2/internal/utils"
 
 	"github.com/gin-gonic/gin"
@@ -379,7 +380,7 @@ func OpenMysteryBox(c *gin.Context) {
 		case "subscription_days":
 			days := int(prize.Value)
 			var sub models.Subscription
-			if err := tx.Where("user_id = ?", userID).First(&sub).Error; err != nil {
+			if err := tx.Where("user_id = ?", userID).Order("id ASC").First(&sub).Error; err != nil {
 				sub = models.Subscription{
 					UserID: userID, SubscriptionURL: utils.GenerateHexToken(),
 					DeviceLimit: 3, IsActive: true, Status: "active",
@@ -388,17 +389,8 @@ func OpenMysteryBox(c *gin.Context) {
 				if err := tx.Create(&sub).Error; err != nil {
 					return err
 				}
-			} else {
-				newExpire := sub.ExpireTime
-				if newExpire.Before(time.Now()) {
-					newExpire = time.Now()
-				}
-				newExpire = newExpire.AddDate(0, 0, days)
-				if err := tx.Model(&sub).Updates(map[string]interface{}{
-					"expire_time": newExpire, "is_active": true, "status": "active",
-				}).Error; err != nil {
-					return err
-				}
+			} else if _, err := services.ExtendSubscriptionDays(tx, &sub, days); err != nil {
+				return err
 			}
 		case "coupon":
 			couponCode = "MB" + utils.GenerateRandomString(10)
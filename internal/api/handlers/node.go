@@ -13,9 +13,11 @@ import (
 
 	"cboard/v2/internal/database"
 	"cboard/v2/internal/models"
+	"cboard/v2/internal/services"
 	"cboard/v2/internal/utils"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 // ListNodes returns paginated active nodes.
@@ -28,7 +30,7 @@ func ListNodes(c *gin.Context) {
 
 	// --- Fetch public nodes ---
 	var totalPublic int64
-	pubQuery := db.Model(&models.Node{}).Where("is_active = ?", true)
+	pubQuery := db.Model(&models.Node{}).Scopes(services.LiveNodeScope)
 	if region := c.Query("region"); region != "" {
 		pubQuery = pubQuery.Where("region = ?", region)
 	}
@@ -42,12 +44,13 @@ func ListNodes(c *gin.Context) {
 	var hasActiveSub bool
 	var isDedicatedOnly bool
 	if userID > 0 {
-		var activeSub int64
-		db.Model(&models.Subscription{}).Where("user_id = ? AND status = ?", userID, "active").Count(&activeSub)
-		hasActiveSub = activeSub > 0
+		var activeCount int64
+		db.Model(&models.Subscription{}).Where("user_id = ? AND status = ?", userID, "active").Count(&activeCount)
+		hasActiveSub = activeCount > 0
 		if hasActiveSub {
+			// 多订阅时以到期最晚的订阅为准
 			var sub models.Subscription
-			if err := db.Where("user_id = ? AND status = ?", userID, "active").First(&sub).Error; err == nil {
+			if err := db.Where("user_id = ? AND status = ?", userID, "active").Order("expire_time DESC").First(&sub).Error; err == nil {
 				customNodes, isDedicatedOnly, _ = fetchUserCustomNodes(db, userID, sub.ExpireTime)
 			}
 		}
@@ -60,8 +63,13 @@ func ListNodes(c *gin.Context) {
 		allNodes = customNodes
 	} else {
 		// Normal mode: custom nodes first, then public nodes
+		// 已订阅用户只列出套餐授权分组内的节点
 		var allPublic []models.Node
-		db.Model(&models.Node{}).Where("is_active = ?", true).Order("order_index ASC").Find(&allPublic)
+		pubNodes := db.Model(&models.Node{}).Scopes(services.LiveNodeScope)
+		if hasActiveSub {
+			pubNodes = pubNodes.Scopes(services.UserNodeScope(db, userID))
+		}
+		pubNodes.Order("order_index ASC").Find(&allPublic)
 		allNodes = append(customNodes, allPublic...)
 	}
 
@@ -140,12 +148,12 @@ func GetNodeStats(c *gin.Context) {
 
 	// Gather all nodes (public + user custom)
 	var allNodes []models.Node
-	db.Model(&models.Node{}).Where("is_active = ?", true).Find(&allNodes)
+	db.Model(&models.Node{}).Scopes(services.LiveNodeScope).Find(&allNodes)
 
 	userID := c.GetUint("user_id")
 	if userID > 0 {
 		var sub models.Subscription
-		if err := db.Where("user_id = ? AND status = ?", userID, "active").First(&sub).Error; err == nil {
+		if err := db.Where("user_id = ? AND status = ?", userID, "active").Order("expire_time DESC").First(&sub).Error; err == nil {
 			customNodes, _, _ := fetchUserCustomNodes(db, userID, sub.ExpireTime)
 			allNodes = append(allNodes, customNodes...)
 		}
@@ -186,7 +194,7 @@ func GetNode(c *gin.Context) {
 
 	db := database.GetDB()
 	var node models.Node
-	if err := db.Where("id = ? AND is_active = ?", id, true).First(&node).Error; err != nil {
+	if err := db.Scopes(services.LiveNodeScope).Where("id = ?", id).First(&node).Error; err != nil {
 		utils.NotFound(c, "节点不存在")
 		return
 	}
@@ -194,6 +202,9 @@ func GetNode(c *gin.Context) {
 	// Strip config for unauthenticated requests
 	if c.GetUint("user_id") == 0 {
 		node.Config = nil
+	} else if node.GroupID != nil && !userCanAccessNodeGroup(db, c.GetUint("user_id"), *node.GroupID) {
+		// 分组节点仅对套餐授权了该分组的用户展示配置
+		node.Config = nil
 	}
 
 	utils.Success(c, node)
@@ -330,7 +341,7 @@ func TestNode(c *gin.Context) {
 func BatchTestNodes(c *gin.Context) {
 	db := database.GetDB()
 	var nodes []models.Node
-	db.Where("is_active = ? AND config IS NOT NULL AND config != ''", true).Find(&nodes)
+	db.Scopes(services.LiveNodeScope).Where("config IS NOT NULL AND config != ''").Find(&nodes)
 
 	type Result struct {
 		NodeID    uint   `json:"node_id"`
@@ -381,3 +392,17 @@ func BatchTestNodes(c *gin.Context) {
 
 	utils.Success(c, gin.H{"tested": len(results), "results": results})
 }
+
+// userCanAccessNodeGroup reports whether any of the user's active packages grants the node group.
+func userCanAccessNodeGroup(db *gorm.DB, userID uint, groupID uint) bool {
+	var subs []models.Subscription
+	db.Where("user_id = ? AND status = ? AND package_id IS NOT NULL", userID, "active").Find(&subs)
+	for _, sub := range subs {
+		for _, id := range services.PackageNodeGroupIDs(db, uint(*sub.PackageID)) {
+			if id == groupID {
+				return true
+			}
+		}
+	}
+	return false
+}
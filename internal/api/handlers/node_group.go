@@ -0,0 +1,210 @@
+package handlers
+
+import (
+	"fmt"
+	"strconv"
+
+	"cboard/v2/internal/cache"
+	"cboard/v2/internal/database"
+	"cboard/v2/internal/models"
+	"cboard/v2/internal/services"
+	"cboard/v2/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ==================== Node Groups ====================
+
+// AdminListNodeGroups GET /admin/node-groups
+func AdminListNodeGroups(c *gin.Context) {
+	db := database.GetDB()
+	var groups []models.NodeGroup
+	db.Order("sort_order ASC, id ASC").Find(&groups)
+
+	type groupCount struct {
+		GroupID uint
+		Count   int64
+	}
+	var nodeCounts []groupCount
+	db.Model(&models.Node{}).Select("group_id, COUNT(*) as count").Where("group_id IS NOT NULL").Group("group_id").Scan(&nodeCounts)
+	nodeCountMap := make(map[uint]int64, len(nodeCounts))
+	for _, nc := range nodeCounts {
+		nodeCountMap[nc.GroupID] = nc.Count
+	}
+	var bindings []models.PackageNodeGroup
+	db.Find(&bindings)
+	packageMap := make(map[uint][]uint)
+	for _, b := range bindings {
+		packageMap[b.NodeGroupID] = append(packageMap[b.NodeGroupID], b.PackageID)
+	}
+
+	items := make([]gin.H, 0, len(groups))
+	for _, g := range groups {
+		packageIDs := packageMap[g.ID]
+		if packageIDs == nil {
+			packageIDs = []uint{}
+		}
+		items = append(items, gin.H{
+			"id":          g.ID,
+			"name":        g.Name,
+			"description": g.Description,
+			"sort_order":  g.SortOrder,
+			"node_count":  nodeCountMap[g.ID],
+			"package_ids": packageIDs,
+			"created_at":  g.CreatedAt,
+			"updated_at":  g.UpdatedAt,
+		})
+	}
+	utils.Success(c, items)
+}
+
+// AdminCreateNodeGroup POST /admin/node-groups
+func AdminCreateNodeGroup(c *gin.Context) {
+	var req struct {
+		Name        string  `json:"name" binding:"required"`
+		Description *string `json:"description"`
+		SortOrder   int     `json:"sort_order"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, "参数错误")
+		return
+	}
+	group := models.NodeGroup{Name: req.Name, Description: req.Description, SortOrder: req.SortOrder}
+	if err := database.GetDB().Create(&group).Error; err != nil {
+		utils.InternalError(c, "创建节点分组失败，名称可能已存在")
+		return
+	}
+	utils.CreateAuditLog(c, "create_node_group", "node_group", group.ID, fmt.Sprintf("创建节点分组: %s", group.Name))
+	utils.Success(c, group)
+}
+
+// AdminUpdateNodeGroup PUT /admin/node-groups/:id
+func AdminUpdateNodeGroup(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.BadRequest(c, "无效的分组ID")
+		return
+	}
+	db := database.GetDB()
+	var group models.NodeGroup
+	if err := db.First(&group, id).Error; err != nil {
+		utils.NotFound(c, "节点分组不存在")
+		return
+	}
+	var req map[string]interface{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, "参数错误: "+err.Error())
+		return
+	}
+	allowed := map[string]bool{"name": true, "description": true, "sort_order": true}
+	updates := make(map[string]interface{})
+	for k, v := range req {
+		if allowed[k] {
+			updates[k] = v
+		}
+	}
+	if len(updates) == 0 {
+		utils.BadRequest(c, "无有效更新字段")
+		return
+	}
+	if err := db.Model(&group).Updates(updates).Error; err != nil {
+		utils.InternalError(c, "更新节点分组失败")
+		return
+	}
+	utils.CreateAuditLog(c, "update_node_group", "node_group", group.ID, fmt.Sprintf("更新节点分组: %s", group.Name))
+	utils.Success(c, group)
+}
+
+// AdminDeleteNodeGroup DELETE /admin/node-groups/:id
+// 删除分组后其中的节点变为未分组（所有套餐可用），套餐关联一并删除。
+func AdminDeleteNodeGroup(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.BadRequest(c, "无效的分组ID")
+		return
+	}
+	db := database.GetDB()
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Node{}).Where("group_id = ?", id).Update("group_id", nil).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("node_group_id = ?", id).Delete(&models.PackageNodeGroup{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.NodeGroup{}, id).Error
+	}); err != nil {
+		utils.InternalError(c, "删除节点分组失败")
+		return
+	}
+	utils.CreateAuditLog(c, "delete_node_group", "node_group", uint(id), fmt.Sprintf("删除节点分组 ID: %d", id))
+	cache.ClearAllSubscriptionCache()
+	utils.SuccessMessage(c, "删除成功")
+}
+
+// AdminGetPackageNodeGroups GET /admin/packages/:id/node-groups
+func AdminGetPackageNodeGroups(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.BadRequest(c, "无效的套餐ID")
+		return
+	}
+	utils.Success(c, gin.H{"group_ids": services.PackageNodeGroupIDs(database.GetDB(), uint(id))})
+}
+
+// AdminSetPackageNodeGroups PUT /admin/packages/:id/node-groups
+// 以请求中的分组列表整体替换套餐授权的分组；空列表表示只使用未分组节点。
+func AdminSetPackageNodeGroups(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.BadRequest(c, "无效的套餐ID")
+		return
+	}
+	var req struct {
+		GroupIDs []uint `json:"group_ids"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, "参数错误")
+		return
+	}
+	db := database.GetDB()
+	var pkg models.Package
+	if err := db.First(&pkg, id).Error; err != nil {
+		utils.NotFound(c, "套餐不存在")
+		return
+	}
+	seen := make(map[uint]bool, len(req.GroupIDs))
+	groupIDs := make([]uint, 0, len(req.GroupIDs))
+	for _, gid := range req.GroupIDs {
+		if !seen[gid] {
+			seen[gid] = true
+			groupIDs = append(groupIDs, gid)
+		}
+	}
+	req.GroupIDs = groupIDs
+	if len(req.GroupIDs) > 0 {
+		var count int64
+		db.Model(&models.NodeGroup{}).Where("id IN ?", req.GroupIDs).Count(&count)
+		if int(count) != len(req.GroupIDs) {
+			utils.BadRequest(c, "包含不存在的节点分组")
+			return
+		}
+	}
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("package_id = ?", pkg.ID).Delete(&models.PackageNodeGroup{}).Error; err != nil {
+			return err
+		}
+		for _, gid := range req.GroupIDs {
+			if err := tx.Create(&models.PackageNodeGroup{PackageID: pkg.ID, NodeGroupID: gid}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		utils.InternalError(c, "保存套餐节点分组失败")
+		return
+	}
+	utils.CreateAuditLog(c, "update_package_node_groups", "package", pkg.ID, fmt.Sprintf("设置套餐 %s 的节点分组: %v", pkg.Name, req.GroupIDs))
+	cache.ClearAllSubscriptionCache()
+	utils.Success(c, gin.H{"group_ids": req.GroupIDs})
+}
@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"cboard/v2/internal/database"
+	"cboard/v2/internal/models"
+	"cboard/v2/internal/services"
+	"cboard/v2/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ── 导入节点重命名规则 ──
+
+const nodeRenamePreviewLimit = 200
+
+func AdminGetNodeRenameRules(c *gin.Context) {
+	rules, err := services.LoadNodeRenameRules(database.GetDB())
+	if err != nil {
+		utils.InternalError(c, "加载重命名规则失败: "+err.Error())
+		return
+	}
+	utils.Success(c, gin.H{"rules": rules})
+}
+
+func AdminSaveNodeRenameRules(c *gin.Context) {
+	var req struct {
+		Rules []services.NodeRenameRule `json:"rules"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, "参数错误: "+err.Error())
+		return
+	}
+	if req.Rules == nil {
+		req.Rules = []services.NodeRenameRule{}
+	}
+	if _, err := services.CompileNodeRenameRules(req.Rules); err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+	if err := services.SaveNodeRenameRules(database.GetDB(), req.Rules); err != nil {
+		utils.InternalError(c, "保存重命名规则失败: "+err.Error())
+		return
+	}
+	utils.CreateAuditLog(c, "update_node_rename_rules", "node", 0, "更新节点重命名规则")
+	utils.SuccessMessage(c, "重命名规则已保存，下次更新或导入节点时生效")
+}
+
+// AdminPreviewNodeRenameRules shows the effect of the given (or saved) rules on sample names;
+// without names it uses the upstream names of the current auto-imported nodes (see services.PreviewAutoNodeRename).
+func AdminPreviewNodeRenameRules(c *gin.Context) {
+	var req struct {
+		Rules []services.NodeRenameRule `json:"rules"`
+		Names []string                  `json:"names"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, "参数错误: "+err.Error())
+		return
+	}
+	db := database.GetDB()
+	if req.Rules == nil {
+		rules, err := services.LoadNodeRenameRules(db)
+		if err != nil {
+			utils.InternalError(c, "加载重命名规则失败: "+err.Error())
+			return
+		}
+		req.Rules = rules
+	}
+	renamer, err := services.CompileNodeRenameRules(req.Rules)
+	if err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	var before, after []string
+	if len(req.Names) > 0 {
+		if len(req.Names) > nodeRenamePreviewLimit {
+			req.Names = req.Names[:nodeRenamePreviewLimit]
+		}
+		nodes := make([]models.Node, 0, len(req.Names))
+		for _, name := range req.Names {
+			nodes = append(nodes, models.Node{Name: name, Region: services.DetectRegion(name)})
+			before = append(before, name)
+		}
+		for _, node := range renamer.Apply(nodes) {
+			after = append(after, node.Name)
+		}
+	} else {
+		before, after = services.PreviewAutoNodeRename(db, renamer, nodeRenamePreviewLimit)
+	}
+
+	items := make([]gin.H, len(before))
+	for i := range before {
+		items[i] = gin.H{"before": before[i], "after": after[i]}
+	}
+	utils.Success(c, gin.H{"items": items})
+}
@@ -123,13 +123,18 @@ func ListOrders(c *gin.Context) {
 func CreateOrder(c *gin.Context) {
 	userID := c.GetUint("user_id")
 	var req struct {
-		PackageID  uint   `json:"package_id" binding:"required"`
-		CouponCode string `json:"coupon_code"`
+		PackageID       uint   `json:"package_id" binding:"required"`
+		CouponCode      string `json:"coupon_code"`
+		SubscriptionID  *uint  `json:"subscription_id"`  // 续期指定订阅，缺省为主订阅
+		NewSubscription bool   `json:"new_subscription"` // 购买一个独立的新订阅
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
 		utils.BadRequest(c, "参数错误")
 		return
 	}
+	if !validateOrderSubscriptionTarget(c, userID, req.SubscriptionID, req.NewSubscription) {
+		return
+	}
 	db := database.GetDB()
 	var pkg models.Package
 	if err := db.First(&pkg, req.PackageID).Error; err != nil {
@@ -161,15 +166,17 @@ func CreateOrder(c *gin.Context) {
 	orderNo := fmt.Sprintf("ORD%d%s", time.Now().Unix(), utils.GenerateRandomString(6))
 	expireTime := time.Now().Add(30 * time.Minute)
 	order := models.Order{
-		OrderNo:        orderNo,
-		UserID:         userID,
-		PackageID:      req.PackageID,
-		Amount:         amount,
-		Status:         "pending",
-		CouponID:       couponID,
-		DiscountAmount: &discountAmount,
-		FinalAmount:    &finalAmount,
-		ExpireTime:     &expireTime,
+		OrderNo:         orderNo,
+		UserID:          userID,
+		PackageID:       req.PackageID,
+		Amount:          amount,
+		Status:          "pending",
+		CouponID:        couponID,
+		DiscountAmount:  &discountAmount,
+		FinalAmount:     &finalAmount,
+		ExpireTime:      &expireTime,
+		SubscriptionID:  req.SubscriptionID,
+		NewSubscription: req.NewSubscription,
 	}
 
 	// 使用事务确保订单创建和优惠券使用的原子性
@@ -306,6 +313,9 @@ func PayOrder(c *gin.Context) {
 		// 创建或续期订阅
 		var deviceLimit int
 		var durationDays int
+		var trafficQuota int64
+		var speedLimit int
+		var trafficResetMode string
 		var pkgName string
 
 		isUpgradeOrder := false
@@ -343,23 +353,43 @@ func PayOrder(c *gin.Context) {
 			}
 			deviceLimit = pkg.DeviceLimit
 			durationDays = pkg.DurationDays
+			trafficQuota = services.TrafficGBToBytes(pkg.TrafficGB)
+			speedLimit = pkg.SpeedLimit
+			trafficResetMode = pkg.TrafficResetMode
 			pkgName = pkg.Name
 		}
 
+		target, err := services.ResolveOrderSubscription(tx, &order)
+		if err != nil {
+			tx.Rollback()
+			utils.BadRequest(c, "订单关联的订阅不存在")
+			return
+		}
 		var sub models.Subscription
-		if err := tx.Where("user_id = ?", userID).First(&sub).Error; err != nil {
+		if target == nil {
 			if isUpgradeOrder {
 				tx.Rollback()
 				utils.BadRequest(c, "升级订单需要已有订阅")
 				return
 			}
+			if order.NewSubscription {
+				if err := services.CheckSubscriptionQuota(tx, userID); err != nil {
+					tx.Rollback()
+					utils.BadRequest(c, err.Error())
+					return
+				}
+			}
 			sub = models.Subscription{
-				UserID:          userID,
-				SubscriptionURL: utils.GenerateHexToken(),
-				DeviceLimit:     deviceLimit,
-				IsActive:        true,
-				Status:          "active",
-				ExpireTime:      time.Now().AddDate(0, 0, durationDays),
+				UserID:           userID,
+				SubscriptionURL:  utils.GenerateHexToken(),
+				DeviceLimit:      deviceLimit,
+				TrafficQuota:     trafficQuota,
+				SpeedLimit:       speedLimit,
+				TrafficResetMode: services.NormalizeTrafficResetMode(trafficResetMode),
+				TrafficResetDay:  time.Now().Day(),
+				IsActive:         true,
+				Status:           "active",
+				ExpireTime:       time.Now().AddDate(0, 0, durationDays),
 			}
 			if order.PackageID > 0 {
 				pkgID := int64(order.PackageID)
@@ -371,6 +401,7 @@ func PayOrder(c *gin.Context) {
 				return
 			}
 		} else {
+			sub = *target
 			if isUpgradeOrder {
 				pkgName = fmt.Sprintf("订阅升级: +%d设备", upgradeAddDevices)
 				if upgradeExtendMonths > 0 {
@@ -381,12 +412,12 @@ func PayOrder(c *gin.Context) {
 				if upgradeExtendMonths > 0 {
 					newExpire = newExpire.AddDate(0, upgradeExtendMonths, 0)
 				}
-				if err := tx.Model(&sub).Updates(map[string]interface{}{
+				if err := tx.Model(&sub).Updates(services.PreservePauseState(&sub, map[string]interface{}{
 					"device_limit": newLimit,
 					"expire_time":  newExpire,
 					"is_active":    true,
 					"status":       "active",
-				}).Error; err != nil {
+				})).Error; err != nil {
 					tx.Rollback()
 					utils.InternalError(c, "订阅升级失败")
 					return
@@ -398,22 +429,31 @@ func PayOrder(c *gin.Context) {
 				}
 				newExpire = newExpire.AddDate(0, 0, durationDays)
 				updates := map[string]interface{}{
-					"device_limit": deviceLimit,
-					"expire_time":  newExpire,
-					"is_active":    true,
-					"status":       "active",
+					"device_limit":       deviceLimit,
+					"expire_time":        newExpire,
+					"is_active":          true,
+					"status":             "active",
+					"traffic_quota":      trafficQuota,
+					"speed_limit":        speedLimit,
+					"upload_traffic":     0,
+					"download_traffic":   0,
+					"traffic_reset_mode": services.NormalizeTrafficResetMode(trafficResetMode),
+					"traffic_reset_day":  time.Now().Day(),
+					"last_traffic_reset": time.Now(),
+					"traffic_exhausted":  false,
 				}
 				if order.PackageID > 0 {
 					pkgID := int64(order.PackageID)
 					updates["package_id"] = &pkgID
 				}
-				if err := tx.Model(&sub).Updates(updates).Error; err != nil {
+				if err := tx.Model(&sub).Updates(services.PreservePauseState(&sub, updates)).Error; err != nil {
 					tx.Rollback()
 					utils.InternalError(c, "续期订阅失败")
 					return
 				}
 			}
 		}
+		services.BindOrderSubscription(tx, &order, sub.ID)
 		if err := tx.Commit().Error; err != nil {
 			utils.InternalError(c, "支付事务提交失败")
 			return
@@ -429,8 +469,7 @@ func PayOrder(c *gin.Context) {
 		utils.LogOrder("[BalancePay] 扣款成功: user_id=%d before=%.2f amount=%.2f after=%.2f order_no=%s",
 			userID, freshUser.Balance, payAmount, freshUser.Balance-payAmount, orderNo)
 		var subURL string
-		var userSub models.Subscription
-		if database.GetDB().Where("user_id = ?", userID).First(&userSub).Error == nil {
+		{
 			settings := utils.GetSettings("site_url", "domain_name")
 			siteURL := settings["site_url"]
 			if siteURL == "" {
@@ -440,7 +479,7 @@ func PayOrder(c *gin.Context) {
 				siteURL = "https://" + siteURL
 			}
 			siteURL = strings.TrimRight(siteURL, "/")
-			subURL = siteURL + "/api/v1/subscribe/" + userSub.SubscriptionURL
+			subURL = siteURL + "/api/v1/client/subscribe?token=" + services.SubscriptionLinkToken(&sub, "")
 		}
 		emailSubject, emailBody := services.RenderEmail("payment_success", map[string]string{
 			"username": notifyUser.Username, "order_no": orderNo, "amount": payAmountStr, "package_name": pkgName, "subscription_url": subURL,
@@ -530,17 +569,41 @@ func GetOrderStatus(c *gin.Context) {
 	utils.Success(c, result)
 }
 
+// validateOrderSubscriptionTarget checks the subscription an order will renew, or the quota for a new one.
+func validateOrderSubscriptionTarget(c *gin.Context, userID uint, subID *uint, newSubscription bool) bool {
+	db := database.GetDB()
+	if newSubscription {
+		if err := services.CheckSubscriptionQuota(db, userID); err != nil {
+			utils.BadRequest(c, err.Error())
+			return false
+		}
+		return true
+	}
+	if subID != nil {
+		if _, err := services.FindUserSubscription(db, userID, *subID); err != nil {
+			utils.NotFound(c, "订阅不存在")
+			return false
+		}
+	}
+	return true
+}
+
 // CreateCustomOrder POST /orders/custom
 func CreateCustomOrder(c *gin.Context) {
 	var req struct {
-		Devices    int    `json:"devices" binding:"required,min=1"`
-		Months     int    `json:"months" binding:"required,min=1"`
-		CouponCode string `json:"coupon_code"`
+		Devices         int    `json:"devices" binding:"required,min=1"`
+		Months          int    `json:"months" binding:"required,min=1"`
+		CouponCode      string `json:"coupon_code"`
+		SubscriptionID  *uint  `json:"subscription_id"`
+		NewSubscription bool   `json:"new_subscription"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
 		utils.BadRequest(c, "参数错误")
 		return
 	}
+	if !validateOrderSubscriptionTarget(c, c.GetUint("user_id"), req.SubscriptionID, req.NewSubscription) {
+		return
+	}
 
 	if !utils.IsBoolSetting("custom_package_enabled") {
 		utils.BadRequest(c, "自定义套餐功能未启用")
@@ -625,16 +688,18 @@ func CreateCustomOrder(c *gin.Context) {
 	expireTime := time.Now().Add(30 * time.Minute)
 	totalDiscount := (basePrice - finalPrice)
 	order := models.Order{
-		OrderNo:        orderNo,
-		UserID:         userID,
-		PackageID:      0,
-		Amount:         basePrice,
-		Status:         "pending",
-		CouponID:       couponID,
-		DiscountAmount: &totalDiscount,
-		FinalAmount:    &finalPrice,
-		ExpireTime:     &expireTime,
-		ExtraData:      &extraStr,
+		OrderNo:         orderNo,
+		UserID:          userID,
+		PackageID:       0,
+		Amount:          basePrice,
+		Status:          "pending",
+		CouponID:        couponID,
+		DiscountAmount:  &totalDiscount,
+		FinalAmount:     &finalPrice,
+		ExpireTime:      &expireTime,
+		ExtraData:       &extraStr,
+		SubscriptionID:  req.SubscriptionID,
+		NewSubscription: req.NewSubscription,
 	}
 	if err := db.Create(&order).Error; err != nil {
 		utils.InternalError(c, "创建订单失败")
@@ -695,19 +760,20 @@ func CreateCustomOrder(c *gin.Context) {
 func CalcUpgradePrice(c *gin.Context) {
 	userID := c.GetUint("user_id")
 	db := database.GetDB()
-	var sub models.Subscription
-	if err := db.Where("user_id = ?", userID).First(&sub).Error; err != nil {
-		utils.NotFound(c, "暂无有效订阅")
-		return
-	}
 	var req struct {
-		AddDevices   int `json:"add_devices" binding:"required,min=1"`
-		ExtendMonths int `json:"extend_months"` // 0 表示不续期
+		AddDevices     int  `json:"add_devices" binding:"required,min=1"`
+		ExtendMonths   int  `json:"extend_months"`   // 0 表示不续期
+		SubscriptionID uint `json:"subscription_id"` // 0 表示主订阅
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
 		utils.BadRequest(c, "参数错误")
 		return
 	}
+	sub, err := services.FindUserSubscription(db, userID, req.SubscriptionID)
+	if err != nil {
+		utils.NotFound(c, "暂无有效订阅")
+		return
+	}
 	// Remove restriction of multiples of 5
 
 	now := time.Now()
@@ -764,20 +830,21 @@ func CalcUpgradePrice(c *gin.Context) {
 func CreateUpgradeOrder(c *gin.Context) {
 	userID := c.GetUint("user_id")
 	db := database.GetDB()
-	var sub models.Subscription
-	if err := db.Where("user_id = ?", userID).First(&sub).Error; err != nil {
-		utils.NotFound(c, "暂无有效订阅")
-		return
-	}
 	var req struct {
-		AddDevices   int    `json:"add_devices" binding:"required,min=1"`
-		ExtendMonths int    `json:"extend_months"`
-		CouponCode   string `json:"coupon_code"`
+		AddDevices     int    `json:"add_devices" binding:"required,min=1"`
+		ExtendMonths   int    `json:"extend_months"`
+		CouponCode     string `json:"coupon_code"`
+		SubscriptionID uint   `json:"subscription_id"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
 		utils.BadRequest(c, "参数错误")
 		return
 	}
+	sub, err := services.FindUserSubscription(db, userID, req.SubscriptionID)
+	if err != nil {
+		utils.NotFound(c, "暂无有效订阅")
+		return
+	}
 	// Remove restriction of multiples of 5
 	if req.AddDevices > 100 {
 		utils.BadRequest(c, "单次最多增加 100 个设备")
@@ -862,6 +929,7 @@ func CreateUpgradeOrder(c *gin.Context) {
 		FinalAmount:    &finalPrice,
 		ExpireTime:     &expireTime,
 		ExtraData:      &extraStr,
+		SubscriptionID: &sub.ID,
 	}
 	if err := db.Create(&order).Error; err != nil {
 		utils.InternalError(c, "创建订单失败")
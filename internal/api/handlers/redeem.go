@@ -5,6 +5,7 @@ import (
 
 	"cboard/v2/internal/database"
 	"cboard/v2/internal/models"
+	"cboard/v2/internal/services"
 	"cboard/v2/internal/utils"
 
 	"github.com/gin-gonic/gin"
@@ -14,7 +15,8 @@ import (
 
 func RedeemCode(c *gin.Context) {
 	var req struct {
-		Code string `json:"code" binding:"required"`
+		Code           string `json:"code" binding:"required"`
+		SubscriptionID uint   `json:"subscription_id"` // 时长类卡密作用的订阅，缺省为主订阅
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
 		utils.BadRequest(c, "参数错误")
@@ -74,13 +76,20 @@ func RedeemCode(c *gin.Context) {
 		if code.Type == "duration" || code.Type == "package" {
 			// Duration: value = days to add; Package: use linked package
 			var sub models.Subscription
-			if err := tx.Where("user_id = ?", userID).First(&sub).Error; err != nil {
+			if target, err := services.FindUserSubscription(tx, userID, req.SubscriptionID); err == nil {
+				sub = *target
+			} else if req.SubscriptionID > 0 {
+				utils.NotFound(c, "订阅不存在")
+				return err
+			}
+			if sub.ID == 0 {
 				// Create new subscription
 				subURL := utils.GenerateHexToken()
 				sub = models.Subscription{
 					UserID:          userID,
 					SubscriptionURL: subURL,
 					DeviceLimit:     3,
+					TrafficResetDay: time.Now().Day(),
 					IsActive:        true,
 					Status:          "active",
 					ExpireTime:      time.Now().AddDate(0, 0, int(code.Value)),
@@ -91,6 +100,9 @@ func RedeemCode(c *gin.Context) {
 					var pkg models.Package
 					if tx.First(&pkg, *code.PackageID).Error == nil {
 						sub.DeviceLimit = pkg.DeviceLimit
+						sub.TrafficQuota = services.TrafficGBToBytes(pkg.TrafficGB)
+						sub.SpeedLimit = pkg.SpeedLimit
+						sub.TrafficResetMode = services.NormalizeTrafficResetMode(pkg.TrafficResetMode)
 						if code.Type == "package" {
 							sub.ExpireTime = time.Now().AddDate(0, 0, pkg.DurationDays)
 						}
@@ -101,10 +113,6 @@ func RedeemCode(c *gin.Context) {
 				}
 			} else {
 				// Extend existing subscription
-				newExpire := sub.ExpireTime
-				if newExpire.Before(time.Now()) {
-					newExpire = time.Now()
-				}
 				days := int(code.Value)
 				if code.Type == "package" && code.PackageID != nil {
 					var pkg models.Package
@@ -112,10 +120,7 @@ func RedeemCode(c *gin.Context) {
 						days = pkg.DurationDays
 					}
 				}
-				newExpire = newExpire.AddDate(0, 0, days)
-				if err := tx.Model(&sub).Updates(map[string]interface{}{
-					"expire_time": newExpire, "is_active": true, "status": "active",
-				}).Error; err != nil {
+				if _, err := services.ExtendSubscriptionDays(tx, &sub, days); err != nil {
 					return err
 				}
 			}
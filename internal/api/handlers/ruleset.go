@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"cboard/v2/internal/services"
+	"cboard/v2/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ==================== Rule Sets ====================
+
+var geositeContentTypes = map[string]string{
+	"clash":          "text/yaml; charset=utf-8",
+	"singbox-source": "application/json; charset=utf-8",
+}
+
+// GetGeositeRuleSet GET /api/v1/rulesets/geosite/:format/:category
+// 公开接口，供配置模板引用本站托管的规则集，如 /rulesets/geosite/clash/netflix、/rulesets/geosite/singbox-source/geolocation-!cn
+// （sing-box 为 source 格式 JSON，不是 .srs）。
+func GetGeositeRuleSet(c *gin.Context) {
+	category := c.Param("category")
+	format, supported := services.NormalizeGeositeFormat(c.Param("format"))
+	if !supported {
+		utils.BadRequest(c, "不支持的规则集格式，可选: "+strings.Join(services.GeositeFormats, ", "))
+		return
+	}
+	result, err := services.RenderGeositeRuleSet(category, format, c.Query("policy"))
+	if err != nil {
+		if errors.Is(err, services.ErrGeositeCategoryNotFound) {
+			utils.NotFound(c, err.Error())
+		} else {
+			utils.SysError("ruleset", "生成 geosite 规则集失败: "+err.Error())
+			utils.InternalError(c, "生成规则集失败")
+		}
+		return
+	}
+
+	contentType, ok := geositeContentTypes[format]
+	if !ok {
+		contentType = "text/plain; charset=utf-8"
+	}
+	c.Header("Content-Type", contentType)
+	c.Header("Cache-Control", "public, max-age=3600")
+	// ServeContent 处理 If-Modified-Since，客户端在 geosite.dat 未更新时得到 304
+	http.ServeContent(c.Writer, c.Request, category, services.GeositeModTime(), strings.NewReader(result))
+}
+
+// AdminListGeositeCategories GET /admin/rulesets/geosite
+// 列出 geosite.dat 中的分类及域名数量，便于编写模板时查找分类名。
+func AdminListGeositeCategories(c *gin.Context) {
+	categories, err := services.ListGeositeCategories()
+	if err != nil {
+		utils.InternalError(c, err.Error())
+		return
+	}
+	utils.Success(c, gin.H{
+		"categories": categories,
+		"formats":    services.GeositeFormats,
+		"url":        getSubscriptionBaseURL() + "/api/v1/rulesets/geosite/{format}/{category}",
+	})
+}
@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"cboard/v2/internal/database"
+	"cboard/v2/internal/models"
+	"cboard/v2/internal/services"
+	"cboard/v2/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ── 自建节点对接（XrayR / V2bX UniProxy 协议） ──
+// 响应体不使用 utils.Success 包装，保持与 UniProxy 节点端约定的原始 JSON 格式。
+
+func serverNode(c *gin.Context) *models.Node {
+	return c.MustGet("server_node").(*models.Node)
+}
+
+// serverJSONWithETag writes JSON and honours If-None-Match so agents can skip unchanged payloads.
+func serverJSONWithETag(c *gin.Context, data interface{}) {
+	body, err := json.Marshal(data)
+	if err != nil {
+		utils.InternalError(c, "序列化失败")
+		return
+	}
+	sum := sha1.Sum(body)
+	etag := `"` + hex.EncodeToString(sum[:]) + `"`
+	c.Header("ETag", etag)
+	if c.GetHeader("If-None-Match") == etag {
+		c.Status(http.StatusNotModified)
+		return
+	}
+	c.Data(http.StatusOK, "application/json; charset=utf-8", body)
+}
+
+func touchServerNode(node *models.Node, column string) {
+	database.GetDB().Model(&models.Node{}).Where("id = ?", node.ID).Updates(map[string]interface{}{
+		column:   time.Now(),
+		"status": "online",
+	})
+}
+
+// ServerNodeConfig GET /api/v1/server/UniProxy/config
+func ServerNodeConfig(c *gin.Context) {
+	node := serverNode(c)
+	cfg, err := services.BuildServerNodeConfig(node)
+	if err != nil {
+		utils.BadRequest(c, "节点配置无效: "+err.Error())
+		return
+	}
+	touchServerNode(node, "last_check_at")
+	serverJSONWithETag(c, cfg)
+}
+
+// ServerNodeUsers GET /api/v1/server/UniProxy/user
+func ServerNodeUsers(c *gin.Context) {
+	node := serverNode(c)
+	users, err := services.ListServerUsers(database.GetDB(), node)
+	if err != nil {
+		utils.InternalError(c, "获取用户列表失败")
+		return
+	}
+	touchServerNode(node, "last_check_at")
+	serverJSONWithETag(c, gin.H{"users": users})
+}
+
+// ServerNodePush POST /api/v1/server/UniProxy/push
+// Body: {"<user_id>": [upload_bytes, download_bytes], ...}
+// 上报流量按节点倍率折算后计入订阅。
+func ServerNodePush(c *gin.Context) {
+	node := serverNode(c)
+	rate := services.NodeRate(node)
+	var data map[string][]int64
+	if err := c.ShouldBindJSON(&data); err != nil {
+		utils.BadRequest(c, "参数错误: "+err.Error())
+		return
+	}
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		for uid, traffic := range data {
+			id, err := strconv.ParseUint(uid, 10, 64)
+			if err != nil || len(traffic) < 2 {
+				continue
+			}
+			if err := services.RecordSubscriptionTraffic(tx, uint(id),
+				services.ScaleTrafficByRate(traffic[0], rate), services.ScaleTrafficByRate(traffic[1], rate)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		utils.SysError("server_node", fmt.Sprintf("节点 %d 流量上报写入失败: %v", node.ID, err))
+		utils.InternalError(c, "流量写入失败")
+		return
+	}
+	touchServerNode(node, "last_push_at")
+	c.JSON(http.StatusOK, gin.H{"data": true})
+}
+
+// ServerNodeAlive POST /api/v1/server/UniProxy/alive
+// Body: {"<user_id>": ["ip_nodeid", ...], ...}
+func ServerNodeAlive(c *gin.Context) {
+	node := serverNode(c)
+	var data map[string][]string
+	if err := c.ShouldBindJSON(&data); err != nil {
+		utils.BadRequest(c, "参数错误: "+err.Error())
+		return
+	}
+	online := services.ReportServerAlive(node.ID, data)
+	database.GetDB().Model(&models.Node{}).Where("id = ?", node.ID).Update("online_users", online)
+	c.JSON(http.StatusOK, gin.H{"data": true})
+}
+
+// ServerNodeAliveList GET /api/v1/server/UniProxy/alivelist
+func ServerNodeAliveList(c *gin.Context) {
+	alive := make(map[string]int)
+	for uid, count := range services.ServerAliveCounts() {
+		alive[strconv.FormatUint(uint64(uid), 10)] = count
+	}
+	c.JSON(http.StatusOK, gin.H{"alive": alive})
+}
@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"cboard/v2/internal/database"
+	"cboard/v2/internal/models"
+	"cboard/v2/internal/services"
+	"cboard/v2/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ==================== Subscription Leak Detection ====================
+
+// AdminListLeakFlaggedSubscriptions GET /admin/subscriptions/leak-flagged
+// 列出被泄露检测标记的订阅；blocked=true 只看当前封禁中的订阅。检测策略通过系统设置 sub_leak_* 配置。
+func AdminListLeakFlaggedSubscriptions(c *gin.Context) {
+	db := database.GetDB()
+	p := utils.GetPagination(c)
+	query := db.Model(&models.Subscription{}).Where("leak_flagged_at IS NOT NULL")
+	if c.Query("blocked") == "true" {
+		query = query.Where("leak_blocked_until > ?", time.Now())
+	}
+	var total int64
+	query.Count(&total)
+	var subs []models.Subscription
+	query.Order("leak_flagged_at DESC").Offset(p.Offset()).Limit(p.PageSize).Find(&subs)
+
+	userIDs := make([]uint, 0, len(subs))
+	for _, sub := range subs {
+		userIDs = append(userIDs, sub.UserID)
+	}
+	userMap := make(map[uint]models.User)
+	if len(userIDs) > 0 {
+		var users []models.User
+		db.Select("id, email, username").Where("id IN ?", userIDs).Find(&users)
+		for _, u := range users {
+			userMap[u.ID] = u
+		}
+	}
+
+	items := make([]gin.H, 0, len(subs))
+	for _, sub := range subs {
+		user := userMap[sub.UserID]
+		items = append(items, gin.H{
+			"id":                 sub.ID,
+			"user_id":            sub.UserID,
+			"username":           user.Username,
+			"user_email":         user.Email,
+			"status":             sub.Status,
+			"expire_time":        sub.ExpireTime,
+			"current_devices":    sub.CurrentDevices,
+			"device_limit":       sub.DeviceLimit,
+			"leak_flagged_at":    sub.LeakFlaggedAt,
+			"leak_ip_count":      sub.LeakIPCount,
+			"leak_blocked_until": sub.LeakBlockedUntil,
+			"is_blocked":         services.IsLeakBlocked(&sub),
+		})
+	}
+
+	policy := services.GetLeakPolicy()
+	actions := make([]string, 0, len(policy.Actions))
+	for a := range policy.Actions {
+		actions = append(actions, a)
+	}
+	sort.Strings(actions)
+	utils.Success(c, gin.H{
+		"items":     items,
+		"total":     total,
+		"page":      p.Page,
+		"page_size": p.PageSize,
+		"policy": gin.H{
+			"ip_threshold": policy.Threshold,
+			"window_hours": int(policy.Window.Hours()),
+			"actions":      actions,
+			"block_hours":  int(policy.BlockDuration.Hours()),
+		},
+	})
+}
+
+// AdminClearSubscriptionLeak POST /admin/subscriptions/:id/leak-clear
+// 解除泄露标记与封禁，并清空当前窗口的 IP 统计。
+func AdminClearSubscriptionLeak(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.BadRequest(c, "无效的订阅ID")
+		return
+	}
+	db := database.GetDB()
+	var sub models.Subscription
+	if err := db.First(&sub, id).Error; err != nil {
+		utils.NotFound(c, "订阅不存在")
+		return
+	}
+	if err := services.ClearSubscriptionLeakFlag(db, sub.ID); err != nil {
+		utils.InternalError(c, "解除泄露标记失败")
+		return
+	}
+	adminID := c.GetUint("user_id")
+	utils.CreateSubscriptionLog(sub.ID, sub.UserID, "leak_cleared", "admin", &adminID, "管理员解除订阅泄露标记", nil, nil)
+	utils.CreateAuditLog(c, "clear_subscription_leak", "subscription", sub.ID, fmt.Sprintf("解除订阅泄露标记 (用户ID: %d)", sub.UserID))
+	utils.SuccessMessage(c, "已解除泄露标记")
+}
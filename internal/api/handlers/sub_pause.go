@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"cboard/v2/internal/database"
+	"cboard/v2/internal/services"
+	"cboard/v2/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ==================== Subscription Pause ====================
+
+// GetSubscriptionPauseInfo GET /subscriptions/pause
+// 返回订阅的暂停状态以及本年度剩余的暂停次数与时长。
+func GetSubscriptionPauseInfo(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	db := database.GetDB()
+	sub, err := userSubscriptionFromQuery(c, db, userID)
+	if err != nil {
+		utils.NotFound(c, "暂无订阅")
+		return
+	}
+	policy := services.GetPausePolicy()
+	usage := services.GetPauseUsage(db, sub.ID)
+	remainingCount := policy.MaxCount - usage.Count
+	if remainingCount < 0 {
+		remainingCount = 0
+	}
+	utils.Success(c, gin.H{
+		"subscription_id": sub.ID,
+		"paused":          services.IsSubscriptionPaused(sub),
+		"paused_at":       sub.PausedAt,
+		"pause_resume_at": sub.PauseResumeAt,
+		"enabled":         policy.MaxCount > 0,
+		"max_count":       policy.MaxCount,
+		"max_days":        int(policy.MaxDuration.Hours() / 24),
+		"used_count":      usage.Count,
+		"used_hours":      int(usage.Used.Hours()),
+		"remaining_count": remainingCount,
+		"remaining_hours": int(usage.Remaining(policy).Hours()),
+	})
+}
+
+// PauseUserSubscription POST /subscriptions/pause
+func PauseUserSubscription(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	db := database.GetDB()
+	sub, err := userSubscriptionFromQuery(c, db, userID)
+	if err != nil {
+		utils.NotFound(c, "暂无订阅")
+		return
+	}
+	if err := services.PauseSubscription(db, sub, "user", &userID); err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+	utils.Success(c, gin.H{"paused_at": sub.PausedAt, "pause_resume_at": sub.PauseResumeAt})
+}
+
+// ResumeUserSubscription POST /subscriptions/resume
+func ResumeUserSubscription(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	db := database.GetDB()
+	sub, err := userSubscriptionFromQuery(c, db, userID)
+	if err != nil {
+		utils.NotFound(c, "暂无订阅")
+		return
+	}
+	if err := services.ResumeSubscription(db, sub, "user", &userID); err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+	utils.Success(c, gin.H{"expire_time": sub.ExpireTime})
+}
@@ -0,0 +1,205 @@
+package handlers
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"cboard/v2/internal/database"
+	"cboard/v2/internal/models"
+	"cboard/v2/internal/services"
+	"cboard/v2/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ==================== Subscription Profiles ====================
+
+type subscriptionProfileRequest struct {
+	Name          string   `json:"name" binding:"required"`
+	Regions       []string `json:"regions"`
+	Protocols     []string `json:"protocols"`
+	ProtocolOrder []string `json:"protocol_order"`
+	Include       string   `json:"include"`
+	Exclude       string   `json:"exclude"`
+	SortBy        string   `json:"sort_by"`
+	HideInfoNodes bool     `json:"hide_info_nodes"`
+}
+
+func (req *subscriptionProfileRequest) applyTo(p *models.SubscriptionProfile) {
+	p.Name = strings.TrimSpace(req.Name)
+	p.Regions = services.EncodeSubscriptionProfileList(req.Regions)
+	p.Protocols = services.EncodeSubscriptionProfileList(req.Protocols)
+	p.ProtocolOrder = services.EncodeSubscriptionProfileList(req.ProtocolOrder)
+	p.Include = req.Include
+	p.Exclude = req.Exclude
+	p.SortBy = req.SortBy
+	p.HideInfoNodes = req.HideInfoNodes
+}
+
+// subscriptionProfileView returns the profile with decoded lists and its derived subscription links.
+func subscriptionProfileView(p *models.SubscriptionProfile, sub *models.Subscription, baseURL string) gin.H {
+	lists := services.DecodeSubscriptionProfileLists(p)
+	urls := buildSubscriptionURLs(baseURL, sub, "")
+	suffix := "&profile=" + strconv.FormatUint(uint64(p.ID), 10)
+	for k, v := range urls {
+		if s, _ := v.(string); s != "" {
+			urls[k] = s + suffix
+		}
+	}
+	return gin.H{
+		"id":              p.ID,
+		"subscription_id": p.SubscriptionID,
+		"name":            p.Name,
+		"regions":         lists.Regions,
+		"protocols":       lists.Protocols,
+		"protocol_order":  lists.ProtocolOrder,
+		"include":         p.Include,
+		"exclude":         p.Exclude,
+		"sort_by":         p.SortBy,
+		"hide_info_nodes": p.HideInfoNodes,
+		"urls":            urls,
+		"created_at":      p.CreatedAt,
+		"updated_at":      p.UpdatedAt,
+	}
+}
+
+// loadUserSubscriptionProfile loads a profile owned by the current user, writing the error response itself.
+func loadUserSubscriptionProfile(c *gin.Context, db *gorm.DB, userID uint) (*models.SubscriptionProfile, *models.Subscription, bool) {
+	var profile models.SubscriptionProfile
+	if err := db.Where("id = ? AND user_id = ?", c.Param("id"), userID).First(&profile).Error; err != nil {
+		utils.NotFound(c, "方案不存在")
+		return nil, nil, false
+	}
+	sub, err := services.FindUserSubscription(db, userID, profile.SubscriptionID)
+	if err != nil {
+		utils.NotFound(c, "订阅不存在")
+		return nil, nil, false
+	}
+	return &profile, sub, true
+}
+
+// ListSubscriptionProfiles GET /subscriptions/profiles?subscription_id=
+// 返回订阅的筛选方案，以及该订阅当前可选的地区与协议。
+func ListSubscriptionProfiles(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+	db := database.GetDB()
+	sub, err := userSubscriptionFromQuery(c, db, userID)
+	if err != nil {
+		utils.NotFound(c, "暂无订阅")
+		return
+	}
+	var profiles []models.SubscriptionProfile
+	db.Where("subscription_id = ? AND user_id = ?", sub.ID, userID).Order("id ASC").Find(&profiles)
+	baseURL := getSubscriptionBaseURL()
+	items := make([]gin.H, 0, len(profiles))
+	for i := range profiles {
+		items = append(items, subscriptionProfileView(&profiles[i], sub, baseURL))
+	}
+
+	customNodes, hasDedicated, _ := fetchUserCustomNodes(db, userID, sub.ExpireTime)
+	nodes := customNodes
+	if !hasDedicated {
+		var publicNodes []models.Node
+		db.Scopes(services.SubscriptionNodeScope(db, sub)).Scopes(services.LiveNodeScope).Where("status = ?", "online").Find(&publicNodes)
+		nodes = append(nodes, publicNodes...)
+	}
+	regionSet, protocolSet := make(map[string]bool), make(map[string]bool)
+	for _, n := range nodes {
+		regionSet[services.NodeRegion(n)] = true
+		protocolSet[n.Type] = true
+	}
+	regions, protocols := make([]string, 0, len(regionSet)), make([]string, 0, len(protocolSet))
+	for r := range regionSet {
+		regions = append(regions, r)
+	}
+	for p := range protocolSet {
+		protocols = append(protocols, p)
+	}
+	sort.Strings(regions)
+	sort.Strings(protocols)
+
+	utils.Success(c, gin.H{
+		"items":        items,
+		"regions":      regions,
+		"protocols":    protocols,
+		"sorts":        services.SubscriptionProfileSorts,
+		"max_profiles": utils.GetIntSetting("max_subscription_profiles", 10),
+	})
+}
+
+// CreateSubscriptionProfile POST /subscriptions/profiles?subscription_id=
+func CreateSubscriptionProfile(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+	var req subscriptionProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, "参数错误")
+		return
+	}
+	db := database.GetDB()
+	sub, err := userSubscriptionFromQuery(c, db, userID)
+	if err != nil {
+		utils.NotFound(c, "暂无订阅")
+		return
+	}
+	var count int64
+	db.Model(&models.SubscriptionProfile{}).Where("subscription_id = ?", sub.ID).Count(&count)
+	if limit := utils.GetIntSetting("max_subscription_profiles", 10); int(count) >= limit {
+		utils.BadRequest(c, "每个订阅最多保存 "+strconv.Itoa(limit)+" 个方案")
+		return
+	}
+
+	profile := models.SubscriptionProfile{UserID: userID, SubscriptionID: sub.ID}
+	req.applyTo(&profile)
+	if err := services.ValidateSubscriptionProfile(&profile); err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+	if err := db.Create(&profile).Error; err != nil {
+		utils.InternalError(c, "保存方案失败")
+		return
+	}
+	utils.Success(c, subscriptionProfileView(&profile, sub, getSubscriptionBaseURL()))
+}
+
+// UpdateSubscriptionProfile PUT /subscriptions/profiles/:id
+func UpdateSubscriptionProfile(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+	var req subscriptionProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, "参数错误")
+		return
+	}
+	db := database.GetDB()
+	profile, sub, ok := loadUserSubscriptionProfile(c, db, userID)
+	if !ok {
+		return
+	}
+	req.applyTo(profile)
+	if err := services.ValidateSubscriptionProfile(profile); err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+	// Select("*") 以便清空的列表与关闭的开关也能写入
+	if err := db.Model(profile).Select("*").Omit("id", "user_id", "subscription_id", "created_at").Updates(profile).Error; err != nil {
+		utils.InternalError(c, "保存方案失败")
+		return
+	}
+	utils.Success(c, subscriptionProfileView(profile, sub, getSubscriptionBaseURL()))
+}
+
+// DeleteSubscriptionProfile DELETE /subscriptions/profiles/:id
+func DeleteSubscriptionProfile(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+	db := database.GetDB()
+	profile, _, ok := loadUserSubscriptionProfile(c, db, userID)
+	if !ok {
+		return
+	}
+	if err := db.Delete(profile).Error; err != nil {
+		utils.InternalError(c, "删除方案失败")
+		return
+	}
+	utils.SuccessMessage(c, "方案已删除")
+}
@@ -8,6 +8,7 @@ import (
 	"math"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -32,8 +33,38 @@ const (
 	subStatusExpired
 	subStatusInactive
 	subStatusDeviceOverLimit
+	subStatusTrafficExhausted
+	subStatusLeakBlocked
+	subStatusPaused
+	subStatusLinkExpired
 )
 
+// String returns the value stored in subscription_access_logs.status
+func (s subscriptionStatus) String() string {
+	switch s {
+	case subStatusOK:
+		return "ok"
+	case subStatusNotFound:
+		return "not_found"
+	case subStatusExpired:
+		return "expired"
+	case subStatusInactive:
+		return "inactive"
+	case subStatusDeviceOverLimit:
+		return "device_over_limit"
+	case subStatusTrafficExhausted:
+		return "traffic_exhausted"
+	case subStatusLeakBlocked:
+		return "leak_blocked"
+	case subStatusPaused:
+		return "paused"
+	case subStatusLinkExpired:
+		return "link_expired"
+	default:
+		return "unknown"
+	}
+}
+
 var errDeviceLimitReached = errors.New("device limit reached")
 
 var (
@@ -135,6 +166,9 @@ func buildSubscriptionContext(c *gin.Context) *subscriptionContext {
 	// 旧：/api/v1/sub/:url（路径参数）
 	// 新：/api/v1/client/subscribe?token=TOKEN（查询参数，参考业界标准风格）
 	url := c.Param("url")
+	if url == "" {
+		url = c.Param("token") // /api/v1/client/provider/:token/:region
+	}
 	if url == "" {
 		url = c.Query("token")
 	}
@@ -163,16 +197,44 @@ func buildSubscriptionContext(c *gin.Context) *subscriptionContext {
 	ctx := &subscriptionContext{SiteURL: siteURL, SupportContact: supportContact, ClientInfo: clientInfo}
 
 	var sub models.Subscription
-	if err := db.Where("subscription_url = ?", url).First(&sub).Error; err != nil {
+	var signedDeviceID string
+	if services.IsSignedToken(url) {
+		// 签名链接：校验签名后再检查有效期，过期链接返回提示节点
+		link, err := services.ParseSignedToken(url)
+		if err == nil {
+			if err = db.First(&sub, link.SubscriptionID).Error; err == nil {
+				err = services.VerifySignedLink(link, &sub)
+			}
+		}
+		if err == services.ErrSignedLinkExpired {
+			ctx.Sub = &sub
+			ctx.Status = subStatusLinkExpired
+			return ctx
+		}
+		if err != nil {
+			utils.SysError("subscription", fmt.Sprintf("订阅签名链接校验失败: %s from IP: %s", url, clientIP))
+			ctx.Status = subStatusNotFound
+			return ctx
+		}
+		signedDeviceID = link.DeviceID
+	} else if err := db.Where("subscription_url = ?", url).First(&sub).Error; err != nil {
 		// 记录失败的订阅访问（用于检测枚举攻击）
 		utils.SysError("subscription", fmt.Sprintf("订阅地址不存在访问尝试: %s from IP: %s", url, clientIP))
 		ctx.Status = subStatusNotFound
 		return ctx
+	} else if !services.LegacyTokenAllowed() {
+		ctx.Sub = &sub
+		ctx.Status = subStatusLinkExpired
+		return ctx
 	}
 	ctx.Sub = &sub
 	ctx.DeviceLimit = sub.DeviceLimit
 	ctx.CurrentDevices = sub.CurrentDevices
 
+	if services.IsSubscriptionPaused(&sub) {
+		ctx.Status = subStatusPaused
+		return ctx
+	}
 	if !sub.IsActive || sub.Status != "active" {
 		ctx.Status = subStatusInactive
 		return ctx
@@ -181,22 +243,25 @@ func buildSubscriptionContext(c *gin.Context) *subscriptionContext {
 		ctx.Status = subStatusExpired
 		return ctx
 	}
+	if services.IsTrafficExhausted(&sub) {
+		ctx.Status = subStatusTrafficExhausted
+		return ctx
+	}
 
-	// 防剥离滥用/防合并出售检测 (按天统计独立 IP 数)
-	r := database.GetRedis()
-	if r != nil && !clientInfo.IsBrowser {
-		today := time.Now().Format("2006-01-02")
-		ipSetKey := fmt.Sprintf("sub_ips:%d:%s", sub.ID, today)
-		r.SAdd(context.Background(), ipSetKey, clientIP)
-		r.Expire(context.Background(), ipSetKey, 48*time.Hour)
-		ipCount := r.SCard(context.Background(), ipSetKey).Val()
-
-		// 如果单日超过 15 个不同的 IP，可能存在订阅泄露/合租贩卖
-		if ipCount > 15 {
-			utils.SysError("security_alert", fmt.Sprintf("【防滥用警报】订阅 ID: %d 疑似泄露！今日已被 %d 个不同IP拉取。", sub.ID, ipCount))
-			// 若需做到极度严格，可在此处拦截:
-			// ctx.Status = subStatusDeviceOverLimit
-			// return ctx
+	if services.IsLeakBlocked(&sub) {
+		ctx.Status = subStatusLeakBlocked
+		return ctx
+	}
+
+	// 防剥离滥用/防合并出售检测：按检测窗口统计独立 IP 数，超过阈值按后台策略处理
+	if !clientInfo.IsBrowser {
+		policy := services.GetLeakPolicy()
+		if policy.Threshold > 0 {
+			ipCount := services.TrackSubscriptionIP(db, sub.ID, clientIP, policy.Window)
+			if ipCount > int64(policy.Threshold) && services.HandleSubscriptionLeak(db, &sub, ipCount, policy) {
+				ctx.Status = subStatusLeakBlocked
+				return ctx
+			}
 		}
 	}
 
@@ -207,7 +272,7 @@ func buildSubscriptionContext(c *gin.Context) *subscriptionContext {
 		if hasDedicated {
 			nodes = customNodes
 		} else {
-			db.Where("is_active = ? AND status = ?", true, "online").Order("order_index ASC").Find(&nodes)
+			db.Scopes(services.SubscriptionNodeScope(db, &sub)).Scopes(services.LiveNodeScope).Where("status = ?", "online").Order("order_index ASC").Find(&nodes)
 			nodes = append(customNodes, nodes...)
 		}
 		ctx.HasDedicatedOnly = hasDedicated
@@ -226,7 +291,7 @@ func buildSubscriptionContext(c *gin.Context) *subscriptionContext {
 		ctx.Nodes = customNodes
 	} else {
 		var publicNodes []models.Node
-		db.Where("is_active = ? AND status = ?", true, "online").Order("order_index ASC").Find(&publicNodes)
+		db.Scopes(services.SubscriptionNodeScope(db, &sub)).Scopes(services.LiveNodeScope).Where("status = ?", "online").Order("order_index ASC").Find(&publicNodes)
 		ctx.Nodes = append(customNodes, publicNodes...)
 	}
 	ctx.HasUnlimitedDevices = hasUnlimited
@@ -238,6 +303,9 @@ func buildSubscriptionContext(c *gin.Context) *subscriptionContext {
 	if appDeviceID == "" {
 		appDeviceID = strings.TrimSpace(c.Query("app_device_id"))
 	}
+	if appDeviceID == "" {
+		appDeviceID = signedDeviceID
+	}
 	fingerprint := services.GenerateDeviceFingerprint(ua, ip)
 	if appDeviceID != "" {
 		fingerprint = services.GenerateDeviceFingerprint("MoneyFly-App-Device:"+appDeviceID, "")
@@ -482,6 +550,10 @@ func getInfoNodes(ctx *subscriptionContext) []models.Node {
 		}
 		infoNodes = append(infoNodes, createInfoNode("⏰ 到期: "+expireStr))
 		infoNodes = append(infoNodes, createInfoNode(fmt.Sprintf("📱 设备: %d/%d", ctx.CurrentDevices, ctx.DeviceLimit)))
+		if ctx.Sub.TrafficQuota > 0 {
+			infoNodes = append(infoNodes, createInfoNode(fmt.Sprintf("📊 流量: %s/%s",
+				services.FormatTrafficBytes(ctx.Sub.UploadTraffic+ctx.Sub.DownloadTraffic), services.FormatTrafficBytes(ctx.Sub.TrafficQuota))))
+		}
 	}
 
 	if ctx.SupportContact != "" {
@@ -516,6 +588,33 @@ func getErrorNodes(ctx *subscriptionContext) []models.Node {
 	case subStatusDeviceOverLimit:
 		reason = "设备数量超限"
 		solution = fmt.Sprintf("当前设备 %d/%d，请在官网删除不使用的设备", ctx.CurrentDevices, ctx.DeviceLimit)
+	case subStatusLeakBlocked:
+		reason = "订阅疑似泄露，已被临时封禁"
+		solution = "请登录官网重置订阅地址后重新导入"
+		if ctx.Sub != nil && ctx.Sub.LeakBlockedUntil != nil {
+			solution = fmt.Sprintf("请登录官网重置订阅地址，或等待至 %s 自动解除", ctx.Sub.LeakBlockedUntil.Format("2006-01-02 15:04"))
+		}
+	case subStatusPaused:
+		reason = "订阅已暂停"
+		solution = "请登录官网恢复订阅"
+		if ctx.Sub != nil && ctx.Sub.PauseResumeAt != nil {
+			solution = fmt.Sprintf("请登录官网恢复订阅，最迟 %s 自动恢复", ctx.Sub.PauseResumeAt.Format("2006-01-02 15:04"))
+		}
+	case subStatusLinkExpired:
+		reason = "订阅链接已过期"
+		solution = "请登录官网复制最新的订阅链接并重新导入"
+	case subStatusTrafficExhausted:
+		reason = "流量已用尽"
+		solution = "请前往官网续费"
+		if ctx.Sub != nil {
+			used := services.FormatTrafficBytes(ctx.Sub.UploadTraffic + ctx.Sub.DownloadTraffic)
+			quota := services.FormatTrafficBytes(ctx.Sub.TrafficQuota)
+			if next := services.SubscriptionNextTrafficReset(ctx.Sub); !next.IsZero() {
+				solution = fmt.Sprintf("已用 %s/%s，%s 重置或前往官网续费", used, quota, next.Format("2006-01-02"))
+			} else {
+				solution = fmt.Sprintf("已用 %s/%s，请前往官网续费", used, quota)
+			}
+		}
 	}
 
 	nodes := []models.Node{
@@ -539,6 +638,14 @@ func generateSubscriptionName(ctx *subscriptionContext) string {
 			return "订阅已失效"
 		case subStatusDeviceOverLimit:
 			return "设备超限"
+		case subStatusTrafficExhausted:
+			return "流量已用尽"
+		case subStatusLeakBlocked:
+			return "订阅已封禁"
+		case subStatusPaused:
+			return "订阅已暂停"
+		case subStatusLinkExpired:
+			return "订阅链接已过期"
 		case subStatusNotFound:
 			return "订阅不存在"
 		default:
@@ -565,6 +672,7 @@ func generateSubscriptionName(ctx *subscriptionContext) string {
 func GetSubscription(c *gin.Context) {
 	ctx := buildSubscriptionContext(c)
 	excludedProtocols := parseExcludedProtocols(c.Query("exclude"))
+	profile := subscriptionProfileFromQuery(c, ctx)
 
 	// 支持 ?format= 和 ?type= 两种参数名（兼容不同客户端）
 	subType := c.Query("format")
@@ -583,12 +691,17 @@ func GetSubscription(c *gin.Context) {
 
 	// Determine output format
 	useStash := subType == "stash"
-	useClash := subType == "clash" || subType == "classmeta" || subType == "mihomo"
+	useClash := subType == "clash" || subType == "clashmeta" || subType == "classmeta" || subType == "mihomo"
 	useSurge := subType == "surge"
+	useSurfboard := subType == "surfboard"
+	useEgern := subType == "egern"
 	useQuantumultX := subType == "quantumult" || subType == "quantumultx"
 	useLoon := subType == "loon"
 	useSingBox := subType == "singbox" || subType == "sing-box"
-	useShadowrocket := subType == "shadowrocket"
+	useXray := subType == "xray"
+	// proxy-providers 模式仅用于正常状态的 Clash 订阅，异常状态仍直接下发提示节点
+	useProviders := useClash && ctx.Status == subStatusOK && clashProvidersRequested(c)
+	customRules, customRulesCacheKey := subscriptionCustomRules(ctx, subType)
 
 	// 尝试从 Redis 缓存获取下发内容 (仅当订阅状态正常时缓存)
 	var cacheKey string
@@ -598,17 +711,22 @@ func GetSubscription(c *gin.Context) {
 		if len(excludedProtocols) > 0 {
 			cacheKey = fmt.Sprintf("%s:exclude:%s", cacheKey, strings.Join(excludedProtocols, ","))
 		}
+		if useProviders {
+			// provider 地址中带有请求所用的令牌（签名链接含设备 ID）和域名，地址不同的请求不能共用缓存
+			cacheKey += ":providers:" + utils.SHA256Hash(clashProviderOptions(c, excludedProtocols, profile).URL(""))[:16]
+		}
+		cacheKey += subscriptionProfileCacheKey(profile) + customRulesCacheKey
 		if cachedBody, err := r.Get(c.Request.Context(), cacheKey).Result(); err == nil && cachedBody != "" {
 			subscriptionName := generateSubscriptionName(ctx)
 			encodedName := url.QueryEscape(subscriptionName)
 
-			if useStash || useClash {
+			if useStash || useClash || useEgern {
 				c.Header("Content-Type", "text/yaml; charset=utf-8")
 				c.Header("Content-Disposition", fmt.Sprintf("attachment; filename*=UTF-8''%s.yaml", encodedName))
-			} else if useSurge || useQuantumultX || useLoon {
+			} else if useSurge || useSurfboard || useQuantumultX || useLoon {
 				c.Header("Content-Type", "text/plain; charset=utf-8")
 				c.Header("Content-Disposition", fmt.Sprintf("attachment; filename*=UTF-8''%s.conf", encodedName))
-			} else if useSingBox {
+			} else if useSingBox || useXray {
 				c.Header("Content-Type", "application/json; charset=utf-8")
 				c.Header("Content-Disposition", fmt.Sprintf("attachment; filename*=UTF-8''%s.json", encodedName))
 			} else {
@@ -619,63 +737,72 @@ func GetSubscription(c *gin.Context) {
 			c.Header("Subscription-Title", subscriptionName)
 			c.Header("Profile-Title", subscriptionName)
 			setSubscriptionHeaders(c, ctx)
+			recordSubscriptionAccess(c, ctx, subType, true)
 			c.String(http.StatusOK, cachedBody)
 			return
 		}
 	}
 
-	var nodes []models.Node
-	if ctx.Status != subStatusOK {
-		nodes = getErrorNodes(ctx)
-	} else {
-		nodes = append(getInfoNodes(ctx), ctx.Nodes...)
+	nodes := subscriptionNodes(ctx, subType, excludedProtocols, profile)
+	if ctx.Status == subStatusOK {
 		incrementSubscriptionCounter(ctx.Sub, subType)
 	}
 
-	if useStash || useClash {
-		nodes = FilterNodesByProtocol(nodes, getEffectiveProtocolFilter(ctx.Sub, "clash"))
-	} else {
-		// Shadowrocket 支持 socks 节点，跳过 universal 协议过滤
-		if !useShadowrocket {
-			nodes = FilterNodesByProtocol(nodes, getEffectiveProtocolFilter(ctx.Sub, "universal"))
-		}
-		// V2RayN 通用订阅不支持 socks 节点，自动排除
-		if ctx.ClientInfo != nil && isV2RayNClient(ctx.ClientInfo.SoftwareName) {
-			nodes = FilterNodesByProtocol(nodes, excludeProtocols(nodes, "socks", "socks5"))
-		}
-	}
-	if len(excludedProtocols) > 0 {
-		nodes = FilterNodesByProtocol(nodes, excludeProtocols(nodes, excludedProtocols...))
-	}
-
 	subscriptionName := generateSubscriptionName(ctx)
 
 	var responseData string
 	var contentType string
 	var fileNameSuffix string
 
+	db := database.GetDB()
 	if useStash {
-		responseData = services.GenerateStashYAMLWithDomain(nodes, ctx.SiteURL, subscriptionName)
+		responseData = services.GenerateStashYAMLWithTemplate(nodes, ctx.SiteURL, subscriptionName,
+			services.ResolveConfigTemplate(db, services.ConfigTemplateFormatStash, ctx.Sub),
+			services.ResolveConfigTemplate(db, services.ConfigTemplateFormatClash, ctx.Sub))
+		fileNameSuffix = ".yaml"
+		contentType = "text/yaml; charset=utf-8"
+	} else if useProviders {
+		responseData = services.GenerateClashYAMLWithProviders(nodes, ctx.SiteURL, subscriptionName,
+			services.ResolveConfigTemplate(db, services.ConfigTemplateFormatClash, ctx.Sub),
+			clashProviderOptions(c, excludedProtocols, profile))
 		fileNameSuffix = ".yaml"
 		contentType = "text/yaml; charset=utf-8"
 	} else if useClash {
-		responseData = services.GenerateClashYAMLWithDomain(nodes, ctx.SiteURL, subscriptionName)
+		responseData = services.GenerateClashYAMLWithTemplate(nodes, ctx.SiteURL, subscriptionName,
+			services.ResolveConfigTemplate(db, services.ConfigTemplateFormatClash, ctx.Sub))
 		fileNameSuffix = ".yaml"
 		contentType = "text/yaml; charset=utf-8"
 	} else if useSurge {
-		responseData = services.GenerateSurgeConfig(nodes, ctx.SiteURL)
+		responseData = services.GenerateSurgeConfigWithTemplate(nodes, ctx.SiteURL,
+			services.ResolveConfigTemplate(db, services.ConfigTemplateFormatSurge, ctx.Sub))
 		fileNameSuffix = ".conf"
 		contentType = "text/plain; charset=utf-8"
+	} else if useSurfboard {
+		responseData = services.GenerateSurfboardConfigWithTemplate(nodes, ctx.SiteURL,
+			services.ResolveConfigTemplate(db, services.ConfigTemplateFormatSurfboard, ctx.Sub))
+		fileNameSuffix = ".conf"
+		contentType = "text/plain; charset=utf-8"
+	} else if useEgern {
+		responseData = services.GenerateEgernConfig(nodes)
+		fileNameSuffix = ".yaml"
+		contentType = "text/yaml; charset=utf-8"
 	} else if useQuantumultX {
-		responseData = services.GenerateQuantumultXConfig(nodes)
+		responseData = services.GenerateQuantumultXConfigWithTemplate(nodes, ctx.SiteURL,
+			services.ResolveConfigTemplate(db, services.ConfigTemplateFormatQuantumultX, ctx.Sub))
 		fileNameSuffix = ".conf"
 		contentType = "text/plain; charset=utf-8"
 	} else if useLoon {
-		responseData = services.GenerateLoonConfig(nodes, ctx.SiteURL)
+		responseData = services.GenerateLoonConfigWithTemplate(nodes, ctx.SiteURL,
+			services.ResolveConfigTemplate(db, services.ConfigTemplateFormatLoon, ctx.Sub))
 		fileNameSuffix = ".conf"
 		contentType = "text/plain; charset=utf-8"
 	} else if useSingBox {
-		responseData = services.GenerateSingBoxConfig(nodes)
+		responseData = services.GenerateSingBoxConfigWithTemplate(nodes,
+			services.ResolveConfigTemplate(db, services.ConfigTemplateFormatSingBox, ctx.Sub))
+		fileNameSuffix = ".json"
+		contentType = "application/json; charset=utf-8"
+	} else if useXray {
+		responseData = services.GenerateXrayConfig(nodes, subscriptionName)
 		fileNameSuffix = ".json"
 		contentType = "application/json; charset=utf-8"
 	} else {
@@ -683,6 +810,15 @@ func GetSubscription(c *gin.Context) {
 		fileNameSuffix = ""
 		contentType = "text/plain; charset=utf-8"
 	}
+	if len(customRules) > 0 {
+		if useStash || useClash {
+			responseData = services.InjectClashRules(responseData, customRules)
+		} else if useSurge {
+			responseData = services.InjectSurgeRules(responseData, customRules)
+		} else if useSingBox {
+			responseData = services.InjectSingBoxRules(responseData, customRules)
+		}
+	}
 
 	fileName := subscriptionName
 	if strings.HasPrefix(subscriptionName, "到期: ") {
@@ -701,14 +837,192 @@ func GetSubscription(c *gin.Context) {
 		r.Set(context.Background(), cacheKey, responseData, 5*time.Minute)
 	}
 
+	recordSubscriptionAccess(c, ctx, subType, false)
+	c.String(http.StatusOK, responseData)
+}
+
+// subscriptionNodes builds the node list delivered for subType: error nodes for abnormal subscriptions,
+// otherwise info nodes plus the subscription's nodes (narrowed by the user's profile, if any),
+// filtered by the protocol settings and ?exclude=.
+func subscriptionNodes(ctx *subscriptionContext, subType string, excludedProtocols []string, profile *models.SubscriptionProfile) []models.Node {
+	var nodes []models.Node
+	if ctx.Status != subStatusOK {
+		nodes = getErrorNodes(ctx)
+	} else {
+		if profile == nil || !profile.HideInfoNodes {
+			nodes = getInfoNodes(ctx)
+		}
+		// 用户筛选方案只作用于真实节点，按原始名称匹配（倍率后缀追加之前）
+		for _, n := range services.ApplySubscriptionProfile(ctx.Nodes, profile) {
+			// 自建节点使用该订阅自己的凭据（与节点端拉取的用户 UUID 一致），倍率节点名称追加 [xN]
+			n = services.ApplyNodeRateSuffix(n)
+			nodes = append(nodes, services.ApplyServerCredential(n, ctx.Sub.SubscriptionURL))
+		}
+	}
+
+	switch subType {
+	case "stash", "clash", "clashmeta", "classmeta", "mihomo":
+		nodes = FilterNodesByProtocol(nodes, getEffectiveProtocolFilter(ctx.Sub, "clash"))
+	case "surfboard":
+		nodes = FilterNodesByProtocol(nodes, getEffectiveProtocolFilter(ctx.Sub, "surfboard"))
+	case "egern":
+		nodes = FilterNodesByProtocol(nodes, getEffectiveProtocolFilter(ctx.Sub, "egern"))
+	default:
+		// Shadowrocket 支持 socks 节点，跳过 universal 协议过滤
+		if subType != "shadowrocket" {
+			nodes = FilterNodesByProtocol(nodes, getEffectiveProtocolFilter(ctx.Sub, "universal"))
+		}
+		// V2RayN 通用订阅不支持 socks 节点，自动排除
+		if ctx.ClientInfo != nil && isV2RayNClient(ctx.ClientInfo.SoftwareName) {
+			nodes = FilterNodesByProtocol(nodes, excludeProtocols(nodes, "socks", "socks5"))
+		}
+	}
+	if len(excludedProtocols) > 0 {
+		nodes = FilterNodesByProtocol(nodes, excludeProtocols(nodes, excludedProtocols...))
+	}
+	return nodes
+}
+
+// subscriptionProfileFromQuery loads the profile selected by ?profile=; unknown profiles or profiles of
+// other subscriptions are ignored so the link still delivers the full node list.
+func subscriptionProfileFromQuery(c *gin.Context, ctx *subscriptionContext) *models.SubscriptionProfile {
+	profileID, err := strconv.ParseUint(c.Query("profile"), 10, 64)
+	if err != nil || profileID == 0 || ctx.Sub == nil || ctx.Sub.ID == 0 {
+		return nil
+	}
+	var profile models.SubscriptionProfile
+	if err := database.GetDB().Where("id = ? AND subscription_id = ?", profileID, ctx.Sub.ID).First(&profile).Error; err != nil {
+		return nil
+	}
+	return &profile
+}
+
+// subscriptionProfileCacheKey distinguishes cached payloads per profile; the update time invalidates edited profiles.
+func subscriptionProfileCacheKey(profile *models.SubscriptionProfile) string {
+	if profile == nil {
+		return ""
+	}
+	return fmt.Sprintf(":profile:%d:%d", profile.ID, profile.UpdatedAt.Unix())
+}
+
+// customRulesFormats lists the subscription types that receive the user's custom rules.
+var customRulesFormats = map[string]bool{
+	"stash": true, "clash": true, "clashmeta": true, "classmeta": true, "mihomo": true, "surge": true, "singbox": true, "sing-box": true,
+}
+
+// subscriptionCustomRules loads the owner's custom rules for formats that support them; the returned
+// suffix keys cached payloads by the rules' update time.
+func subscriptionCustomRules(ctx *subscriptionContext, subType string) ([]services.UserRule, string) {
+	if ctx.Status != subStatusOK || ctx.Sub == nil || !customRulesFormats[subType] || !customRulesEnabled() {
+		return nil, ""
+	}
+	var record models.UserCustomRules
+	if err := database.GetDB().Where("user_id = ?", ctx.Sub.UserID).First(&record).Error; err != nil || record.Rules == "" {
+		return nil, ""
+	}
+	return services.DecodeUserRules(record.Rules), fmt.Sprintf(":rules:%d", record.UpdatedAt.Unix())
+}
+
+// clashProvidersRequested reports whether the Clash profile uses proxy-providers;
+// ?providers=1/0 overrides the clash_proxy_providers_enabled setting.
+func clashProvidersRequested(c *gin.Context) bool {
+	switch c.Query("providers") {
+	case "1", "true":
+		return true
+	case "0", "false":
+		return false
+	}
+	return utils.IsBoolSetting("clash_proxy_providers_enabled")
+}
+
+// clashProviderOptions builds provider URLs with the token the client used, so signed links keep working.
+func clashProviderOptions(c *gin.Context, excludedProtocols []string, profile *models.SubscriptionProfile) services.ClashProviderOptions {
+	baseURL := getSubscriptionBaseURL()
+	if baseURL == "" {
+		scheme := "http"
+		if c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https" {
+			scheme = "https"
+		}
+		baseURL = scheme + "://" + c.Request.Host
+	}
+	token := c.Param("url")
+	if token == "" {
+		token = c.Param("token")
+	}
+	if token == "" {
+		token = c.Query("token")
+	}
+	params := url.Values{}
+	if len(excludedProtocols) > 0 {
+		params.Set("exclude", strings.Join(excludedProtocols, ","))
+	}
+	if profile != nil {
+		params.Set("profile", strconv.FormatUint(uint64(profile.ID), 10))
+	}
+	query := ""
+	if len(params) > 0 {
+		query = "?" + params.Encode()
+	}
+	return services.ClashProviderOptions{
+		URL: func(provider string) string {
+			return fmt.Sprintf("%s/api/v1/client/provider/%s/%s%s", baseURL, url.PathEscape(token), url.PathEscape(provider), query)
+		},
+		Interval: utils.GetIntSetting("clash_provider_interval", 3600),
+	}
+}
+
+// GetClashProvider GET /api/v1/client/provider/:token/:region
+// proxy-providers 模式下的节点列表（仅 proxies），region 为 all 或地区名，节点筛选与 Clash 订阅一致。
+func GetClashProvider(c *gin.Context) {
+	ctx := buildSubscriptionContext(c)
+	excludedProtocols := parseExcludedProtocols(c.Query("exclude"))
+	profile := subscriptionProfileFromQuery(c, ctx)
+	provider := c.Param("region")
+
+	var cacheKey string
+	r := database.GetRedis()
+	if ctx.Status == subStatusOK && ctx.Sub != nil && r != nil {
+		cacheKey = fmt.Sprintf("sub_payload:%d:provider:%s", ctx.Sub.ID, provider)
+		if len(excludedProtocols) > 0 {
+			cacheKey = fmt.Sprintf("%s:exclude:%s", cacheKey, strings.Join(excludedProtocols, ","))
+		}
+		cacheKey += subscriptionProfileCacheKey(profile)
+		if cachedBody, err := r.Get(c.Request.Context(), cacheKey).Result(); err == nil && cachedBody != "" {
+			c.Header("Content-Type", "text/yaml; charset=utf-8")
+			setSubscriptionHeaders(c, ctx)
+			recordSubscriptionAccess(c, ctx, "clash-provider", true)
+			c.String(http.StatusOK, cachedBody)
+			return
+		}
+	}
+
+	nodes := subscriptionNodes(ctx, "clash", excludedProtocols, profile)
+	var responseData string
+	if ctx.Status != subStatusOK {
+		// 订阅异常时所有 provider 都返回提示节点，客户端刷新 provider 即可看到原因
+		responseData = services.GenerateClashProxiesYAML(nodes)
+	} else {
+		responseData = services.GenerateClashProviderYAML(nodes, provider)
+	}
+
+	c.Header("Content-Type", "text/yaml; charset=utf-8")
+	setSubscriptionHeaders(c, ctx)
+	if cacheKey != "" {
+		r.Set(context.Background(), cacheKey, responseData, 5*time.Minute)
+	}
+	recordSubscriptionAccess(c, ctx, "clash-provider", false)
 	c.String(http.StatusOK, responseData)
 }
 
 // setSubscriptionHeaders sets common subscription response headers（Sparkle 等客户端用 Profile-Title / Profile-Update-Interval 显示名称与自动更新间隔）
 func setSubscriptionHeaders(c *gin.Context, ctx *subscriptionContext) {
 	if ctx.Sub != nil {
-		// subscription-userinfo: upload=0; download=0; total=0; expire=<unix>
-		userinfoParts := []string{"upload=0", "download=0", "total=0"}
+		// subscription-userinfo: upload=<bytes>; download=<bytes>; total=<bytes>; expire=<unix>（total=0 表示不限流量）
+		userinfoParts := []string{
+			fmt.Sprintf("upload=%d", ctx.Sub.UploadTraffic),
+			fmt.Sprintf("download=%d", ctx.Sub.DownloadTraffic),
+			fmt.Sprintf("total=%d", ctx.Sub.TrafficQuota),
+		}
 		if !ctx.Sub.ExpireTime.IsZero() {
 			userinfoParts = append(userinfoParts, fmt.Sprintf("expire=%d", ctx.Sub.ExpireTime.Unix()))
 		}
@@ -744,11 +1058,51 @@ func incrementSubscriptionCounter(sub *models.Subscription, subType string) {
 	}
 }
 
+// recordSubscriptionAccess writes a SubscriptionAccessLog for this fetch; IP lookup and insert run on the worker pool.
+// 订阅地址不存在的请求已由 buildSubscriptionContext 记入系统日志，这里不再记录。
+func recordSubscriptionAccess(c *gin.Context, ctx *subscriptionContext, format string, cacheHit bool) {
+	if ctx.Sub == nil {
+		return
+	}
+	ua := c.GetHeader("User-Agent")
+	entry := models.SubscriptionAccessLog{
+		SubscriptionID: ctx.Sub.ID,
+		UserID:         ctx.Sub.UserID,
+		IPAddress:      utils.GetRealClientIP(c),
+		UserAgent:      &ua,
+		Format:         format,
+		Status:         ctx.Status.String(),
+		CacheHit:       cacheHit,
+	}
+	if info := ctx.ClientInfo; info != nil {
+		entry.SoftwareName = info.SoftwareName
+		entry.SoftwareVersion = info.SoftwareVersion
+		entry.OSName = info.OSName
+		entry.OSVersion = info.OSVersion
+		entry.DeviceModel = info.DeviceModel
+		entry.DeviceBrand = info.DeviceBrand
+		entry.DeviceType = info.DeviceType
+		entry.IsBrowser = info.IsBrowser
+		entry.SubscriptionType = info.SubscriptionType
+	}
+	worker.GetDefaultPool().Submit(func() {
+		if location := utils.GetIPLocation(entry.IPAddress); location != "" {
+			entry.Location = &location
+		}
+		if err := database.GetDB().Create(&entry).Error; err != nil {
+			utils.SysError("subscription", fmt.Sprintf("写入订阅访问日志失败: sub=%d err=%v", entry.SubscriptionID, err))
+		}
+	})
+}
+
 func GetSubscriptionByFormat(c *gin.Context) {
 	format := c.Param("format")
 	switch strings.ToLower(format) {
 	case "clash":
 		GetSubscription(c)
+	case "xray", "xray-json":
+		c.Request.URL.RawQuery += "&type=xray"
+		GetSubscription(c)
 	case "v2ray", "base64", "universal":
 		// 通用 base64 格式，传 type=universal 参数
 		c.Request.URL.RawQuery += "&type=universal"
@@ -793,31 +1147,67 @@ func buildClientSubscriptionURL(baseURL, token, typ string) string {
 	return fmt.Sprintf("%s/api/v1/client/subscribe?token=%s&type=%s", baseURL, token, typ)
 }
 
-func buildSubscriptionURLs(baseURL, token string) gin.H {
+// buildSubscriptionURLs builds the per-client links; they carry a signed, expiring token when signed links are enabled.
+func buildSubscriptionURLs(baseURL string, sub *models.Subscription, deviceID string) gin.H {
+	token := services.SubscriptionLinkToken(sub, deviceID)
 	return gin.H{
 		"universal_url":    buildClientSubscriptionURL(baseURL, token, ""),
 		"clash_url":        buildClientSubscriptionURL(baseURL, token, "clash"),
 		"stash_url":        buildClientSubscriptionURL(baseURL, token, "stash"),
 		"surge_url":        buildClientSubscriptionURL(baseURL, token, "surge"),
+		"surfboard_url":    buildClientSubscriptionURL(baseURL, token, "surfboard"),
+		"egern_url":        buildClientSubscriptionURL(baseURL, token, "egern"),
 		"quantumultx_url":  buildClientSubscriptionURL(baseURL, token, "quantumultx"),
 		"loon_url":         buildClientSubscriptionURL(baseURL, token, "loon"),
 		"singbox_url":      buildClientSubscriptionURL(baseURL, token, "singbox"),
+		"xray_url":         buildClientSubscriptionURL(baseURL, token, "xray"),
 		"shadowrocket_url": buildClientSubscriptionURL(baseURL, token, ""),
 		"v2ray_url":        buildClientSubscriptionURL(baseURL, token, ""),
 		"hiddify_url":      buildClientSubscriptionURL(baseURL, token, ""),
 	}
 }
 
+// userSubscriptionFromQuery loads the subscription selected by ?subscription_id=, defaulting to the primary one.
+func userSubscriptionFromQuery(c *gin.Context, db *gorm.DB, userID uint) (*models.Subscription, error) {
+	subID, _ := strconv.ParseUint(c.Query("subscription_id"), 10, 64)
+	return services.FindUserSubscription(db, userID, uint(subID))
+}
+
 func GetUserSubscription(c *gin.Context) {
 	userID := c.MustGet("user_id").(uint)
-	var sub models.Subscription
-	if err := database.GetDB().Where("user_id = ?", userID).First(&sub).Error; err != nil {
+	sub, err := userSubscriptionFromQuery(c, database.GetDB(), userID)
+	if err != nil {
 		utils.NotFound(c, "暂无订阅")
 		return
 	}
+	view := userSubscriptionView(sub, getSubscriptionBaseURL(), c.Query("device_id"))
+	utils.Success(c, view)
+}
 
+// ListUserSubscriptions GET /subscriptions/list
+// 返回用户的全部订阅（主订阅在前）以及可拥有的订阅数量上限。
+func ListUserSubscriptions(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+	subs := services.ListUserSubscriptions(database.GetDB(), userID)
 	baseURL := getSubscriptionBaseURL()
-	subscriptionURLs := buildSubscriptionURLs(baseURL, sub.SubscriptionURL)
+	items := make([]gin.H, 0, len(subs))
+	for i := range subs {
+		items = append(items, userSubscriptionView(&subs[i], baseURL, ""))
+	}
+	utils.Success(c, gin.H{
+		"items":             items,
+		"total":             len(items),
+		"max_subscriptions": utils.GetIntSetting("max_subscriptions_per_user", 5),
+	})
+}
+
+func userSubscriptionView(sub *models.Subscription, baseURL, deviceID string) gin.H {
+	subscriptionURLs := buildSubscriptionURLs(baseURL, sub, deviceID)
+	var linkExpiresAt *time.Time
+	if services.SignedLinksEnabled() {
+		t := time.Now().Add(services.SignedLinkTTL())
+		linkExpiresAt = &t
+	}
 
 	// Get package name
 	var packageName string
@@ -828,7 +1218,7 @@ func GetUserSubscription(c *gin.Context) {
 		}
 	}
 
-	result := gin.H{
+	return gin.H{
 		"id":                     sub.ID,
 		"user_id":                sub.UserID,
 		"package_id":             sub.PackageID,
@@ -838,12 +1228,16 @@ func GetUserSubscription(c *gin.Context) {
 		"token_clash_url":        subscriptionURLs["clash_url"],
 		"token_stash_url":        subscriptionURLs["stash_url"],
 		"token_surge_url":        subscriptionURLs["surge_url"],
+		"token_surfboard_url":    subscriptionURLs["surfboard_url"],
+		"token_egern_url":        subscriptionURLs["egern_url"],
 		"token_quantumultx_url":  subscriptionURLs["quantumultx_url"],
 		"token_loon_url":         subscriptionURLs["loon_url"],
 		"token_singbox_url":      subscriptionURLs["singbox_url"],
+		"token_xray_url":         subscriptionURLs["xray_url"],
 		"token_shadowrocket_url": subscriptionURLs["shadowrocket_url"],
 		"token_v2ray_url":        subscriptionURLs["v2ray_url"],
 		"token_hiddify_url":      subscriptionURLs["hiddify_url"],
+		"link_expires_at":        linkExpiresAt,
 		"device_limit":           sub.DeviceLimit,
 		"current_devices":        sub.CurrentDevices,
 		"universal_count":        sub.UniversalCount,
@@ -851,21 +1245,27 @@ func GetUserSubscription(c *gin.Context) {
 		"surge_count":            sub.SurgeCount,
 		"quanx_count":            sub.QuanXCount,
 		"shadowrocket_count":     sub.ShadowrocketCount,
+		"upload_traffic":         sub.UploadTraffic,
+		"download_traffic":       sub.DownloadTraffic,
+		"traffic_quota":          sub.TrafficQuota,
+		"traffic_reset_mode":     sub.TrafficResetMode,
+		"next_traffic_reset":     services.SubscriptionNextTrafficReset(sub),
 		"is_active":              sub.IsActive,
 		"status":                 sub.Status,
+		"paused_at":              sub.PausedAt,
+		"pause_resume_at":        sub.PauseResumeAt,
 		"expire_time":            sub.ExpireTime,
 		"expire_at":              sub.ExpireTime.Format("2006-01-02"),
 		"days_remaining":         int(time.Until(sub.ExpireTime).Hours() / 24),
 		"created_at":             sub.CreatedAt,
 		"updated_at":             sub.UpdatedAt,
 	}
-	utils.Success(c, result)
 }
 
 func GetSubscriptionDevices(c *gin.Context) {
 	userID := c.MustGet("user_id").(uint)
-	var sub models.Subscription
-	if err := database.GetDB().Where("user_id = ?", userID).First(&sub).Error; err != nil {
+	sub, err := userSubscriptionFromQuery(c, database.GetDB(), userID)
+	if err != nil {
 		utils.Success(c, []interface{}{})
 		return
 	}
@@ -877,8 +1277,8 @@ func GetSubscriptionDevices(c *gin.Context) {
 func ResetSubscription(c *gin.Context) {
 	userID := c.MustGet("user_id").(uint)
 	db := database.GetDB()
-	var sub models.Subscription
-	if err := db.Where("user_id = ?", userID).First(&sub).Error; err != nil {
+	sub, err := userSubscriptionFromQuery(c, db, userID)
+	if err != nil {
 		utils.NotFound(c, "暂无订阅")
 		return
 	}
@@ -890,9 +1290,9 @@ func ResetSubscription(c *gin.Context) {
 		if err := tx.Where("subscription_id = ?", sub.ID).Delete(&models.Device{}).Error; err != nil {
 			return err
 		}
-		if err := tx.Model(&sub).Updates(map[string]interface{}{
+		if err := tx.Model(sub).Updates(map[string]interface{}{
 			"subscription_url": newURL, "current_devices": 0,
-			"clash_count": 0, "universal_count": 0,
+			"clash_count": 0, "universal_count": 0, "leak_blocked_until": nil,
 		}).Error; err != nil {
 			return err
 		}
@@ -906,6 +1306,8 @@ func ResetSubscription(c *gin.Context) {
 		utils.InternalError(c, "重置订阅失败")
 		return
 	}
+	// 新地址重新开始泄露检测统计
+	services.ClearSubscriptionIPs(sub.ID)
 	// 通知用户订阅已重置
 	go services.NotifyUser(userID, "subscription_reset", map[string]string{"reset_by": "您自己"})
 	utils.Success(c, gin.H{"new_url": newURL})
@@ -922,12 +1324,17 @@ func ConvertToBalance(c *gin.Context) {
 		return
 	}
 
+	subID, _ := strconv.ParseUint(c.Query("subscription_id"), 10, 64)
 	var convertedAmount float64
 	var newBalance float64
+	var sub models.Subscription
 
 	err := db.Transaction(func(tx *gorm.DB) error {
-		var sub models.Subscription
-		if err := tx.Where("user_id = ? AND status = ?", userID, "active").First(&sub).Error; err != nil {
+		q := tx.Where("user_id = ? AND status = ?", userID, "active")
+		if subID > 0 {
+			q = q.Where("id = ?", subID)
+		}
+		if err := q.Order("id ASC").First(&sub).Error; err != nil {
 			return fmt.Errorf("no_sub")
 		}
 		remaining := time.Until(sub.ExpireTime).Hours() / 24
@@ -977,8 +1384,6 @@ func ConvertToBalance(c *gin.Context) {
 	utils.CreateBalanceLogEntry(userID, "refund", convertedAmount, newBalance-convertedAmount, newBalance, nil,
 		fmt.Sprintf("订阅转余额 (%.2f元)", convertedAmount), c)
 
-	var sub models.Subscription
-	db.Where("user_id = ?", userID).First(&sub)
 	utils.CreateSubscriptionLog(sub.ID, userID, "deactivate", "user", &userID,
 		fmt.Sprintf("订阅转余额: %.2f元", convertedAmount), nil, nil)
 
@@ -998,8 +1403,8 @@ func SendSubscriptionEmail(c *gin.Context) {
 		return
 	}
 
-	var sub models.Subscription
-	if err := db.Where("user_id = ?", userID).First(&sub).Error; err != nil {
+	sub, err := userSubscriptionFromQuery(c, db, userID)
+	if err != nil {
 		utils.NotFound(c, "暂无订阅")
 		return
 	}
@@ -1010,8 +1415,9 @@ func SendSubscriptionEmail(c *gin.Context) {
 		return
 	}
 
-	universalURL := fmt.Sprintf("%s/api/v1/sub/%s", baseURL, sub.SubscriptionURL)
-	clashURL := fmt.Sprintf("%s/api/v1/sub/clash/%s", baseURL, sub.SubscriptionURL)
+	subURLs := buildSubscriptionURLs(baseURL, sub, "")
+	universalURL, _ := subURLs["universal_url"].(string)
+	clashURL, _ := subURLs["clash_url"].(string)
 
 	subject, body := services.RenderEmail("subscription", map[string]string{
 		"clash_url":     clashURL,
@@ -1027,22 +1433,16 @@ func DeleteSubscriptionDevice(c *gin.Context) {
 	userID := c.MustGet("user_id").(uint)
 	deviceID := c.Param("id")
 	db := database.GetDB()
-	var sub models.Subscription
-	if err := db.Where("user_id = ?", userID).First(&sub).Error; err != nil {
-		utils.NotFound(c, "暂无订阅")
-		return
-	}
-	var device models.Device
-	if err := db.Where("id = ? AND subscription_id = ? AND is_active = ?", deviceID, sub.ID, true).First(&device).Error; err != nil {
-		utils.NotFound(c, "设备不存在")
+	device, sub, ok := findUserDevice(c, db, userID, deviceID)
+	if !ok {
 		return
 	}
 	// Soft-deactivate in transaction
 	err := db.Transaction(func(tx *gorm.DB) error {
-		if err := tx.Model(&device).Update("is_active", false).Error; err != nil {
+		if err := tx.Model(device).Update("is_active", false).Error; err != nil {
 			return err
 		}
-		return tx.Model(&sub).UpdateColumn("current_devices", gorm.Expr("CASE WHEN current_devices > 0 THEN current_devices - 1 ELSE 0 END")).Error
+		return tx.Model(sub).UpdateColumn("current_devices", gorm.Expr("CASE WHEN current_devices > 0 THEN current_devices - 1 ELSE 0 END")).Error
 	})
 	if err != nil {
 		utils.InternalError(c, "删除设备失败")
@@ -1051,6 +1451,21 @@ func DeleteSubscriptionDevice(c *gin.Context) {
 	utils.SuccessMessage(c, "设备已删除")
 }
 
+// findUserDevice loads an active device that belongs to any of the user's subscriptions.
+func findUserDevice(c *gin.Context, db *gorm.DB, userID uint, deviceID string) (*models.Device, *models.Subscription, bool) {
+	var device models.Device
+	if err := db.Where("id = ? AND is_active = ?", deviceID, true).First(&device).Error; err != nil {
+		utils.NotFound(c, "设备不存在")
+		return nil, nil, false
+	}
+	var sub models.Subscription
+	if err := db.Where("id = ? AND user_id = ?", device.SubscriptionID, userID).First(&sub).Error; err != nil {
+		utils.NotFound(c, "设备不存在")
+		return nil, nil, false
+	}
+	return &device, &sub, true
+}
+
 // UpdateDeviceRemark allows a user to set/update a remark on their device.
 func UpdateDeviceRemark(c *gin.Context) {
 	userID := c.MustGet("user_id").(uint)
@@ -1070,15 +1485,8 @@ func UpdateDeviceRemark(c *gin.Context) {
 		req.Remark = req.Remark[:200]
 	}
 
-	var sub models.Subscription
-	if err := db.Where("user_id = ?", userID).First(&sub).Error; err != nil {
-		utils.NotFound(c, "暂无订阅")
-		return
-	}
-
-	var device models.Device
-	if err := db.Where("id = ? AND subscription_id = ? AND is_active = ?", deviceID, sub.ID, true).First(&device).Error; err != nil {
-		utils.NotFound(c, "设备不存在")
+	device, _, ok := findUserDevice(c, db, userID, deviceID)
+	if !ok {
 		return
 	}
 
@@ -1086,7 +1494,7 @@ func UpdateDeviceRemark(c *gin.Context) {
 	if req.Remark == "" {
 		remark = nil
 	}
-	if err := db.Model(&device).Update("remark", remark).Error; err != nil {
+	if err := db.Model(device).Update("remark", remark).Error; err != nil {
 		utils.InternalError(c, "更新备注失败")
 		return
 	}
@@ -1095,7 +1503,7 @@ func UpdateDeviceRemark(c *gin.Context) {
 }
 
 // getEffectiveProtocolFilter returns per-subscription filter if set, else falls back to global.
-// filterType: "clash" or "universal"
+// filterType: "clash", "universal", "surfboard" or "egern"
 func getEffectiveProtocolFilter(sub *models.Subscription, filterType string) map[string]bool {
 	if sub != nil && sub.ProtocolFilter != "" {
 		var pf map[string][]string
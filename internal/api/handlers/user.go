@@ -315,12 +315,16 @@ func GetDashboardInfo(c *gin.Context) {
 	}
 
 	var sub models.Subscription
-	hasSub := db.Where("user_id = ?", userID).First(&sub).Error == nil
+	hasSub := db.Where("user_id = ?", userID).Order("id ASC").First(&sub).Error == nil
+	var subCount int64
+	db.Model(&models.Subscription{}).Where("user_id = ?", userID).Count(&subCount)
 	var orderCount int64
 	db.Model(&models.Order{}).Where("user_id = ?", userID).Count(&orderCount)
 	var deviceCount int64
 	if hasSub {
-		db.Model(&models.Device{}).Where("subscription_id = ? AND is_active = ?", sub.ID, true).Count(&deviceCount)
+		db.Model(&models.Device{}).
+			Where("subscription_id IN (?) AND is_active = ?", db.Model(&models.Subscription{}).Select("id").Where("user_id = ?", userID), true).
+			Count(&deviceCount)
 	}
 	// Compute node stats (public + user custom nodes)
 	var nodeTotal, nodeOnline int64
@@ -329,8 +333,9 @@ func GetDashboardInfo(c *gin.Context) {
 		customNodes, _, _ = fetchUserCustomNodes(db, userID, sub.ExpireTime)
 		// Public nodes
 		var publicOnline int64
-		db.Model(&models.Node{}).Where("is_active = ?", true).Count(&nodeTotal)
-		db.Model(&models.Node{}).Where("is_active = ? AND status = ?", true, "online").Count(&publicOnline)
+		nodeScope := services.UserNodeScope(db, userID)
+		db.Model(&models.Node{}).Scopes(nodeScope, services.LiveNodeScope).Count(&nodeTotal)
+		db.Model(&models.Node{}).Scopes(nodeScope).Scopes(services.LiveNodeScope).Where("status = ?", "online").Count(&publicOnline)
 		nodeTotal += int64(len(customNodes))
 		nodeOnline = publicOnline
 		for _, cn := range customNodes {
@@ -340,13 +345,14 @@ func GetDashboardInfo(c *gin.Context) {
 		}
 	}
 	utils.Success(c, gin.H{
-		"balance":          user.Balance,
-		"has_subscription": hasSub,
-		"subscription":     sub,
-		"order_count":      orderCount,
-		"device_count":     deviceCount,
-		"node_total":       nodeTotal,
-		"node_online":      nodeOnline,
+		"balance":            user.Balance,
+		"has_subscription":   hasSub,
+		"subscription":       sub,
+		"subscription_count": subCount,
+		"order_count":        orderCount,
+		"device_count":       deviceCount,
+		"node_total":         nodeTotal,
+		"node_online":        nodeOnline,
 	})
 }
 
@@ -363,8 +369,8 @@ func GetSubscriptionResets(c *gin.Context) {
 
 func GetUserDevices(c *gin.Context) {
 	userID := c.MustGet("user_id").(uint)
-	var sub models.Subscription
-	if err := database.GetDB().Where("user_id = ?", userID).First(&sub).Error; err != nil {
+	sub, err := userSubscriptionFromQuery(c, database.GetDB(), userID)
+	if err != nil {
 		utils.Success(c, []interface{}{})
 		return
 	}
@@ -1,9 +1,13 @@
 package middleware
 
 import (
+	"crypto/subtle"
 	"net"
+	"strconv"
 	"strings"
 
+	"cboard/v2/internal/database"
+	"cboard/v2/internal/models"
 	"cboard/v2/internal/utils"
 
 	"github.com/gin-gonic/gin"
@@ -64,3 +68,48 @@ func IPWhitelist() gin.HandlerFunc {
 		c.Abort()
 	}
 }
+
+// ServerTokenRequired authenticates node agents (XrayR / V2bX) by the shared server_token
+// and loads the self-hosted node identified by node_id into the context as "server_node".
+func ServerTokenRequired() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := utils.GetSetting("server_token")
+		if token == "" {
+			utils.Forbidden(c, "节点对接未启用")
+			c.Abort()
+			return
+		}
+		if subtle.ConstantTimeCompare([]byte(c.Query("token")), []byte(token)) != 1 {
+			utils.Unauthorized(c, "token 错误")
+			c.Abort()
+			return
+		}
+		nodeID, err := strconv.ParseUint(c.Query("node_id"), 10, 64)
+		if err != nil || nodeID == 0 {
+			utils.BadRequest(c, "node_id 无效")
+			c.Abort()
+			return
+		}
+		var node models.Node
+		if err := database.GetDB().Where("id = ? AND is_server = ?", nodeID, true).First(&node).Error; err != nil {
+			utils.NotFound(c, "节点不存在")
+			c.Abort()
+			return
+		}
+		// 节点端的 node_type 命名与面板不完全一致（shadowsocks / ss，v2ray / vmess）
+		nodeType := strings.ToLower(c.Query("node_type"))
+		switch nodeType {
+		case "shadowsocks":
+			nodeType = "ss"
+		case "v2ray":
+			nodeType = "vmess"
+		}
+		if nodeType != "" && nodeType != node.Type {
+			utils.BadRequest(c, "node_type 与节点类型不匹配")
+			c.Abort()
+			return
+		}
+		c.Set("server_node", &node)
+		c.Next()
+	}
+}
@@ -65,6 +65,23 @@ func SetupRouter(cfg *config.Config) *gin.Engine {
 	// 通用链接：GET /api/v1/client/subscribe?token=TOKEN&type=universal
 	subRL := middleware.RateLimit(20, time.Minute)
 	api.GET("/client/subscribe", subRL, handlers.GetSubscription)
+	// Clash proxy-providers 模式的节点列表：GET /api/v1/client/provider/TOKEN/all 或 /TOKEN/香港
+	api.GET("/client/provider/:token/:region", subRL, handlers.GetClashProvider)
+
+	// 规则集（由 uploads/config/geosite.dat 生成）：GET /api/v1/rulesets/geosite/clash/netflix
+	api.GET("/rulesets/geosite/:format/:category", middleware.RateLimit(60, time.Minute), handlers.GetGeositeRuleSet)
+
+	// 自建节点对接（XrayR / V2bX UniProxy 协议），使用 server_token 认证
+	// GET /api/v1/server/UniProxy/config?node_type=vless&node_id=1&token=TOKEN
+	uniProxy := api.Group("/server/UniProxy")
+	uniProxy.Use(middleware.ServerTokenRequired())
+	{
+		uniProxy.GET("/config", handlers.ServerNodeConfig)
+		uniProxy.GET("/user", handlers.ServerNodeUsers)
+		uniProxy.POST("/push", handlers.ServerNodePush)
+		uniProxy.POST("/alive", handlers.ServerNodeAlive)
+		uniProxy.GET("/alivelist", handlers.ServerNodeAliveList)
+	}
 
 	// 公开配置
 	api.GET("/config", handlers.GetPublicConfig)
@@ -108,6 +125,8 @@ func SetupRouter(cfg *config.Config) *gin.Engine {
 			users.PUT("/notification-settings", handlers.UpdateNotificationSettings)
 			users.GET("/privacy-settings", handlers.GetPrivacySettings)
 			users.PUT("/privacy-settings", handlers.UpdatePrivacySettings)
+			users.GET("/custom-rules", handlers.GetCustomRules)
+			users.PUT("/custom-rules", handlers.UpdateCustomRules)
 			users.GET("/my-level", handlers.GetMyLevel)
 			users.GET("/login-history", handlers.GetLoginHistory)
 			users.GET("/activities", handlers.GetActivities)
@@ -121,6 +140,7 @@ func SetupRouter(cfg *config.Config) *gin.Engine {
 		// 订阅
 		subs := authorized.Group("/subscriptions")
 		{
+			subs.GET("/list", handlers.ListUserSubscriptions)
 			subs.GET("/user-subscription", handlers.GetUserSubscription)
 			subs.GET("/devices", handlers.GetSubscriptionDevices)
 			subs.POST("/reset-subscription", handlers.ResetSubscription)
@@ -128,6 +148,13 @@ func SetupRouter(cfg *config.Config) *gin.Engine {
 			subs.POST("/send-subscription-email", handlers.SendSubscriptionEmail)
 			subs.DELETE("/devices/:id", handlers.DeleteSubscriptionDevice)
 			subs.PUT("/devices/:id/remark", handlers.UpdateDeviceRemark)
+			subs.GET("/pause", handlers.GetSubscriptionPauseInfo)
+			subs.POST("/pause", handlers.PauseUserSubscription)
+			subs.POST("/resume", handlers.ResumeUserSubscription)
+			subs.GET("/profiles", handlers.ListSubscriptionProfiles)
+			subs.POST("/profiles", handlers.CreateSubscriptionProfile)
+			subs.PUT("/profiles/:id", handlers.UpdateSubscriptionProfile)
+			subs.DELETE("/profiles/:id", handlers.DeleteSubscriptionProfile)
 		}
 
 		// 订单
@@ -165,6 +192,9 @@ func SetupRouter(cfg *config.Config) *gin.Engine {
 		authorized.POST("/coupons/verify", middleware.RateLimit(10, time.Minute), handlers.VerifyCoupon)
 		authorized.GET("/coupons/my", handlers.GetMyCoupons)
 
+		// 订阅转换（需开启 sub_converter_user_enabled，添加频率限制防止滥用拉取）
+		authorized.POST("/tools/convert", middleware.RateLimit(10, time.Minute), handlers.ConvertSubscription)
+
 		// 通知
 		notifs := authorized.Group("/notifications")
 		{
@@ -274,8 +304,41 @@ func SetupRouter(cfg *config.Config) *gin.Engine {
 			adminPkgs.POST("", handlers.AdminCreatePackage)
 			adminPkgs.PUT("/:id", handlers.AdminUpdatePackage)
 			adminPkgs.DELETE("/:id", handlers.AdminDeletePackage)
+			adminPkgs.GET("/:id/node-groups", handlers.AdminGetPackageNodeGroups)
+			adminPkgs.PUT("/:id/node-groups", handlers.AdminSetPackageNodeGroups)
 		}
 
+		// 节点分组
+		adminNodeGroups := admin.Group("/node-groups")
+		adminNodeGroups.Use(middleware.CSRFProtection())
+		{
+			adminNodeGroups.GET("", handlers.AdminListNodeGroups)
+			adminNodeGroups.POST("", handlers.AdminCreateNodeGroup)
+			adminNodeGroups.PUT("/:id", handlers.AdminUpdateNodeGroup)
+			adminNodeGroups.DELETE("/:id", handlers.AdminDeleteNodeGroup)
+		}
+
+		// 客户端配置模板
+		adminConfigTemplates := admin.Group("/config-templates")
+		adminConfigTemplates.Use(middleware.CSRFProtection())
+		{
+			adminConfigTemplates.GET("", handlers.AdminListConfigTemplates)
+			adminConfigTemplates.POST("", handlers.AdminCreateConfigTemplate)
+			adminConfigTemplates.POST("/preview", handlers.AdminPreviewConfigTemplate)
+			adminConfigTemplates.GET("/:id", handlers.AdminGetConfigTemplate)
+			adminConfigTemplates.PUT("/:id", handlers.AdminUpdateConfigTemplate)
+			adminConfigTemplates.DELETE("/:id", handlers.AdminDeleteConfigTemplate)
+			adminConfigTemplates.GET("/:id/versions", handlers.AdminListConfigTemplateVersions)
+			adminConfigTemplates.POST("/:id/rollback", handlers.AdminRollbackConfigTemplate)
+			adminConfigTemplates.PUT("/:id/bindings", handlers.AdminSetConfigTemplateBindings)
+		}
+
+		// geosite 规则集分类
+		admin.GET("/rulesets/geosite", handlers.AdminListGeositeCategories)
+
+		// 订阅转换工具
+		admin.POST("/tools/convert", middleware.CSRFProtection(), handlers.ConvertSubscription)
+
 		// 节点管理
 		adminNodes := admin.Group("/nodes")
 		adminNodes.Use(middleware.CSRFProtection())
@@ -285,6 +348,9 @@ func SetupRouter(cfg *config.Config) *gin.Engine {
 			adminNodes.PUT("/:id", handlers.AdminUpdateNode)
 			adminNodes.DELETE("/:id", handlers.AdminDeleteNode)
 			adminNodes.POST("/import", handlers.AdminImportNodes)
+			adminNodes.GET("/rename-rules", handlers.AdminGetNodeRenameRules)
+			adminNodes.PUT("/rename-rules", handlers.AdminSaveNodeRenameRules)
+			adminNodes.POST("/rename-rules/preview", handlers.AdminPreviewNodeRenameRules)
 			adminNodes.POST("/:id/test", handlers.AdminTestNode)
 			adminNodes.POST("/batch-action", handlers.AdminBatchNodeAction)
 		}
@@ -323,8 +389,11 @@ func SetupRouter(cfg *config.Config) *gin.Engine {
 		adminSubs.Use(middleware.CSRFProtection())
 		{
 			adminSubs.GET("", handlers.AdminListSubscriptions)
+			adminSubs.GET("/leak-flagged", handlers.AdminListLeakFlaggedSubscriptions)
 			adminSubs.GET("/:id", handlers.AdminGetSubscription)
+			adminSubs.GET("/:id/access-logs", handlers.AdminSubscriptionAccessLogs)
 			adminSubs.POST("/:id/reset", handlers.AdminResetSubscription)
+			adminSubs.POST("/:id/leak-clear", handlers.AdminClearSubscriptionLeak)
 			adminSubs.POST("/:id/extend", handlers.AdminExtendSubscription)
 			adminSubs.PUT("/:id", handlers.AdminUpdateSubscription)
 			adminSubs.POST("/:id/send-email", handlers.AdminSendSubscriptionEmail)
@@ -206,12 +206,17 @@ func AutoMigrate() error {
 		// 订阅与设备
 		&models.Subscription{},
 		&models.SubscriptionReset{},
+		&models.SubscriptionPause{},
+		&models.SubscriptionProfile{},
+		&models.UserCustomRules{},
 		&models.Device{},
 
 		// 节点
 		&models.Node{},
 		&models.CustomNode{},
 		&models.UserCustomNode{},
+		&models.NodeGroup{},
+		&models.PackageNodeGroup{},
 
 		// 订单与套餐
 		&models.Order{},
@@ -248,6 +253,9 @@ func AutoMigrate() error {
 		// 系统配置
 		&models.SystemConfig{},
 		&models.Announcement{},
+		&models.ConfigTemplate{},
+		&models.ConfigTemplateVersion{},
+		&models.ConfigTemplateBinding{},
 
 		// 审计与安全
 		&models.AuditLog{},
@@ -260,6 +268,7 @@ func AutoMigrate() error {
 		// 日志
 		&models.RegistrationLog{},
 		&models.SubscriptionLog{},
+		&models.SubscriptionAccessLog{},
 		&models.BalanceLog{},
 		&models.CommissionLog{},
 		&models.SystemLog{},
@@ -0,0 +1,49 @@
+package models
+
+import "time"
+
+// ConfigTemplate 客户端配置模板（Clash / Stash / sing-box / Surge / Surfboard / Loon / Quantumult X），由后台维护，替代 uploads/config 下的模板文件
+type ConfigTemplate struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	Name        string    `gorm:"type:varchar(100)" json:"name"`
+	Format      string    `gorm:"type:varchar(20);index" json:"format"` // clash / stash / singbox / surge / surfboard / loon / quantumultx
+	Description *string   `gorm:"type:text" json:"description"`
+	Content     string    `gorm:"size:16777216" json:"content"`    // 当前版本内容（MySQL 下为 mediumtext）
+	Version     int       `gorm:"default:1" json:"version"`        // 当前版本号
+	IsDefault   bool      `gorm:"default:false" json:"is_default"` // 同一格式的默认模板（未按套餐/等级指定时使用）
+	CreatedAt   time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt   time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+func (ConfigTemplate) TableName() string {
+	return "config_templates"
+}
+
+// ConfigTemplateVersion 模板的历史版本，每次保存或回滚都会新增一条
+type ConfigTemplateVersion struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	TemplateID uint      `gorm:"uniqueIndex:idx_template_version" json:"template_id"`
+	Version    int       `gorm:"uniqueIndex:idx_template_version" json:"version"`
+	Content    string    `gorm:"size:16777216" json:"content"`
+	Comment    string    `gorm:"type:varchar(255)" json:"comment"`
+	CreatedBy  *uint     `json:"created_by"`
+	CreatedAt  time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+func (ConfigTemplateVersion) TableName() string {
+	return "config_template_versions"
+}
+
+// ConfigTemplateBinding 将模板指定给套餐或用户等级（同一格式下每个对象只绑定一个模板）
+type ConfigTemplateBinding struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	TemplateID uint      `gorm:"index" json:"template_id"`
+	Format     string    `gorm:"type:varchar(20);uniqueIndex:idx_template_binding_target" json:"format"`
+	TargetType string    `gorm:"type:varchar(20);uniqueIndex:idx_template_binding_target" json:"target_type"` // package / user_level
+	TargetID   uint      `gorm:"uniqueIndex:idx_template_binding_target" json:"target_id"`
+	CreatedAt  time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+func (ConfigTemplateBinding) TableName() string {
+	return "config_template_bindings"
+}
@@ -63,6 +63,31 @@ type SubscriptionLog struct {
 
 func (SubscriptionLog) TableName() string { return "subscription_logs" }
 
+// SubscriptionAccessLog 订阅拉取记录（每次客户端获取订阅写入一条，用于排查设备超限等争议）
+type SubscriptionAccessLog struct {
+	ID               uint      `gorm:"primaryKey" json:"id"`
+	SubscriptionID   uint      `gorm:"index:idx_sub_access_sub_time;not null" json:"subscription_id"`
+	UserID           uint      `gorm:"index;not null" json:"user_id"`
+	IPAddress        string    `gorm:"type:varchar(45);index" json:"ip_address"`
+	Location         *string   `gorm:"type:varchar(255)" json:"location,omitempty"`
+	UserAgent        *string   `gorm:"type:text" json:"user_agent,omitempty"`
+	SoftwareName     string    `gorm:"type:varchar(50)" json:"software_name"`
+	SoftwareVersion  string    `gorm:"type:varchar(50)" json:"software_version"`
+	OSName           string    `gorm:"type:varchar(50)" json:"os_name"`
+	OSVersion        string    `gorm:"type:varchar(50)" json:"os_version"`
+	DeviceModel      string    `gorm:"type:varchar(100)" json:"device_model"`
+	DeviceBrand      string    `gorm:"type:varchar(50)" json:"device_brand"`
+	DeviceType       string    `gorm:"type:varchar(20)" json:"device_type"`
+	IsBrowser        bool      `gorm:"default:false" json:"is_browser"`
+	SubscriptionType string    `gorm:"type:varchar(20)" json:"subscription_type"` // UA 识别出的客户端类型
+	Format           string    `gorm:"type:varchar(20);index" json:"format"`      // 实际下发的订阅格式
+	Status           string    `gorm:"type:varchar(30);index" json:"status"`      // ok, inactive, expired, traffic_exhausted, device_over_limit
+	CacheHit         bool      `gorm:"default:false" json:"cache_hit"`
+	CreatedAt        time.Time `gorm:"autoCreateTime;index:idx_sub_access_sub_time;index" json:"created_at"`
+}
+
+func (SubscriptionAccessLog) TableName() string { return "subscription_access_logs" }
+
 // BalanceLog 余额变动日志
 type BalanceLog struct {
 	ID              uint      `gorm:"primaryKey" json:"id"`
@@ -18,8 +18,16 @@ type Node struct {
 	IsActive      bool       `gorm:"default:true;index" json:"is_active"`
 	IsManual      bool       `gorm:"default:false" json:"is_manual"`
 	SourceIndex   int        `gorm:"default:0" json:"source_index"`
+	SourceKey     string     `gorm:"type:varchar(32);index" json:"source_key"` // 自动导入节点所属来源（订阅地址的哈希），来源调整顺序后仍能对应
 	OrderIndex    int        `gorm:"default:0;index" json:"order_index"`
+	GroupID       *uint      `gorm:"index" json:"group_id"`                // 所属节点分组，nil 表示所有套餐可用
+	Rate          float64    `gorm:"default:1" json:"rate"`                // 流量计费倍率，上报流量乘以倍率后计入订阅
+	IsServer      bool       `gorm:"default:false;index" json:"is_server"` // 自建节点：由节点端（XrayR/V2bX）对接面板拉取用户、上报流量
+	OnlineUsers   int        `gorm:"default:0" json:"online_users"`
+	LastCheckAt   *time.Time `json:"last_check_at"` // 节点端最后一次拉取配置/用户
+	LastPushAt    *time.Time `json:"last_push_at"`  // 节点端最后一次上报流量
 	LastTest      *time.Time `json:"last_test"`
+	RetiredAt     *time.Time `gorm:"index" json:"retired_at"` // 自动导入节点从上游消失的时间（已下线），重新出现时清空
 	CreatedAt     time.Time  `gorm:"autoCreateTime" json:"created_at"`
 	UpdatedAt     time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
 }
@@ -28,6 +36,32 @@ func (Node) TableName() string {
 	return "nodes"
 }
 
+// NodeGroup 节点分组，套餐通过 PackageNodeGroup 授权可用的分组
+type NodeGroup struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	Name        string    `gorm:"type:varchar(100);uniqueIndex" json:"name"`
+	Description *string   `gorm:"type:text" json:"description"`
+	SortOrder   int       `gorm:"default:0" json:"sort_order"`
+	CreatedAt   time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt   time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+func (NodeGroup) TableName() string {
+	return "node_groups"
+}
+
+// PackageNodeGroup 套餐与节点分组的多对多关联
+type PackageNodeGroup struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	PackageID   uint      `gorm:"uniqueIndex:idx_package_node_group" json:"package_id"`
+	NodeGroupID uint      `gorm:"uniqueIndex:idx_package_node_group;index" json:"node_group_id"`
+	CreatedAt   time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+func (PackageNodeGroup) TableName() string {
+	return "package_node_groups"
+}
+
 type CustomNode struct {
 	ID               uint       `gorm:"primaryKey" json:"id"`
 	Name             string     `gorm:"type:varchar(100)" json:"name"`
@@ -20,6 +20,8 @@ type Order struct {
 	DiscountAmount       *float64   `gorm:"type:decimal(10,2);default:0" json:"discount_amount"`
 	FinalAmount          *float64   `gorm:"type:decimal(10,2)" json:"final_amount"`
 	ExtraData            *string    `gorm:"type:text" json:"extra_data"`
+	SubscriptionID       *uint      `gorm:"index" json:"subscription_id"`          // 续期/升级的目标订阅；新建订阅的订单在激活后回填
+	NewSubscription      bool       `gorm:"default:false" json:"new_subscription"` // 购买独立的新订阅，而不是续期已有订阅
 	CreatedAt            time.Time  `gorm:"autoCreateTime;index;index:idx_user_created,priority:2" json:"created_at"`
 	UpdatedAt            time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
 }
@@ -30,18 +32,21 @@ func (Order) TableName() string {
 
 // Package 套餐
 type Package struct {
-	ID           uint      `gorm:"primaryKey" json:"id"`
-	Name         string    `gorm:"type:varchar(100)" json:"name"`
-	Description  *string   `gorm:"type:text" json:"description"`
-	Price        float64   `gorm:"type:decimal(10,2)" json:"price"`
-	DurationDays int       `json:"duration_days"`
-	DeviceLimit  int       `gorm:"default:3" json:"device_limit"`
-	Features     *string   `gorm:"type:text" json:"features"`
-	SortOrder    int       `gorm:"default:1" json:"sort_order"`
-	IsActive     bool      `gorm:"default:true;index" json:"is_active"`
-	IsFeatured   bool      `gorm:"default:false" json:"is_featured"`
-	CreatedAt    time.Time `gorm:"autoCreateTime" json:"created_at"`
-	UpdatedAt    time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+	ID               uint      `gorm:"primaryKey" json:"id"`
+	Name             string    `gorm:"type:varchar(100)" json:"name"`
+	Description      *string   `gorm:"type:text" json:"description"`
+	Price            float64   `gorm:"type:decimal(10,2)" json:"price"`
+	DurationDays     int       `json:"duration_days"`
+	DeviceLimit      int       `gorm:"default:3" json:"device_limit"`
+	TrafficGB        int       `gorm:"default:0" json:"traffic_gb"`                                  // 流量配额（GB），0 表示不限
+	SpeedLimit       int       `gorm:"default:0" json:"speed_limit"`                                 // 限速（Mbps），0 表示不限
+	TrafficResetMode string    `gorm:"type:varchar(20);default:'monthly'" json:"traffic_reset_mode"` // 流量重置周期：monthly=每月1日，anniversary=按购买日，never=不重置
+	Features         *string   `gorm:"type:text" json:"features"`
+	SortOrder        int       `gorm:"default:1" json:"sort_order"`
+	IsActive         bool      `gorm:"default:true;index" json:"is_active"`
+	IsFeatured       bool      `gorm:"default:false" json:"is_featured"`
+	CreatedAt        time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt        time.Time `gorm:"autoUpdateTime" json:"updated_at"`
 }
 
 func (Package) TableName() string {
@@ -3,29 +3,58 @@ package models
 import "time"
 
 type Subscription struct {
-	ID                uint      `gorm:"primaryKey" json:"id"`
-	UserID            uint      `gorm:"index:idx_user_status" json:"user_id"`
-	PackageID         *int64    `gorm:"index" json:"package_id"`
-	SubscriptionURL   string    `gorm:"type:varchar(100);uniqueIndex" json:"subscription_url"`
-	DeviceLimit       int       `json:"device_limit"`
-	CurrentDevices    int       `gorm:"default:0;index:idx_device_check" json:"current_devices"`
-	UniversalCount    int       `gorm:"default:0" json:"universal_count"`
-	ClashCount        int       `gorm:"default:0" json:"clash_count"`
-	SurgeCount        int       `gorm:"default:0" json:"surge_count"`
-	QuanXCount        int       `gorm:"default:0" json:"quanx_count"`
-	ShadowrocketCount int       `gorm:"default:0" json:"shadowrocket_count"`
-	ProtocolFilter    string    `gorm:"type:text" json:"protocol_filter"` // JSON: {"clash":["vmess",...], "universal":["vmess",...]}; empty = use global
-	IsActive          bool      `gorm:"default:true;index:idx_active_expire" json:"is_active"`
-	Status            string    `gorm:"type:varchar(20);default:'active';index:idx_user_status" json:"status"`
-	ExpireTime        time.Time `gorm:"index:idx_active_expire" json:"expire_time"`
-	CreatedAt         time.Time `gorm:"autoCreateTime" json:"created_at"`
-	UpdatedAt         time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+	ID                uint       `gorm:"primaryKey" json:"id"`
+	UserID            uint       `gorm:"index:idx_user_status" json:"user_id"`
+	PackageID         *int64     `gorm:"index" json:"package_id"`
+	SubscriptionURL   string     `gorm:"type:varchar(100);uniqueIndex" json:"subscription_url"`
+	DeviceLimit       int        `json:"device_limit"`
+	CurrentDevices    int        `gorm:"default:0;index:idx_device_check" json:"current_devices"`
+	UniversalCount    int        `gorm:"default:0" json:"universal_count"`
+	ClashCount        int        `gorm:"default:0" json:"clash_count"`
+	SurgeCount        int        `gorm:"default:0" json:"surge_count"`
+	QuanXCount        int        `gorm:"default:0" json:"quanx_count"`
+	ShadowrocketCount int        `gorm:"default:0" json:"shadowrocket_count"`
+	ProtocolFilter    string     `gorm:"type:text" json:"protocol_filter"`                             // JSON: {"clash":["vmess",...], "universal":["vmess",...]}; empty = use global
+	UploadTraffic     int64      `gorm:"default:0" json:"upload_traffic"`                              // 已用上行流量（字节）
+	DownloadTraffic   int64      `gorm:"default:0" json:"download_traffic"`                            // 已用下行流量（字节）
+	TrafficQuota      int64      `gorm:"default:0" json:"traffic_quota"`                               // 流量配额（字节），0 表示不限
+	SpeedLimit        int        `gorm:"default:0" json:"speed_limit"`                                 // 限速（Mbps），0 表示不限，由自建节点端执行
+	TrafficResetMode  string     `gorm:"type:varchar(20);default:'monthly'" json:"traffic_reset_mode"` // 流量重置周期：monthly / anniversary / never
+	TrafficResetDay   int        `gorm:"default:0" json:"traffic_reset_day"`                           // anniversary 模式下每月的重置日
+	LastTrafficReset  *time.Time `json:"last_traffic_reset"`
+	TrafficExhausted  bool       `gorm:"default:false;index" json:"traffic_exhausted"` // 已用流量达到配额（由定时任务标记）
+	LeakFlaggedAt     *time.Time `gorm:"index" json:"leak_flagged_at"`                 // 最近一次触发订阅泄露检测的时间
+	LeakIPCount       int        `gorm:"default:0" json:"leak_ip_count"`               // 触发时检测窗口内的独立 IP 数
+	LeakBlockedUntil  *time.Time `json:"leak_blocked_until"`                           // 泄露检测临时封禁截止时间
+	PausedAt          *time.Time `json:"paused_at"`                                    // 暂停（冻结）开始时间，未暂停为空
+	PauseResumeAt     *time.Time `gorm:"index" json:"pause_resume_at"`                 // 暂停额度用尽时自动恢复的时间
+	IsActive          bool       `gorm:"default:true;index:idx_active_expire" json:"is_active"`
+	Status            string     `gorm:"type:varchar(20);default:'active';index:idx_user_status" json:"status"`
+	ExpireTime        time.Time  `gorm:"index:idx_active_expire" json:"expire_time"`
+	CreatedAt         time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt         time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
 }
 
 func (Subscription) TableName() string {
 	return "subscriptions"
 }
 
+// SubscriptionPause 订阅暂停记录（用于统计每年的暂停次数与时长）
+type SubscriptionPause struct {
+	ID             uint       `gorm:"primaryKey" json:"id"`
+	SubscriptionID uint       `gorm:"index" json:"subscription_id"`
+	UserID         uint       `gorm:"index" json:"user_id"`
+	PausedAt       time.Time  `gorm:"index" json:"paused_at"`
+	ResumedAt      *time.Time `json:"resumed_at"`
+	PausedSeconds  int64      `gorm:"default:0" json:"paused_seconds"` // 恢复时计入的实际暂停时长
+	ResumedBy      *string    `gorm:"type:varchar(50)" json:"resumed_by"`
+	CreatedAt      time.Time  `gorm:"autoCreateTime" json:"created_at"`
+}
+
+func (SubscriptionPause) TableName() string {
+	return "subscription_pauses"
+}
+
 type SubscriptionReset struct {
 	ID                 uint      `gorm:"primaryKey" json:"id"`
 	UserID             uint      `gorm:"index" json:"user_id"`
@@ -43,3 +72,37 @@ type SubscriptionReset struct {
 func (SubscriptionReset) TableName() string {
 	return "subscription_resets"
 }
+
+// SubscriptionProfile 用户保存的订阅筛选方案，通过订阅链接附加 &profile=ID 生效
+type SubscriptionProfile struct {
+	ID             uint      `gorm:"primaryKey" json:"id"`
+	UserID         uint      `gorm:"index" json:"user_id"`
+	SubscriptionID uint      `gorm:"index" json:"subscription_id"`
+	Name           string    `gorm:"type:varchar(50)" json:"name"`
+	Regions        string    `gorm:"type:text" json:"regions"`             // JSON: ["香港","日本"]；空表示全部地区
+	Protocols      string    `gorm:"type:text" json:"protocols"`           // JSON: ["hysteria2","vless"]；空表示全部协议
+	ProtocolOrder  string    `gorm:"type:text" json:"protocol_order"`      // JSON: 优先排在前面的协议，如 ["hysteria2"]
+	Include        string    `gorm:"type:varchar(255)" json:"include"`     // 节点名称需匹配的正则
+	Exclude        string    `gorm:"type:varchar(255)" json:"exclude"`     // 节点名称匹配则排除的正则
+	SortBy         string    `gorm:"type:varchar(20)" json:"sort_by"`      // 空（后台顺序）/ name / latency / region
+	HideInfoNodes  bool      `gorm:"default:false" json:"hide_info_nodes"` // 不显示官网、到期时间等信息节点
+	CreatedAt      time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt      time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+func (SubscriptionProfile) TableName() string {
+	return "subscription_profiles"
+}
+
+// UserCustomRules 用户自定义分流规则（Clash 规则语法，每行一条），下发订阅时插入到模板规则之前
+type UserCustomRules struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	UserID    uint      `gorm:"uniqueIndex" json:"user_id"`
+	Rules     string    `gorm:"type:text" json:"rules"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+func (UserCustomRules) TableName() string {
+	return "user_custom_rules"
+}
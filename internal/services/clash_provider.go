@@ -0,0 +1,276 @@
+package services
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+
+	"cboard/v2/internal/models"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ── Clash proxy-providers 模式 ──
+// 主配置只保留信息节点，真实节点通过面板提供的 proxy-providers 下发：
+//   - all：全部节点，模板中所有可注入分组改为 use: [all]
+//   - 每个地区一个 provider（名称即 DetectRegion 的地区名），并追加 "<地区>节点" url-test 分组，
+//     加入第一个 select 分组
+// 节点变化时客户端只需按 interval 刷新 provider，不必重新下载整份配置。
+
+// ClashProviderAll is the provider containing every node.
+const ClashProviderAll = "all"
+
+const clashProviderTestURL = "http://www.gstatic.com/generate_204"
+
+// ClashProviderOptions configures the proxy-providers rendered into the main profile.
+type ClashProviderOptions struct {
+	// URL returns the provider endpoint for "all" or a region name.
+	URL func(provider string) string
+	// Interval is the provider refresh interval in seconds.
+	Interval int
+}
+
+// isInfoNode reports whether a node is an in-memory info node (server baidu.com placeholder), using the same
+// exact server match as buildClashProxies so real nodes whose SNI or host merely contain baidu.com are kept.
+func isInfoNode(node models.Node) bool {
+	if node.Config == nil || *node.Config == "" {
+		return false
+	}
+	m, err := NodeConfigToClashMap(node.Type, *node.Config, node.Name)
+	return err == nil && stringFromMap(m, "server") == "baidu.com"
+}
+
+// ClashProviderRegions returns the regions of the real nodes in first-seen order; unmatched nodes only appear in "all".
+func ClashProviderRegions(nodes []models.Node) []string {
+	var regions []string
+	seen := make(map[string]bool)
+	for _, node := range nodes {
+		if node.Config == nil || *node.Config == "" || isInfoNode(node) {
+			continue
+		}
+		region := DetectRegion(node.Name)
+		if region == "其他" || seen[region] {
+			continue
+		}
+		seen[region] = true
+		regions = append(regions, region)
+	}
+	return regions
+}
+
+// GenerateClashProviderYAML renders a provider payload (only a proxies list) for "all" or a region.
+func GenerateClashProviderYAML(nodes []models.Node, provider string) string {
+	var selected []models.Node
+	for _, node := range nodes {
+		if isInfoNode(node) {
+			continue
+		}
+		if provider != ClashProviderAll && DetectRegion(node.Name) != provider {
+			continue
+		}
+		selected = append(selected, node)
+	}
+	return GenerateClashProxiesYAML(selected)
+}
+
+// GenerateClashProxiesYAML renders nodes as a bare proxies list.
+func GenerateClashProxiesYAML(nodes []models.Node) string {
+	proxies, _, _ := buildClashProxies(nodes)
+	if len(proxies) == 0 {
+		return "proxies: []\n"
+	}
+	var sb strings.Builder
+	sb.WriteString("proxies:\n")
+	for _, p := range proxies {
+		writeClashProxy(&sb, p)
+	}
+	return unescapeUnicode(sb.String())
+}
+
+// GenerateClashYAMLWithProviders renders the main Clash profile in proxy-providers mode, using the same
+// template fallback chain as GenerateClashYAMLWithTemplate.
+func GenerateClashYAMLWithProviders(nodes []models.Node, siteDomain, subscriptionName, template string, opts ClashProviderOptions) string {
+	var infoNodes []models.Node
+	for _, node := range nodes {
+		if isInfoNode(node) {
+			infoNodes = append(infoNodes, node)
+		}
+	}
+	regions := ClashProviderRegions(nodes)
+	proxies, infoNames, _ := buildClashProxies(infoNodes)
+	providers := buildClashProvidersNode(regions, opts)
+	if providers == nil {
+		return ""
+	}
+	updateGroups := func(groupsNode *yaml.Node) {
+		applyClashProviderGroups(groupsNode, infoNames, regions)
+	}
+
+	if template != "" {
+		if result := renderClashTemplate([]byte(template), proxies, subscriptionName, providers, updateGroups); result != "" {
+			return result
+		}
+	}
+	if data, err := os.ReadFile("uploads/config/temp.yaml"); err == nil {
+		if result := renderClashTemplate(data, proxies, subscriptionName, providers, updateGroups); result != "" {
+			return result
+		}
+	}
+	fallback := generateDefaultClashYAML(proxies, infoNames, infoNames, siteDomain, subscriptionName)
+	return renderClashTemplate([]byte(fallback), proxies, subscriptionName, providers, updateGroups)
+}
+
+// buildClashProvidersNode builds the proxy-providers mapping for "all" followed by each region.
+func buildClashProvidersNode(regions []string, opts ClashProviderOptions) *yaml.Node {
+	interval := opts.Interval
+	if interval <= 0 {
+		interval = 3600
+	}
+	// 不同订阅的 provider 缓存文件互不覆盖
+	sum := md5.Sum([]byte(opts.URL(ClashProviderAll)))
+	prefix := hex.EncodeToString(sum[:])[:8]
+
+	var sb strings.Builder
+	for _, name := range append([]string{ClashProviderAll}, regions...) {
+		sb.WriteString(fmt.Sprintf("%s:\n", escapeYAML(name)))
+		sb.WriteString("  type: http\n")
+		sb.WriteString(fmt.Sprintf("  url: %s\n", escapeYAML(opts.URL(name))))
+		sb.WriteString(fmt.Sprintf("  interval: %d\n", interval))
+		sb.WriteString(fmt.Sprintf("  path: %s\n", escapeYAML(fmt.Sprintf("./providers/%s-%s.yaml", prefix, name))))
+		sb.WriteString("  health-check:\n")
+		sb.WriteString("    enable: true\n")
+		sb.WriteString(fmt.Sprintf("    url: %s\n", clashProviderTestURL))
+		sb.WriteString("    interval: 600\n")
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(sb.String()), &doc); err != nil || len(doc.Content) == 0 {
+		return nil
+	}
+	return doc.Content[0]
+}
+
+// setClashProviders replaces the template's proxy-providers, or inserts them before proxy-groups.
+func setClashProviders(root, providers *yaml.Node) {
+	for i := 0; i < len(root.Content)-1; i += 2 {
+		if root.Content[i].Value == "proxy-providers" {
+			root.Content[i+1] = providers
+			return
+		}
+	}
+	key := &yaml.Node{Kind: yaml.ScalarNode, Value: "proxy-providers", Tag: "!!str"}
+	for i := 0; i < len(root.Content)-1; i += 2 {
+		if root.Content[i].Value == "proxy-groups" {
+			root.Content = append(root.Content[:i], append([]*yaml.Node{key, providers}, root.Content[i:]...)...)
+			return
+		}
+	}
+	root.Content = append(root.Content, key, providers)
+}
+
+// applyClashProviderGroups points injectable groups at the "all" provider and appends one url-test group per region.
+func applyClashProviderGroups(groupsNode *yaml.Node, infoNames, regions []string) {
+	groupNames := make(map[string]bool)
+	for _, g := range groupsNode.Content {
+		if name := yamlMappingValue(g, "name"); name != nil {
+			groupNames[name.Value] = true
+		}
+	}
+	var regionGroups, groupRegions []string
+	for _, region := range regions {
+		if name := region + "节点"; !groupNames[name] {
+			regionGroups = append(regionGroups, name)
+			groupRegions = append(groupRegions, region)
+		}
+	}
+
+	firstSelect := true
+	for _, g := range groupsNode.Content {
+		if g.Kind != yaml.MappingNode {
+			continue
+		}
+		gType := ""
+		if t := yamlMappingValue(g, "type"); t != nil {
+			gType = t.Value
+		}
+		oldVal := yamlMappingValue(g, "proxies")
+		if oldVal == nil || (gType != "select" && gType != "url-test" && gType != "fallback" && gType != "load-balance") {
+			continue
+		}
+
+		var items []string
+		if oldVal.Kind == yaml.SequenceNode {
+			for _, item := range oldVal.Content {
+				if item.Kind == yaml.ScalarNode && (item.Value == "DIRECT" || item.Value == "REJECT" || groupNames[item.Value]) {
+					items = append(items, item.Value)
+				}
+			}
+		}
+		if gType == "select" {
+			if firstSelect {
+				items = append(items, regionGroups...)
+				firstSelect = false
+			}
+			items = append(items, infoNames...)
+		}
+		setYAMLMappingValue(g, "proxies", yamlStringSeq(items))
+		if len(items) == 0 {
+			deleteYAMLMappingKey(g, "proxies")
+		}
+		setYAMLMappingValue(g, "use", yamlStringSeq([]string{ClashProviderAll}))
+	}
+
+	for i, name := range regionGroups {
+		var sb strings.Builder
+		sb.WriteString(fmt.Sprintf("name: %s\n", escapeYAML(name)))
+		sb.WriteString("type: url-test\n")
+		sb.WriteString(fmt.Sprintf("use:\n  - %s\n", escapeYAML(groupRegions[i])))
+		sb.WriteString(fmt.Sprintf("url: %s\n", clashProviderTestURL))
+		sb.WriteString("interval: 300\n")
+		sb.WriteString("tolerance: 50\n")
+		var doc yaml.Node
+		if err := yaml.Unmarshal([]byte(sb.String()), &doc); err == nil && len(doc.Content) > 0 {
+			groupsNode.Content = append(groupsNode.Content, doc.Content[0])
+		}
+	}
+}
+
+func yamlMappingValue(m *yaml.Node, key string) *yaml.Node {
+	if m.Kind != yaml.MappingNode {
+		return nil
+	}
+	for j := 0; j < len(m.Content)-1; j += 2 {
+		if m.Content[j].Value == key {
+			return m.Content[j+1]
+		}
+	}
+	return nil
+}
+
+func setYAMLMappingValue(m *yaml.Node, key string, val *yaml.Node) {
+	for j := 0; j < len(m.Content)-1; j += 2 {
+		if m.Content[j].Value == key {
+			m.Content[j+1] = val
+			return
+		}
+	}
+	m.Content = append(m.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key, Tag: "!!str"}, val)
+}
+
+func deleteYAMLMappingKey(m *yaml.Node, key string) {
+	for j := 0; j < len(m.Content)-1; j += 2 {
+		if m.Content[j].Value == key {
+			m.Content = append(m.Content[:j], m.Content[j+2:]...)
+			return
+		}
+	}
+}
+
+func yamlStringSeq(items []string) *yaml.Node {
+	seq := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+	for _, s := range items {
+		seq.Content = append(seq.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: s, Tag: "!!str"})
+	}
+	return seq
+}
@@ -0,0 +1,89 @@
+package services
+
+import (
+	"strings"
+	"testing"
+
+	"cboard/v2/internal/models"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestGenerateClashProviderYAML(t *testing.T) {
+	nodes := templateTestNodes(testTrojanNode("Node X", "x.example.com"))
+	var payload struct {
+		Proxies []map[string]interface{} `yaml:"proxies"`
+	}
+	if err := yaml.Unmarshal([]byte(GenerateClashProviderYAML(nodes, ClashProviderAll)), &payload); err != nil {
+		t.Fatalf("invalid provider YAML: %v", err)
+	}
+	if len(payload.Proxies) != 3 {
+		t.Fatalf("all provider should skip info nodes, got %d proxies", len(payload.Proxies))
+	}
+	hk := GenerateClashProviderYAML(nodes, "香港")
+	if !strings.Contains(hk, "hk.example.com") || strings.Contains(hk, "jp.example.com") {
+		t.Fatalf("unexpected region provider:\n%s", hk)
+	}
+	if got := GenerateClashProviderYAML(nodes, "美国"); got != "proxies: []\n" {
+		t.Fatalf("empty provider should render an empty list, got %q", got)
+	}
+}
+
+func TestGenerateClashYAMLWithProviders(t *testing.T) {
+	template := `proxies: []
+proxy-groups:
+  - name: Proxy
+    type: select
+    proxies: [Auto, DIRECT]
+  - name: Auto
+    type: url-test
+    proxies: []
+rules:
+  - MATCH,Proxy
+`
+	opts := ClashProviderOptions{URL: func(p string) string { return "https://panel.example.com/api/v1/client/provider/t/" + p }}
+	out := GenerateClashYAMLWithProviders(templateTestNodes(testTrojanNode("Node X", "x.example.com")), "", "test", template, opts)
+	var cfg struct {
+		Proxies        []map[string]interface{}          `yaml:"proxies"`
+		ProxyProviders map[string]map[string]interface{} `yaml:"proxy-providers"`
+		ProxyGroups    []struct {
+			Name    string   `yaml:"name"`
+			Proxies []string `yaml:"proxies"`
+			Use     []string `yaml:"use"`
+		} `yaml:"proxy-groups"`
+	}
+	if err := yaml.Unmarshal([]byte(out), &cfg); err != nil {
+		t.Fatalf("invalid YAML: %v\n%s", err, out)
+	}
+	if len(cfg.Proxies) != 1 {
+		t.Fatalf("main profile should only keep info nodes, got %v", cfg.Proxies)
+	}
+	if len(cfg.ProxyProviders) != 3 || cfg.ProxyProviders["日本"]["url"] != "https://panel.example.com/api/v1/client/provider/t/日本" {
+		t.Fatalf("unexpected providers: %v", cfg.ProxyProviders)
+	}
+	if len(cfg.ProxyGroups) != 4 {
+		t.Fatalf("expected 2 template groups plus 2 region groups, got %+v", cfg.ProxyGroups)
+	}
+	proxy, auto := cfg.ProxyGroups[0], cfg.ProxyGroups[1]
+	if strings.Join(proxy.Proxies, ",") != "Auto,DIRECT,香港节点,日本节点,📢 官网" || strings.Join(proxy.Use, ",") != "all" {
+		t.Fatalf("unexpected select group: %+v", proxy)
+	}
+	if len(auto.Proxies) != 0 || strings.Join(auto.Use, ",") != "all" {
+		t.Fatalf("unexpected url-test group: %+v", auto)
+	}
+	if hk := cfg.ProxyGroups[2]; hk.Name != "香港节点" || strings.Join(hk.Use, ",") != "香港" {
+		t.Fatalf("unexpected region group: %+v", hk)
+	}
+}
+
+func TestIsInfoNodeMatchesServerOnly(t *testing.T) {
+	nodes := templateTestNodes()
+	if !isInfoNode(nodes[0]) || isInfoNode(nodes[1]) {
+		t.Fatalf("info node detection is wrong for the fixture")
+	}
+	// 真实节点的 SNI / Host 含 baidu.com 时不能被当作提示节点
+	disguised := "trojan://secret@hk.example.com:443?sni=www.baidu.com#HK"
+	if isInfoNode(models.Node{Name: "香港 02", Type: "trojan", Config: &disguised}) {
+		t.Fatalf("node with baidu.com SNI treated as info node")
+	}
+}
@@ -0,0 +1,184 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"cboard/v2/internal/models"
+
+	"gopkg.in/yaml.v3"
+	"gorm.io/gorm"
+)
+
+// ── 客户端配置模板 ──
+// 模板保存在数据库中并保留历史版本，可按套餐或用户等级指定；解析优先级：
+// 套餐绑定 > 用户等级绑定 > 该格式的默认模板 > uploads/config 下的模板文件 > 内置默认配置。
+// 支持 Clash / Stash（YAML）、sing-box（JSON，占位符见 singbox_template.go）
+// 以及 Surge / Surfboard / Loon / Quantumult X（文本，占位符见 profile_template.go）。
+
+const (
+	ConfigTemplateFormatClash       = "clash"
+	ConfigTemplateFormatStash       = "stash"
+	ConfigTemplateFormatSingBox     = "singbox"
+	ConfigTemplateFormatSurge       = "surge"
+	ConfigTemplateFormatSurfboard   = "surfboard"
+	ConfigTemplateFormatLoon        = "loon"
+	ConfigTemplateFormatQuantumultX = "quantumultx"
+
+	ConfigTemplateTargetPackage   = "package"
+	ConfigTemplateTargetUserLevel = "user_level"
+)
+
+// configTemplateValidators maps each supported template format to its validator.
+var configTemplateValidators = map[string]func(content string) error{
+	ConfigTemplateFormatClash:       validateClashTemplate,
+	ConfigTemplateFormatStash:       validateClashTemplate,
+	ConfigTemplateFormatSingBox:     validateSingBoxTemplate,
+	ConfigTemplateFormatSurge:       profileTemplateValidator(ConfigTemplateFormatSurge),
+	ConfigTemplateFormatSurfboard:   profileTemplateValidator(ConfigTemplateFormatSurfboard),
+	ConfigTemplateFormatLoon:        profileTemplateValidator(ConfigTemplateFormatLoon),
+	ConfigTemplateFormatQuantumultX: profileTemplateValidator(ConfigTemplateFormatQuantumultX),
+}
+
+// IsConfigTemplateFormat reports whether templates of the given format can be managed.
+func IsConfigTemplateFormat(format string) bool {
+	_, ok := configTemplateValidators[format]
+	return ok
+}
+
+// ValidateConfigTemplate checks that a template can be rendered by the generator of its format.
+func ValidateConfigTemplate(format, content string) error {
+	validate, ok := configTemplateValidators[format]
+	if !ok {
+		return fmt.Errorf("不支持的模板格式: %s", format)
+	}
+	if strings.TrimSpace(content) == "" {
+		return errors.New("模板内容不能为空")
+	}
+	return validate(content)
+}
+
+// validateClashTemplate mirrors what generateFromTemplateData needs: a YAML mapping with a proxies key
+// and at least one selectable proxy group that has a proxies list to inject nodes into.
+func validateClashTemplate(content string) error {
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(content), &doc); err != nil {
+		return fmt.Errorf("YAML 解析失败: %v", err)
+	}
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return errors.New("模板根节点必须是 YAML 映射")
+	}
+	root := doc.Content[0]
+	var hasProxies bool
+	var groups *yaml.Node
+	for i := 0; i < len(root.Content)-1; i += 2 {
+		switch root.Content[i].Value {
+		case "proxies":
+			hasProxies = true
+		case "proxy-groups":
+			groups = root.Content[i+1]
+		}
+	}
+	if !hasProxies {
+		return errors.New("模板缺少 proxies 字段（节点将注入到该字段）")
+	}
+	if groups == nil || groups.Kind != yaml.SequenceNode || len(groups.Content) == 0 {
+		return errors.New("模板缺少 proxy-groups 列表")
+	}
+	injectable := false
+	for idx, g := range groups.Content {
+		if g.Kind != yaml.MappingNode {
+			return fmt.Errorf("proxy-groups 第 %d 项不是映射", idx+1)
+		}
+		var name, gType string
+		var hasList bool
+		for j := 0; j < len(g.Content)-1; j += 2 {
+			switch g.Content[j].Value {
+			case "name":
+				name = g.Content[j+1].Value
+			case "type":
+				gType = g.Content[j+1].Value
+			case "proxies":
+				hasList = g.Content[j+1].Kind == yaml.SequenceNode
+			}
+		}
+		if name == "" || gType == "" {
+			return fmt.Errorf("proxy-groups 第 %d 项缺少 name 或 type", idx+1)
+		}
+		switch gType {
+		case "select", "url-test", "fallback", "load-balance":
+			if hasList {
+				injectable = true
+			}
+		}
+	}
+	if !injectable {
+		return errors.New("proxy-groups 中至少需要一个带 proxies 列表的 select / url-test / fallback / load-balance 分组")
+	}
+	return nil
+}
+
+// RenderConfigTemplate renders nodes with a template for previews.
+func RenderConfigTemplate(format, content string, nodes []models.Node, subscriptionName string) (string, error) {
+	if err := ValidateConfigTemplate(format, content); err != nil {
+		return "", err
+	}
+	if format == ConfigTemplateFormatSingBox {
+		outbounds, tags := buildSingBoxOutbounds(nodes)
+		return renderSingBoxTemplate([]byte(content), outbounds, tags)
+	}
+	if f, ok := profileFormats[format]; ok {
+		return renderProfileTemplate(f, content, buildProfileNodes(f, nodes), ""), nil
+	}
+	proxies, proxyNames, realNames := buildClashProxies(nodes)
+	result := generateFromTemplateData([]byte(content), proxies, proxyNames, realNames, subscriptionName)
+	if result == "" {
+		return "", errors.New("模板渲染失败")
+	}
+	return result, nil
+}
+
+// ResolveConfigTemplate returns the template content to use for a subscription, or "" to use the file/built-in fallback.
+func ResolveConfigTemplate(db *gorm.DB, format string, sub *models.Subscription) string {
+	if sub != nil {
+		if sub.PackageID != nil {
+			if content, ok := boundConfigTemplate(db, format, ConfigTemplateTargetPackage, uint(*sub.PackageID)); ok {
+				return content
+			}
+		}
+		var user models.User
+		if db.Select("id, user_level_id").First(&user, sub.UserID).Error == nil && user.UserLevelID != nil {
+			if content, ok := boundConfigTemplate(db, format, ConfigTemplateTargetUserLevel, *user.UserLevelID); ok {
+				return content
+			}
+		}
+	}
+	var tpl models.ConfigTemplate
+	if db.Select("content").Where("format = ? AND is_default = ?", format, true).First(&tpl).Error == nil {
+		return tpl.Content
+	}
+	return ""
+}
+
+func boundConfigTemplate(db *gorm.DB, format, targetType string, targetID uint) (string, bool) {
+	var tpl models.ConfigTemplate
+	err := db.Select("config_templates.content").
+		Joins("JOIN config_template_bindings b ON b.template_id = config_templates.id").
+		Where("b.format = ? AND b.target_type = ? AND b.target_id = ?", format, targetType, targetID).
+		First(&tpl).Error
+	return tpl.Content, err == nil
+}
+
+// SaveConfigTemplateVersion stores the template's current content as a new version and bumps its version number.
+func SaveConfigTemplateVersion(tx *gorm.DB, tpl *models.ConfigTemplate, comment string, createdBy *uint) error {
+	var latest int
+	tx.Model(&models.ConfigTemplateVersion{}).Where("template_id = ?", tpl.ID).Select("COALESCE(MAX(version), 0)").Scan(&latest)
+	tpl.Version = latest + 1
+	if err := tx.Model(&models.ConfigTemplate{}).Where("id = ?", tpl.ID).Update("version", tpl.Version).Error; err != nil {
+		return err
+	}
+	return tx.Create(&models.ConfigTemplateVersion{
+		TemplateID: tpl.ID, Version: tpl.Version, Content: tpl.Content, Comment: comment, CreatedBy: createdBy,
+	}).Error
+}
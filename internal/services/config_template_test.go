@@ -0,0 +1,53 @@
+package services
+
+import (
+	"strings"
+	"testing"
+
+	"cboard/v2/internal/models"
+)
+
+const testClashTemplate = `mixed-port: 7890
+proxies: []
+proxy-groups:
+  - name: 节点选择
+    type: select
+    proxies: [DIRECT]
+rules:
+  - MATCH,节点选择
+`
+
+func TestValidateClashTemplate(t *testing.T) {
+	if err := ValidateConfigTemplate(ConfigTemplateFormatClash, testClashTemplate); err != nil {
+		t.Fatalf("valid template rejected: %v", err)
+	}
+	cases := map[string]string{
+		"empty":         "  ",
+		"not mapping":   "- a\n- b\n",
+		"no proxies":    "proxy-groups:\n  - name: a\n    type: select\n    proxies: [DIRECT]\n",
+		"no groups":     "proxies: []\n",
+		"group name":    "proxies: []\nproxy-groups:\n  - type: select\n    proxies: [DIRECT]\n",
+		"no injectable": "proxies: []\nproxy-groups:\n  - name: a\n    type: relay\n    proxies: [DIRECT]\n",
+		"bad yaml":      "proxies: [\n",
+	}
+	for name, content := range cases {
+		if err := ValidateConfigTemplate(ConfigTemplateFormatClash, content); err == nil {
+			t.Fatalf("%s: expected validation error", name)
+		}
+	}
+	if err := ValidateConfigTemplate("unknown", testClashTemplate); err == nil {
+		t.Fatalf("unknown format accepted")
+	}
+}
+
+func TestRenderConfigTemplateInjectsNodes(t *testing.T) {
+	link := "trojan://secret@hk.example.com:443?sni=hk.example.com#HK-01"
+	nodes := []models.Node{{Name: "HK-01", Type: "trojan", Config: &link}}
+	out, err := RenderConfigTemplate(ConfigTemplateFormatClash, testClashTemplate, nodes, "test")
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	if !strings.Contains(out, "HK-01") {
+		t.Fatalf("rendered config missing node:\n%s", out)
+	}
+}
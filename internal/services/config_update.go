@@ -1,9 +1,14 @@
 package services
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"log"
+	"net/url"
+	"regexp"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -11,6 +16,8 @@ import (
 	"cboard/v2/internal/cache"
 	"cboard/v2/internal/database"
 	"cboard/v2/internal/models"
+
+	"gorm.io/gorm"
 )
 
 type LogEntry struct {
@@ -19,17 +26,58 @@ type LogEntry struct {
 	Level   string `json:"level"` // info, error, success
 }
 
+// configUpdateManualPlaceholder marks where manual nodes are placed among the upstream sources.
+const configUpdateManualPlaceholder = "__MANUAL_NODES__"
+
 type ConfigUpdateConfig struct {
-	URLs     []string `json:"urls"`
-	Keywords []string `json:"keywords"`
-	Enabled  bool     `json:"enabled"`
-	Interval int      `json:"interval"` // minutes
+	// URLs mirrors Sources for clients that only edit the flat URL list; when it disagrees with
+	// Sources it wins, and the settings of sources that are still listed are kept.
+	URLs     []string             `json:"urls"`
+	Sources  []ConfigUpdateSource `json:"sources"`
+	Keywords []string             `json:"keywords"`
+	Enabled  bool                 `json:"enabled"`
+	Interval int                  `json:"interval"` // minutes
+	// 跨来源去重：off / identity / endpoint（见 DedupNodes）
+	Dedup string `json:"dedup"`
+}
+
+// ConfigUpdateSource is one upstream subscription with its own fetch and filter settings.
+type ConfigUpdateSource struct {
+	Name      string            `json:"name"`
+	URL       string            `json:"url"`
+	Enabled   bool              `json:"enabled"`
+	Prefix    string            `json:"prefix"`  // 节点名称前缀
+	Include   string            `json:"include"` // 节点名称需匹配的正则
+	Exclude   string            `json:"exclude"` // 节点名称匹配则排除的正则
+	UserAgent string            `json:"user_agent"`
+	Headers   map[string]string `json:"headers"`
+}
+
+// ConfigUpdateSourceStatus is the result of the last fetch of a source.
+type ConfigUpdateSourceStatus struct {
+	Name       string     `json:"name"`
+	URL        string     `json:"url"`
+	Enabled    bool       `json:"enabled"`
+	LastFetch  *time.Time `json:"last_fetch"`
+	HTTPStatus int        `json:"http_status"`
+	NodeCount  int        `json:"node_count"`
+	LastError  string     `json:"last_error"`
+	// 上次解析中未能生成节点的条目（最多 configUpdateMaxIssues 条）及其总数
+	RejectedCount int              `json:"rejected_count"`
+	Rejected      []NodeParseIssue `json:"rejected"`
 }
 
+const configUpdateMaxIssues = 50
+
+// configUpdateStatusKey stores the per-source fetch results next to the config_update settings.
+const configUpdateStatusKey = "config_update_status"
+
 type ConfigUpdateService struct {
 	mu             sync.Mutex
 	running        bool
 	logs           []LogEntry
+	sourceStatus   map[string]ConfigUpdateSourceStatus // 按 URL 记录，持久化到 config_update_status
+	statusLoaded   bool
 	runStopCh      chan struct{}
 	scheduleStopCh chan struct{}
 	ticker         *time.Ticker
@@ -44,7 +92,8 @@ var (
 func GetConfigUpdateService() *ConfigUpdateService {
 	configUpdateOnce.Do(func() {
 		configUpdateInstance = &ConfigUpdateService{
-			logs: make([]LogEntry, 0),
+			logs:         make([]LogEntry, 0),
+			sourceStatus: make(map[string]ConfigUpdateSourceStatus),
 		}
 	})
 	return configUpdateInstance
@@ -70,6 +119,86 @@ func (s *ConfigUpdateService) GetLogs() []LogEntry {
 	return copied
 }
 
+// SourceStatuses returns the last fetch result of every configured source, in configuration order.
+func (s *ConfigUpdateService) SourceStatuses() []ConfigUpdateSourceStatus {
+	cfg, err := s.LoadConfig()
+	if err != nil {
+		return []ConfigUpdateSourceStatus{}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.loadSourceStatusUnlocked()
+	list := make([]ConfigUpdateSourceStatus, 0, len(cfg.Sources))
+	for _, src := range cfg.Sources {
+		if src.URL == configUpdateManualPlaceholder {
+			continue
+		}
+		status := s.sourceStatus[src.URL]
+		status.Name, status.URL, status.Enabled = src.Name, src.URL, src.Enabled
+		list = append(list, status)
+	}
+	return list
+}
+
+func (s *ConfigUpdateService) recordSourceStatus(src ConfigUpdateSource, httpStatus, nodeCount int, report *NodeParseReport, err error) {
+	now := time.Now()
+	status := ConfigUpdateSourceStatus{LastFetch: &now, HTTPStatus: httpStatus, NodeCount: nodeCount}
+	if err != nil {
+		status.LastError = err.Error()
+	}
+	if report != nil {
+		status.RejectedCount = len(report.Rejected)
+		status.Rejected = report.Rejected
+		if len(status.Rejected) > configUpdateMaxIssues {
+			status.Rejected = status.Rejected[:configUpdateMaxIssues]
+		}
+	}
+	s.mu.Lock()
+	s.loadSourceStatusUnlocked()
+	s.sourceStatus[src.URL] = status
+	data, err := json.Marshal(s.sourceStatus)
+	s.mu.Unlock()
+	if err == nil {
+		err = saveConfigUpdateStatus(string(data))
+	}
+	if err != nil {
+		log.Printf("[ConfigUpdate] 保存来源状态失败: %v", err)
+	}
+}
+
+// loadSourceStatusUnlocked reads the persisted source statuses once, so they survive restarts.
+// Caller must hold s.mu.
+func (s *ConfigUpdateService) loadSourceStatusUnlocked() {
+	if s.statusLoaded {
+		return
+	}
+	s.statusLoaded = true
+	var cfg models.SystemConfig
+	if err := database.GetDB().Where("key = ? AND category = ?", configUpdateStatusKey, "node").First(&cfg).Error; err != nil {
+		return
+	}
+	if err := json.Unmarshal([]byte(cfg.Value), &s.sourceStatus); err != nil {
+		log.Printf("[ConfigUpdate] 读取来源状态失败: %v", err)
+	}
+}
+
+func saveConfigUpdateStatus(value string) error {
+	db := database.GetDB()
+	var existing models.SystemConfig
+	result := db.Where("key = ? AND category = ?", configUpdateStatusKey, "node").First(&existing)
+	if result.Error != nil {
+		return db.Create(&models.SystemConfig{
+			Key:         configUpdateStatusKey,
+			Value:       value,
+			Type:        "json",
+			Category:    "node",
+			DisplayName: "节点自动更新来源状态",
+			Description: "各订阅来源最近一次获取结果",
+		}).Error
+	}
+	return db.Model(&existing).Update("value", value).Error
+}
+
 func (s *ConfigUpdateService) ClearLogs() {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -211,21 +340,33 @@ func (s *ConfigUpdateService) runUpdate() {
 		return
 	}
 
-	if len(cfg.URLs) == 0 {
+	if len(cfg.Sources) == 0 {
 		s.addLog("error", "没有配置订阅URL")
 		return
 	}
 
 	var allNodes []models.Node
+	// 已停用或获取 / 解析失败的来源保留其现有节点，避免停用或上游临时故障导致节点被批量下线；
+	// 按来源地址（SourceKey）而不是位置对应，调整来源顺序后也不会错配
+	keepSources := make(map[string]bool)
+	sourceIndexes := make(map[string]int)
+	prefixes := make(map[int]string)
 	realSourceIdx := 0
+	backfillNodeSourceKeys(database.GetDB(), cfg.Sources)
 
-	for _, u := range cfg.URLs {
-		u = strings.TrimSpace(u)
-		if u == "" || u == "__MANUAL_NODES__" {
+	for _, src := range cfg.Sources {
+		if src.URL == configUpdateManualPlaceholder {
 			continue
 		}
 
 		realSourceIdx++
+		label := src.label()
+		sourceIndexes[src.key()] = realSourceIdx
+		if !src.Enabled {
+			keepSources[src.key()] = true
+			s.addLog("info", fmt.Sprintf("跳过已停用的订阅: %s，保留其现有节点", label))
+			continue
+		}
 
 		// Check stop signal
 		if s.shouldStopRun() {
@@ -233,24 +374,46 @@ func (s *ConfigUpdateService) runUpdate() {
 			return
 		}
 
-		s.addLog("info", fmt.Sprintf("正在获取订阅: %s", u))
-		content, err := FetchSubscriptionContent(u)
+		s.addLog("info", fmt.Sprintf("正在获取订阅: %s", label))
+		content, httpStatus, err := FetchSubscriptionContentWithOptions(src.URL, FetchOptions{UserAgent: src.UserAgent, Headers: src.Headers})
 		if err != nil {
-			s.addLog("error", fmt.Sprintf("获取订阅失败 [%s]: %s", u, err.Error()))
+			keepSources[src.key()] = true
+			s.recordSourceStatus(src, httpStatus, 0, nil, err)
+			s.addLog("error", fmt.Sprintf("获取订阅失败 [%s]: %s，保留其现有节点", label, err.Error()))
 			continue
 		}
 
-		nodes, err := ParseSubscriptionContent(content)
+		nodes, report, err := ParseSubscriptionContentWithReport(content)
+		if err == nil && len(nodes) == 0 {
+			err = fmt.Errorf("未解析到任何节点")
+			if summary := report.Summary(); summary != "" {
+				err = fmt.Errorf("未解析到任何节点（%s）", summary)
+			}
+		}
 		if err != nil {
-			s.addLog("error", fmt.Sprintf("解析节点失败 [%s]: %s", u, err.Error()))
+			keepSources[src.key()] = true
+			s.recordSourceStatus(src, httpStatus, 0, report, err)
+			s.addLog("error", fmt.Sprintf("解析节点失败 [%s]: %s，保留其现有节点", label, err.Error()))
 			continue
 		}
 
+		parsed := len(nodes)
+		nodes = src.filter(nodes)
 		for i := range nodes {
 			nodes[i].SourceIndex = realSourceIdx
+			nodes[i].SourceKey = src.key()
+		}
+		prefixes[realSourceIdx] = src.Prefix
+		s.recordSourceStatus(src, httpStatus, len(nodes), report, nil)
+		if summary := report.Summary(); summary != "" {
+			s.addLog("error", fmt.Sprintf("订阅 %s 中有 %s，详见来源状态", label, summary))
 		}
 
-		s.addLog("info", fmt.Sprintf("从 %s 解析到 %d 个节点", u, len(nodes)))
+		if len(nodes) != parsed {
+			s.addLog("info", fmt.Sprintf("从 %s 解析到 %d 个节点，来源筛选后保留 %d 个", label, parsed, len(nodes)))
+		} else {
+			s.addLog("info", fmt.Sprintf("从 %s 解析到 %d 个节点", label, len(nodes)))
+		}
 		allNodes = append(allNodes, nodes...)
 	}
 
@@ -260,37 +423,39 @@ func (s *ConfigUpdateService) runUpdate() {
 		s.addLog("info", fmt.Sprintf("关键词排除过滤后剩余 %d 个节点", len(allNodes)))
 	}
 
-	if len(allNodes) == 0 {
-		s.addLog("info", "没有找到有效节点，跳过更新")
-		return
-	}
-
 	db := database.GetDB()
 
-	// Delete old auto-imported nodes and reset auto-increment
-	result := db.Where("is_manual = ?", false).Delete(&models.Node{})
-	if result.Error != nil {
-		s.addLog("error", fmt.Sprintf("删除旧节点失败: %v", result.Error))
-		return
+	if cfg.Dedup == NodeDedupIdentity || cfg.Dedup == NodeDedupEndpoint {
+		var dropped []NodeDuplicate
+		allNodes, dropped = DedupNodes(allNodes, cfg.Dedup)
+		if len(dropped) > 0 {
+			names := make([]string, 0, nodeSyncLogNames)
+			for _, d := range dropped {
+				names = appendSyncName(names, fmt.Sprintf("%s（与 %s 重复）", d.Dropped.Name, d.Kept.Name))
+			}
+			s.addLog("info", fmt.Sprintf("去重移除 %d 个重复节点: %s%s", len(dropped), strings.Join(names, "、"), moreNodesSuffix(len(dropped), len(names))))
+		}
 	}
-	s.addLog("info", fmt.Sprintf("已删除 %d 个旧的自动导入节点", result.RowsAffected))
 
-	// Check if there are any manual nodes left
-	var manualCount int64
-	if err := db.Model(&models.Node{}).Where("is_manual = ?", true).Count(&manualCount).Error; err != nil {
-		s.addLog("error", fmt.Sprintf("统计手动节点失败: %v", err))
-		return
+	// 重命名规则作用于所有来源合并后的列表（{index} 按地区全局编号），来源前缀最后添加
+	renamer, err := LoadNodeRenamer(db)
+	if err != nil {
+		s.addLog("error", "加载节点重命名规则失败，本次不重命名: "+err.Error())
 	}
-	if manualCount == 0 {
-		// No nodes left at all, reset the auto-increment sequence
-		if err := db.Exec("DELETE FROM sqlite_sequence WHERE name = 'nodes'").Error; err != nil {
-			s.addLog("error", fmt.Sprintf("重置节点ID序列失败: %v", err))
-		} else {
-			s.addLog("info", "已重置节点ID序列")
-		}
+	if !renamer.Empty() {
+		allNodes = renamer.Apply(allNodes)
+		s.addLog("info", "已应用节点重命名规则")
+	}
+	for i := range allNodes {
+		allNodes[i].Name = prefixes[allNodes[i].SourceIndex] + allNodes[i].Name
 	}
 
-	// Insert new nodes, respecting manual node positions.
+	if len(allNodes) == 0 {
+		s.addLog("info", "没有找到有效节点，跳过更新")
+		return
+	}
+
+	// Assign order_index, respecting manual node positions.
 	// Manual nodes keep their order_index based on the "__MANUAL_NODES__" placeholder
 	// position in the URL list. Auto nodes fill around them.
 	var manualNodes []models.Node
@@ -299,68 +464,105 @@ func (s *ConfigUpdateService) runUpdate() {
 	// Find where __MANUAL_NODES__ placeholder is in the URL list.
 	// Count how many real URLs come before it to determine manual node insertion point.
 	manualInsertAfterSource := -1 // -1 means no placeholder found, default to end
-	realURLIndex := 0
-	for _, u := range cfg.URLs {
-		u = strings.TrimSpace(u)
-		if u == "__MANUAL_NODES__" {
-			manualInsertAfterSource = realURLIndex
+	for i, src := range cfg.Sources {
+		if src.URL == configUpdateManualPlaceholder {
+			manualInsertAfterSource = i
 			break
 		}
-		if u != "" {
-			realURLIndex++
-		}
 	}
 
-	// Count nodes per source to find the insertion point
-	manualInsertAt := len(allNodes) // default: end
-	if manualInsertAfterSource >= 0 {
-		manualInsertAt = 0
-		for _, n := range allNodes {
-			if n.SourceIndex <= manualInsertAfterSource {
-				manualInsertAt++
-			}
+	// 保留来源的现有节点按来源当前位置参与排序，避免沿用的旧序号与本次分配的序号冲突
+	var keptNodes []models.Node
+	if len(keepSources) > 0 {
+		keys := make([]string, 0, len(keepSources))
+		for key := range keepSources {
+			keys = append(keys, key)
 		}
+		db.Where("is_manual = ? AND retired_at IS NULL AND source_key IN ?", false, keys).
+			Order("order_index ASC, id ASC").Find(&keptNodes)
+	}
+	keptBefore := append([]models.Node(nil), keptNodes...)
+	ordered := make([]*models.Node, 0, len(allNodes)+len(keptNodes))
+	for i := range allNodes {
+		ordered = append(ordered, &allNodes[i])
+	}
+	for i := range keptNodes {
+		keptNodes[i].SourceIndex = sourceIndexes[keptNodes[i].SourceKey]
+		ordered = append(ordered, &keptNodes[i])
 	}
 
-	// Assign order_index: auto nodes get sequential slots, manual nodes inserted at manualInsertAt
-	successCount := 0
-	slot := 0
-	for i, node := range allNodes {
-		if i == manualInsertAt {
-			// Reserve slots for manual nodes
-			for j := range manualNodes {
-				if err := db.Model(&models.Node{}).Where("id = ?", manualNodes[j].ID).Update("order_index", slot).Error; err != nil {
-					s.addLog("error", fmt.Sprintf("更新手动节点排序失败(%s): %v", manualNodes[j].Name, err))
-				}
-				slot++
-			}
-		}
-		node.IsManual = false
-		node.OrderIndex = slot
-		if err := db.Create(&node).Error; err == nil {
-			successCount++
-		} else {
-			s.addLog("error", fmt.Sprintf("导入节点失败(%s): %v", node.Name, err))
+	// Auto nodes get sequential slots, manual nodes are placed at the returned slot
+	slot := assignNodeSlots(ordered, manualInsertAfterSource, len(manualNodes))
+	for j := range manualNodes {
+		if err := db.Model(&models.Node{}).Where("id = ?", manualNodes[j].ID).Update("order_index", slot+j).Error; err != nil {
+			s.addLog("error", fmt.Sprintf("更新手动节点排序失败(%s): %v", manualNodes[j].Name, err))
 		}
-		slot++
 	}
-	// If manual nodes go at the very end (or no auto nodes)
-	if manualInsertAt >= len(allNodes) {
-		for j := range manualNodes {
-			if err := db.Model(&models.Node{}).Where("id = ?", manualNodes[j].ID).Update("order_index", slot).Error; err != nil {
-				s.addLog("error", fmt.Sprintf("更新手动节点排序失败(%s): %v", manualNodes[j].Name, err))
-			}
-			slot++
+	for i, node := range keptNodes {
+		if node.SourceIndex == keptBefore[i].SourceIndex && node.OrderIndex == keptBefore[i].OrderIndex {
+			continue
+		}
+		if err := db.Model(&models.Node{}).Where("id = ?", node.ID).
+			Updates(map[string]interface{}{"source_index": node.SourceIndex, "order_index": node.OrderIndex}).Error; err != nil {
+			s.addLog("error", fmt.Sprintf("更新保留节点排序失败(%s): %v", node.Name, err))
 		}
 	}
 
-	s.addLog("success", fmt.Sprintf("更新完成: 共 %d 个节点，成功导入 %d 个", len(allNodes), successCount))
+	// Diff against the existing auto-imported nodes instead of deleting and recreating them,
+	// so node IDs, latency history and admin settings survive the update.
+	summary, err := SyncAutoNodes(db, allNodes, keepSources)
+	if err != nil {
+		s.addLog("error", "同步节点失败: "+err.Error())
+		return
+	}
+	if len(summary.CreatedNames) > 0 {
+		s.addLog("info", "新增节点: "+strings.Join(summary.CreatedNames, "、")+moreNodesSuffix(summary.Created, len(summary.CreatedNames)))
+	}
+	if len(summary.UpdatedNames) > 0 {
+		s.addLog("info", "更新节点: "+strings.Join(summary.UpdatedNames, "、")+moreNodesSuffix(summary.Updated, len(summary.UpdatedNames)))
+	}
+	if len(summary.RetiredNames) > 0 {
+		s.addLog("info", "下线节点: "+strings.Join(summary.RetiredNames, "、")+moreNodesSuffix(summary.Retired, len(summary.RetiredNames)))
+	}
+	s.addLog("success", fmt.Sprintf("更新完成: 上游共 %d 个节点，%s", len(allNodes), summary))
 
 	// 清除所有订阅缓存，确保客户端立即获取最新节点
 	cache.ClearAllSubscriptionCache()
 	s.addLog("info", "已清除订阅缓存")
 }
 
+// assignNodeSlots orders auto nodes by source (keeping their order within a source) and numbers them,
+// leaving manualCount slots after the nodes of the first manualAfterSource sources, or after all nodes when
+// manualAfterSource is negative. It returns the first slot of the manual nodes.
+func assignNodeSlots(nodes []*models.Node, manualAfterSource, manualCount int) int {
+	sort.SliceStable(nodes, func(i, j int) bool { return nodes[i].SourceIndex < nodes[j].SourceIndex })
+	manualAt := len(nodes)
+	if manualAfterSource >= 0 {
+		manualAt = 0
+		for _, n := range nodes {
+			if n.SourceIndex <= manualAfterSource {
+				manualAt++
+			}
+		}
+	}
+	for i, node := range nodes {
+		node.IsManual = false
+		node.OrderIndex = i
+		if i >= manualAt {
+			node.OrderIndex += manualCount
+		}
+	}
+	return manualAt
+}
+
+// moreNodesSuffix notes how many names were left out of a capped log line.
+func moreNodesSuffix(total, shown int) string {
+	if total > shown {
+		return fmt.Sprintf(" 等 %d 个", total)
+	}
+	return ""
+}
+
 func (s *ConfigUpdateService) shouldStopRun() bool {
 	s.mu.Lock()
 	runStopCh := s.runStopCh
@@ -443,6 +645,129 @@ func matchRegionAlias(keyword, name, region string) bool {
 	return false
 }
 
+// key identifies the source on its imported nodes (Node.SourceKey) independently of its position.
+func (src ConfigUpdateSource) key() string {
+	sum := sha256.Sum256([]byte(strings.TrimSpace(src.URL)))
+	return hex.EncodeToString(sum[:8])
+}
+
+// backfillNodeSourceKeys assigns a SourceKey to auto-imported nodes created before nodes carried one, matching
+// them to the source currently at their SourceIndex.
+func backfillNodeSourceKeys(db *gorm.DB, sources []ConfigUpdateSource) {
+	idx := 0
+	for _, src := range sources {
+		if src.URL == configUpdateManualPlaceholder {
+			continue
+		}
+		idx++
+		if err := db.Model(&models.Node{}).Where("is_manual = ? AND source_key = ? AND source_index = ?", false, "", idx).
+			Update("source_key", src.key()).Error; err != nil {
+			log.Printf("[ConfigUpdate] 补全节点来源失败: %v", err)
+			return
+		}
+	}
+}
+
+func (src ConfigUpdateSource) label() string {
+	if src.Name != "" {
+		return src.Name
+	}
+	return src.URL
+}
+
+// filter keeps the nodes matching the source's include / exclude patterns; the prefix is added after renaming.
+func (src ConfigUpdateSource) filter(nodes []models.Node) []models.Node {
+	// 正则在保存时已校验，这里编译失败时忽略该条件
+	include, _ := regexp.Compile(src.Include)
+	exclude, _ := regexp.Compile(src.Exclude)
+	result := make([]models.Node, 0, len(nodes))
+	for _, node := range nodes {
+		if src.Include != "" && include != nil && !include.MatchString(node.Name) {
+			continue
+		}
+		if src.Exclude != "" && exclude != nil && exclude.MatchString(node.Name) {
+			continue
+		}
+		result = append(result, node)
+	}
+	return result
+}
+
+// normalize reconciles Sources with the legacy URL list (see ConfigUpdateConfig.URLs); previous supplies
+// the settings of sources that are only referenced by URL.
+func (cfg *ConfigUpdateConfig) normalize(previous []ConfigUpdateSource) {
+	var urls []string
+	for _, u := range cfg.URLs {
+		if u = strings.TrimSpace(u); u != "" {
+			urls = append(urls, u)
+		}
+	}
+	same := cfg.URLs == nil || len(urls) == len(cfg.Sources)
+	for i := 0; same && i < len(urls); i++ {
+		same = strings.TrimSpace(cfg.Sources[i].URL) == urls[i]
+	}
+	if !same {
+		known := make(map[string]ConfigUpdateSource)
+		for _, list := range [][]ConfigUpdateSource{previous, cfg.Sources} {
+			for _, src := range list {
+				known[strings.TrimSpace(src.URL)] = src
+			}
+		}
+		sources := make([]ConfigUpdateSource, 0, len(urls))
+		for _, u := range urls {
+			src, ok := known[u]
+			if !ok {
+				src = ConfigUpdateSource{URL: u, Enabled: true}
+			}
+			sources = append(sources, src)
+		}
+		cfg.Sources = sources
+	}
+
+	cfg.URLs = make([]string, 0, len(cfg.Sources))
+	sources := cfg.Sources[:0]
+	for _, src := range cfg.Sources {
+		if src.URL = strings.TrimSpace(src.URL); src.URL == "" {
+			continue
+		}
+		sources = append(sources, src)
+		cfg.URLs = append(cfg.URLs, src.URL)
+	}
+	cfg.Sources = sources
+	if cfg.Keywords == nil {
+		cfg.Keywords = []string{}
+	}
+}
+
+// ValidateConfigUpdateConfig checks the URL, patterns and headers of every source.
+func ValidateConfigUpdateConfig(cfg *ConfigUpdateConfig) error {
+	switch cfg.Dedup {
+	case "", NodeDedupOff, NodeDedupIdentity, NodeDedupEndpoint:
+	default:
+		return fmt.Errorf("不支持的去重方式: %s", cfg.Dedup)
+	}
+	for i, src := range cfg.Sources {
+		if src.URL == configUpdateManualPlaceholder {
+			continue
+		}
+		label := fmt.Sprintf("第 %d 个订阅", i+1)
+		if u, err := url.Parse(src.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("%s的地址无效", label)
+		}
+		for name, pattern := range map[string]string{"包含": src.Include, "排除": src.Exclude} {
+			if _, err := regexp.Compile(pattern); err != nil {
+				return fmt.Errorf("%s的%s正则无效: %v", label, name, err)
+			}
+		}
+		for k, v := range src.Headers {
+			if k == "" || strings.ContainsAny(k, " :\r\n") || strings.ContainsAny(v, "\r\n") {
+				return fmt.Errorf("%s的请求头无效: %s", label, k)
+			}
+		}
+	}
+	return nil
+}
+
 func (s *ConfigUpdateService) LoadConfig() (*ConfigUpdateConfig, error) {
 	db := database.GetDB()
 	var cfg models.SystemConfig
@@ -450,6 +775,7 @@ func (s *ConfigUpdateService) LoadConfig() (*ConfigUpdateConfig, error) {
 	if result.Error != nil {
 		return &ConfigUpdateConfig{
 			URLs:     []string{},
+			Sources:  []ConfigUpdateSource{},
 			Keywords: []string{},
 			Enabled:  false,
 			Interval: 60,
@@ -459,9 +785,20 @@ func (s *ConfigUpdateService) LoadConfig() (*ConfigUpdateConfig, error) {
 	if err := json.Unmarshal([]byte(cfg.Value), &config); err != nil {
 		return nil, err
 	}
+	config.normalize(nil)
 	return &config, nil
 }
 
+// PrepareConfig normalizes a submitted configuration against the stored one and validates it.
+func (s *ConfigUpdateService) PrepareConfig(config *ConfigUpdateConfig) error {
+	var previous []ConfigUpdateSource
+	if current, err := s.LoadConfig(); err == nil {
+		previous = current.Sources
+	}
+	config.normalize(previous)
+	return ValidateConfigUpdateConfig(config)
+}
+
 func (s *ConfigUpdateService) SaveConfig(config *ConfigUpdateConfig) error {
 	db := database.GetDB()
 	data, err := json.Marshal(config)
@@ -0,0 +1,98 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"cboard/v2/internal/database"
+	"cboard/v2/internal/models"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestConfigUpdateNormalize(t *testing.T) {
+	previous := []ConfigUpdateSource{{URL: "https://a.example.com/sub", Name: "A", Prefix: "[A] ", Enabled: true}}
+
+	// 旧版前端只提交 urls：保留仍在列表中的来源设置，新地址默认启用
+	cfg := ConfigUpdateConfig{URLs: []string{" https://b.example.com/sub", "", "__MANUAL_NODES__", "https://a.example.com/sub"}}
+	cfg.normalize(previous)
+	if len(cfg.Sources) != 3 || cfg.Sources[0].URL != "https://b.example.com/sub" || !cfg.Sources[0].Enabled {
+		t.Fatalf("sources = %+v", cfg.Sources)
+	}
+	if cfg.Sources[2].Name != "A" || cfg.Sources[2].Prefix != "[A] " {
+		t.Fatalf("settings of the existing source were lost: %+v", cfg.Sources[2])
+	}
+	if len(cfg.URLs) != 3 || cfg.URLs[1] != "__MANUAL_NODES__" {
+		t.Fatalf("urls = %v", cfg.URLs)
+	}
+
+	// 新版前端提交 sources（urls 与之一致或省略）时以 sources 为准
+	cfg = ConfigUpdateConfig{Sources: []ConfigUpdateSource{{URL: "https://c.example.com/sub", Enabled: false}}}
+	cfg.normalize(previous)
+	if len(cfg.Sources) != 1 || cfg.Sources[0].Enabled || cfg.URLs[0] != "https://c.example.com/sub" {
+		t.Fatalf("sources = %+v, urls = %v", cfg.Sources, cfg.URLs)
+	}
+}
+
+func TestConfigUpdateSourceFilter(t *testing.T) {
+	src := ConfigUpdateSource{Prefix: "[A] ", Include: "香港|日本", Exclude: "(?i)test"}
+	nodes := []models.Node{{Name: "香港 01"}, {Name: "日本 TEST"}, {Name: "美国 01"}, {Name: "日本 02"}}
+	got := src.filter(nodes)
+	if len(got) != 2 || got[0].Name != "香港 01" || got[1].Name != "日本 02" {
+		t.Fatalf("filter = %+v", got)
+	}
+
+	bad := ConfigUpdateConfig{Sources: []ConfigUpdateSource{{URL: "https://a.example.com", Include: "("}}}
+	if err := ValidateConfigUpdateConfig(&bad); err == nil {
+		t.Fatalf("invalid include pattern accepted")
+	}
+	bad = ConfigUpdateConfig{Sources: []ConfigUpdateSource{{URL: "https://a.example.com", Headers: map[string]string{"X-Token": "a\r\nb"}}}}
+	if err := ValidateConfigUpdateConfig(&bad); err == nil {
+		t.Fatalf("header with CRLF accepted")
+	}
+}
+
+func TestAssignNodeSlotsPlacesKeptNodes(t *testing.T) {
+	// 来源 2 本次获取失败：其旧节点（序号 0、1）与来源 1、3 的新节点一起按来源顺序重新编号
+	nodes := []*models.Node{
+		{Name: "a1", SourceIndex: 1}, {Name: "a2", SourceIndex: 1}, {Name: "c1", SourceIndex: 3},
+		{Name: "b1", SourceIndex: 2, OrderIndex: 0}, {Name: "b2", SourceIndex: 2, OrderIndex: 1},
+	}
+	manualAt := assignNodeSlots(nodes, 2, 2)
+	var got []string
+	for _, n := range nodes {
+		got = append(got, fmt.Sprintf("%s=%d", n.Name, n.OrderIndex))
+	}
+	if want := "a1=0 a2=1 b1=2 b2=3 c1=6"; strings.Join(got, " ") != want || manualAt != 4 {
+		t.Fatalf("slots = %s (manual at %d), want %s (manual at 4)", strings.Join(got, " "), manualAt, want)
+	}
+}
+
+func TestConfigUpdateSourceStatusPersists(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	if err := db.AutoMigrate(&models.SystemConfig{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	previous := database.DB
+	database.DB = db
+	t.Cleanup(func() { database.DB = previous })
+
+	src := ConfigUpdateSource{URL: "https://a.example.com/sub", Enabled: true}
+	first := &ConfigUpdateService{sourceStatus: make(map[string]ConfigUpdateSourceStatus)}
+	first.recordSourceStatus(src, 502, 0, nil, fmt.Errorf("upstream down"))
+
+	// 重启后的新实例从配置表读取上次结果
+	restarted := &ConfigUpdateService{sourceStatus: make(map[string]ConfigUpdateSourceStatus)}
+	restarted.mu.Lock()
+	restarted.loadSourceStatusUnlocked()
+	status := restarted.sourceStatus[src.URL]
+	restarted.mu.Unlock()
+	if status.HTTPStatus != 502 || status.LastError != "upstream down" || status.LastFetch == nil {
+		t.Fatalf("persisted status = %+v", status)
+	}
+}
@@ -0,0 +1,159 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"cboard/v2/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// ── 订阅转换 ──
+// 将任意上游订阅（链接 / Clash YAML / JSON 节点列表）转换为指定客户端格式，供客服排查第三方配置。
+// 节点可先按 include / exclude 正则筛选，再按重命名规则改名；重命名规则每行一条 "正则@替换"，
+// 也可用 "`" 分隔多条（与 subconverter 的 rename 参数一致），替换内容支持 ${1} 等分组引用。
+
+// ConvertTarget describes an output format of the subscription converter.
+type ConvertTarget struct {
+	Format      string
+	ContentType string
+	FileSuffix  string
+}
+
+var convertTargets = map[string]ConvertTarget{
+	"clash":        {"clash", "text/yaml; charset=utf-8", ".yaml"},
+	"stash":        {"stash", "text/yaml; charset=utf-8", ".yaml"},
+	"surge":        {"surge", "text/plain; charset=utf-8", ".conf"},
+	"surfboard":    {"surfboard", "text/plain; charset=utf-8", ".conf"},
+	"loon":         {"loon", "text/plain; charset=utf-8", ".conf"},
+	"quantumultx":  {"quantumultx", "text/plain; charset=utf-8", ".conf"},
+	"singbox":      {"singbox", "application/json; charset=utf-8", ".json"},
+	"xray":         {"xray", "application/json; charset=utf-8", ".json"},
+	"egern":        {"egern", "text/yaml; charset=utf-8", ".yaml"},
+	"shadowrocket": {"shadowrocket", "text/plain; charset=utf-8", ""},
+	"base64":       {"base64", "text/plain; charset=utf-8", ""},
+}
+
+var convertTargetAliases = map[string]string{
+	"mihomo": "clash", "clashmeta": "clash", "clash-meta": "clash",
+	"classmeta":  "clash", // 旧版订阅参数的拼写，仅为兼容保留
+	"quantumult": "quantumultx", "quanx": "quantumultx",
+	"sing-box": "singbox", "xray-json": "xray",
+	"v2ray": "base64", "universal": "base64",
+}
+
+// LookupConvertTarget resolves a target format name (case-insensitive, aliases allowed).
+func LookupConvertTarget(name string) (ConvertTarget, bool) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if alias, ok := convertTargetAliases[name]; ok {
+		name = alias
+	}
+	t, ok := convertTargets[name]
+	return t, ok
+}
+
+type renameRule struct {
+	pattern     *regexp.Regexp
+	replacement string
+}
+
+// parseRenameRules parses "pattern@replacement" rules separated by newlines or "`".
+func parseRenameRules(rules string) ([]renameRule, error) {
+	var parsed []renameRule
+	for _, line := range strings.FieldsFunc(rules, func(r rune) bool { return r == '\n' || r == '`' }) {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		idx := strings.LastIndex(line, "@")
+		if idx <= 0 {
+			return nil, fmt.Errorf("重命名规则格式错误（应为 正则@替换）: %s", line)
+		}
+		re, err := regexp.Compile(line[:idx])
+		if err != nil {
+			return nil, fmt.Errorf("重命名规则正则无效 %q: %v", line[:idx], err)
+		}
+		parsed = append(parsed, renameRule{pattern: re, replacement: line[idx+1:]})
+	}
+	return parsed, nil
+}
+
+// FilterAndRenameNodes keeps nodes whose names match include and not exclude, then applies the rename rules in order.
+func FilterAndRenameNodes(nodes []models.Node, include, exclude, rename string) ([]models.Node, error) {
+	var includeRe, excludeRe *regexp.Regexp
+	var err error
+	if include != "" {
+		if includeRe, err = regexp.Compile(include); err != nil {
+			return nil, fmt.Errorf("include 正则无效: %v", err)
+		}
+	}
+	if exclude != "" {
+		if excludeRe, err = regexp.Compile(exclude); err != nil {
+			return nil, fmt.Errorf("exclude 正则无效: %v", err)
+		}
+	}
+	rules, err := parseRenameRules(rename)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]models.Node, 0, len(nodes))
+	for _, node := range nodes {
+		if includeRe != nil && !includeRe.MatchString(node.Name) {
+			continue
+		}
+		if excludeRe != nil && excludeRe.MatchString(node.Name) {
+			continue
+		}
+		name := node.Name
+		for _, rule := range rules {
+			name = rule.pattern.ReplaceAllString(name, rule.replacement)
+		}
+		// 规则把名称替换为空时保留原名，避免生成无名节点
+		if name = strings.TrimSpace(name); name != "" {
+			node.Name = name
+		}
+		result = append(result, node)
+	}
+	return result, nil
+}
+
+// ConvertNodes renders nodes in the target format using the default config templates.
+func ConvertNodes(db *gorm.DB, nodes []models.Node, target ConvertTarget, name string) (string, error) {
+	var content string
+	switch target.Format {
+	case "clash":
+		content = GenerateClashYAMLWithTemplate(nodes, "", name, ResolveConfigTemplate(db, ConfigTemplateFormatClash, nil))
+	case "stash":
+		content = GenerateStashYAMLWithTemplate(nodes, "", name,
+			ResolveConfigTemplate(db, ConfigTemplateFormatStash, nil),
+			ResolveConfigTemplate(db, ConfigTemplateFormatClash, nil))
+	case "surge":
+		content = GenerateSurgeConfigWithTemplate(nodes, name, ResolveConfigTemplate(db, ConfigTemplateFormatSurge, nil))
+	case "surfboard":
+		content = GenerateSurfboardConfigWithTemplate(nodes, name, ResolveConfigTemplate(db, ConfigTemplateFormatSurfboard, nil))
+	case "loon":
+		content = GenerateLoonConfigWithTemplate(nodes, name, ResolveConfigTemplate(db, ConfigTemplateFormatLoon, nil))
+	case "quantumultx":
+		content = GenerateQuantumultXConfigWithTemplate(nodes, name, ResolveConfigTemplate(db, ConfigTemplateFormatQuantumultX, nil))
+	case "singbox":
+		content = GenerateSingBoxConfigWithTemplate(nodes, ResolveConfigTemplate(db, ConfigTemplateFormatSingBox, nil))
+	case "xray":
+		content = GenerateXrayConfig(nodes, name)
+	case "egern":
+		content = GenerateEgernConfig(nodes)
+	case "shadowrocket":
+		content = GenerateShadowrocketBase64(nodes)
+	case "base64":
+		content = GenerateUniversalBase64(nodes)
+	default:
+		return "", fmt.Errorf("不支持的目标格式: %s", target.Format)
+	}
+	if content == "" {
+		return "", errors.New("转换失败")
+	}
+	return content, nil
+}
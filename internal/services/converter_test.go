@@ -0,0 +1,41 @@
+package services
+
+import (
+	"testing"
+
+	"cboard/v2/internal/models"
+)
+
+func TestFilterAndRenameNodes(t *testing.T) {
+	nodes := []models.Node{{Name: "HK 01 | 1x"}, {Name: "JP 01 | 2x"}, {Name: "官网 example.com"}}
+	got, err := FilterAndRenameNodes(nodes, `\d+x`, "^JP", `\s*\|\s*(\d+)x$@ [${1}倍]`+"`"+`^HK@香港`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(got) != 1 || got[0].Name != "香港 01 [1倍]" {
+		t.Fatalf("unexpected result: %+v", got)
+	}
+	if got, _ := FilterAndRenameNodes(nodes[:1], "", "", ".*@"); got[0].Name != "HK 01 | 1x" {
+		t.Fatalf("empty rename result should keep the original name, got %q", got[0].Name)
+	}
+	if _, err := FilterAndRenameNodes(nodes, "", "", "no-separator"); err == nil {
+		t.Fatalf("expected error for rule without @")
+	}
+	if _, err := FilterAndRenameNodes(nodes, "(", "", ""); err == nil {
+		t.Fatalf("expected error for invalid include regex")
+	}
+}
+
+func TestLookupConvertTarget(t *testing.T) {
+	if target, ok := LookupConvertTarget(" Sing-Box "); !ok || target.Format != "singbox" || target.FileSuffix != ".json" {
+		t.Fatalf("unexpected target: %+v %v", target, ok)
+	}
+	for _, alias := range []string{"clashmeta", "classmeta", "mihomo"} {
+		if target, ok := LookupConvertTarget(alias); !ok || target.Format != "clash" {
+			t.Fatalf("alias %s should resolve to clash, got %+v", alias, target)
+		}
+	}
+	if _, ok := LookupConvertTarget("unknown"); ok {
+		t.Fatalf("unknown target should not resolve")
+	}
+}
@@ -0,0 +1,219 @@
+package services
+
+import (
+	"bytes"
+
+	"cboard/v2/internal/models"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ── Egern ──
+// Egern 使用 YAML 配置：proxies 每项以协议名为键，policy_groups / rules 同理。
+// 支持 shadowsocks、vmess、vless（TLS，不含 REALITY）、trojan、hysteria2（不含 obfs）、tuic、http、socks5，
+// 传输层支持 tcp / ws；其余节点在生成时跳过。
+
+// GenerateEgernConfig generates an Egern YAML profile with region groups.
+func GenerateEgernConfig(nodes []models.Node) string {
+	var proxies []interface{}
+	p := collectProfileNodes(nodes, func(node models.Node, name string) bool {
+		m, err := NodeConfigToClashMap(node.Type, *node.Config, node.Name)
+		if err != nil {
+			return false
+		}
+		proxy := clashMapToEgernProxy(name, m)
+		if proxy == nil {
+			return false
+		}
+		proxies = append(proxies, proxy)
+		return true
+	})
+
+	selectPolicies := []string{}
+	var groups []interface{}
+	if len(p.names) > 0 {
+		selectPolicies = append(selectPolicies, "AutoTest")
+		selectPolicies = append(selectPolicies, p.regions...)
+	}
+	selectPolicies = append(selectPolicies, "DIRECT")
+	selectPolicies = append(selectPolicies, p.names...)
+	groups = append(groups, map[string]interface{}{
+		"select": map[string]interface{}{"name": "Proxy", "policies": selectPolicies},
+	})
+	if len(p.names) > 0 {
+		groups = append(groups, egernAutoTestGroup("AutoTest", p.names))
+		for _, region := range p.regions {
+			groups = append(groups, egernAutoTestGroup(region, p.members[region]))
+		}
+	}
+	if proxies == nil {
+		proxies = []interface{}{}
+	}
+
+	config := map[string]interface{}{
+		"proxies":       proxies,
+		"policy_groups": groups,
+		"rules": []interface{}{
+			map[string]interface{}{"domain_set": map[string]interface{}{"match": "https://cdn.jsdelivr.net/gh/Loyalsoldier/surge-rules@release/reject.txt", "policy": "REJECT"}},
+			map[string]interface{}{"domain_set": map[string]interface{}{"match": "https://cdn.jsdelivr.net/gh/Loyalsoldier/surge-rules@release/proxy.txt", "policy": "Proxy"}},
+			map[string]interface{}{"domain_set": map[string]interface{}{"match": "https://cdn.jsdelivr.net/gh/Loyalsoldier/surge-rules@release/direct.txt", "policy": "DIRECT"}},
+			map[string]interface{}{"rule_set": map[string]interface{}{"match": "https://cdn.jsdelivr.net/gh/Loyalsoldier/surge-rules@release/cncidr.txt", "policy": "DIRECT", "no_resolve": true}},
+			map[string]interface{}{"geoip": map[string]interface{}{"match": "CN", "policy": "DIRECT", "no_resolve": true}},
+			map[string]interface{}{"default": map[string]interface{}{"policy": "Proxy"}},
+		},
+	}
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(config); err != nil {
+		return ""
+	}
+	enc.Close()
+	return unescapeUnicode(buf.String())
+}
+
+func egernAutoTestGroup(name string, policies []string) map[string]interface{} {
+	return map[string]interface{}{
+		"auto_test": map[string]interface{}{
+			"name": name, "policies": policies, "interval": 600, "tolerance": 50,
+		},
+	}
+}
+
+// clashMapToEgernProxy converts a Clash proxy map to an Egern proxy entry, or nil when Egern cannot use it.
+func clashMapToEgernProxy(name string, m map[string]interface{}) map[string]interface{} {
+	typ, _ := m["type"].(string)
+	server, _ := m["server"].(string)
+	port := clashMapPortInt(m)
+	if server == "" || port == 0 {
+		return nil
+	}
+	p := map[string]interface{}{"name": name, "server": server, "port": port}
+	skipVerify, _ := m["skip-cert-verify"].(bool)
+	kind := ""
+	switch typ {
+	case "ss":
+		if _, ok := m["plugin"]; ok {
+			return nil
+		}
+		kind = "shadowsocks"
+		p["method"], _ = m["cipher"].(string)
+		p["password"], _ = m["password"].(string)
+		p["udp_relay"] = true
+	case "vmess", "vless":
+		if _, ok := m["reality-opts"]; ok {
+			return nil
+		}
+		kind = typ
+		p["user_id"], _ = m["uuid"].(string)
+		if typ == "vmess" {
+			security, _ := m["cipher"].(string)
+			if security == "" {
+				security = "auto"
+			}
+			p["security"] = security
+		} else if flow, _ := m["flow"].(string); flow != "" {
+			p["flow"] = flow
+		}
+		tls, _ := m["tls"].(bool)
+		transport, ok := egernTransport(m, tls, skipVerify)
+		if !ok {
+			return nil
+		}
+		if transport != nil {
+			p["transport"] = transport
+		}
+	case "trojan":
+		kind = "trojan"
+		p["password"], _ = m["password"].(string)
+		if sni := loonGetSNI(m); sni != "" {
+			p["sni"] = sni
+		}
+		if skipVerify {
+			p["skip_tls_verify"] = true
+		}
+		switch network, _ := m["network"].(string); network {
+		case "", "tcp":
+		case "ws":
+			path, host := extractWSParams(m)
+			ws := map[string]interface{}{"path": path}
+			if host != "" {
+				ws["host"] = host
+			}
+			p["websocket"] = ws
+		default:
+			return nil
+		}
+	case "hysteria2":
+		// 未对接 salamander 混淆参数，带 obfs 的节点直接跳过，避免生成无法连接的配置
+		if obfs, _ := m["obfs"].(string); obfs != "" {
+			return nil
+		}
+		kind = "hysteria2"
+		p["auth"], _ = m["password"].(string)
+		if sni := loonGetSNI(m); sni != "" {
+			p["sni"] = sni
+		}
+		if skipVerify {
+			p["skip_tls_verify"] = true
+		}
+	case "tuic":
+		kind = "tuic"
+		p["uuid"], _ = m["uuid"].(string)
+		p["password"], _ = m["password"].(string)
+		if sni := loonGetSNI(m); sni != "" {
+			p["sni"] = sni
+		}
+		if alpn := stringSliceFromValue(m["alpn"]); len(alpn) > 0 {
+			p["alpn"] = alpn
+		}
+		if skipVerify {
+			p["skip_tls_verify"] = true
+		}
+	case "socks5", "http":
+		if tls, _ := m["tls"].(bool); tls {
+			return nil
+		}
+		kind = typ
+		if user, _ := m["username"].(string); user != "" {
+			p["username"] = user
+			p["password"], _ = m["password"].(string)
+		}
+	default:
+		return nil
+	}
+	return map[string]interface{}{kind: p}
+}
+
+// egernTransport builds the vmess/vless transport block; ok is false for unsupported networks.
+func egernTransport(m map[string]interface{}, tls, skipVerify bool) (map[string]interface{}, bool) {
+	tlsOpts := map[string]interface{}{}
+	if sni, _ := m["servername"].(string); sni != "" {
+		tlsOpts["sni"] = sni
+	}
+	if skipVerify {
+		tlsOpts["skip_tls_verify"] = true
+	}
+	switch network, _ := m["network"].(string); network {
+	case "", "tcp":
+		if !tls {
+			return nil, true
+		}
+		return map[string]interface{}{"tls": tlsOpts}, true
+	case "ws":
+		path, host := extractWSParams(m)
+		ws := map[string]interface{}{"path": path}
+		if host != "" {
+			ws["headers"] = map[string]interface{}{"Host": host}
+		}
+		if !tls {
+			return map[string]interface{}{"ws": ws}, true
+		}
+		for k, v := range tlsOpts {
+			ws[k] = v
+		}
+		return map[string]interface{}{"wss": ws}, true
+	}
+	return nil, false
+}
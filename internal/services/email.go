@@ -291,6 +291,16 @@ func RenderEmail(templateName string, data map[string]string) (subject, htmlBody
 		subject = fmt.Sprintf("订阅地址已重置 - %s", siteName)
 		resetTime := time.Now().Format("2006-01-02 15:04:05")
 		htmlBody = builder.GetSubscriptionResetTemplate(data["username"], data["universal_url"], data["clash_url"], data["expire_time"], resetTime, data["reset_by"])
+	case "subscription_leak":
+		subject = fmt.Sprintf("订阅安全提醒 - %s", siteName)
+		htmlBody = builder.GetBroadcastNotificationTemplate("订阅疑似泄露", fmt.Sprintf(
+			"<p>您好 %s，您的订阅在最近 %s内被 %s 个不同 IP 拉取，疑似已泄露或被分享。</p><p>如非本人操作，请尽快在官网重置订阅地址，以免影响正常使用。</p>",
+			html.EscapeString(data["username"]), html.EscapeString(data["window"]), html.EscapeString(data["ip_count"])))
+	case "subscription_resumed":
+		subject = fmt.Sprintf("订阅已自动恢复 - %s", siteName)
+		htmlBody = builder.GetBroadcastNotificationTemplate("订阅已自动恢复", fmt.Sprintf(
+			"<p>您好，您暂停的订阅已用完本年度暂停额度，系统已自动恢复。</p><p>暂停期间的时长已顺延，新的到期时间为 %s。</p>",
+			html.EscapeString(data["expire_time"])))
 	case "abnormal_login":
 		subject = fmt.Sprintf("异常登录提醒 - %s", siteName)
 		htmlBody = builder.GetAbnormalLoginAlertTemplate(data["username"], data["time"], data["ip"], data["location"], true, true)
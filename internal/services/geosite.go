@@ -0,0 +1,358 @@
+package services
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ── geosite 规则集 ──
+// 读取 uploads/config/geosite.dat（v2fly domain-list-community 的 protobuf 格式），按分类输出：
+//   - clash：rule-provider（behavior: classical）
+//   - singbox-source：sing-box source 格式 JSON rule-set（version 2），客户端引用时需设置 "format": "source"；
+//     不提供编译后的 .srs 二进制规则集（旧格式名 singbox 仍可使用）
+//   - surge / loon：RULE-SET 列表（不支持正则，正则条目跳过）
+//   - quanx：filter_remote 列表（每行带策略，默认 proxy）
+// 分类名不区分大小写，"名称@属性" 只取带该属性的域名（如 google@cn）。
+// 文件修改后（mtime / 大小变化）自动重建索引并清空已生成的结果。
+
+const (
+	geositeFile          = "uploads/config/geosite.dat"
+	geositeRenderedLimit = 256
+)
+
+// geosite 域名类型（routercommon.Domain.Type）
+const (
+	geositeTypePlain  = 0 // 关键字
+	geositeTypeRegex  = 1
+	geositeTypeDomain = 2 // 域名及其子域名
+	geositeTypeFull   = 3 // 完整匹配
+)
+
+// GeositeFormats lists the output formats supported by RenderGeositeRuleSet.
+var GeositeFormats = []string{"clash", "singbox-source", "surge", "loon", "quanx"}
+
+// geositeFormatAliases maps legacy format names to GeositeFormats entries.
+var geositeFormatAliases = map[string]string{"singbox": "singbox-source"}
+
+// NormalizeGeositeFormat resolves a requested format (aliases allowed) to one of GeositeFormats.
+func NormalizeGeositeFormat(format string) (string, bool) {
+	format = strings.ToLower(strings.TrimSpace(format))
+	if alias, ok := geositeFormatAliases[format]; ok {
+		format = alias
+	}
+	for _, f := range GeositeFormats {
+		if f == format {
+			return format, true
+		}
+	}
+	return "", false
+}
+
+// ErrGeositeCategoryNotFound is returned for categories missing from geosite.dat.
+var ErrGeositeCategoryNotFound = errors.New("geosite 分类不存在")
+
+type geositeDomain struct {
+	kind  uint64
+	value string
+	attrs []string
+}
+
+type geositeCache struct {
+	mu       sync.Mutex
+	modTime  time.Time
+	size     int64
+	entries  map[string][]byte // 小写分类名 -> GeoSite 消息原始字节
+	rendered map[string]string
+}
+
+var geosite geositeCache
+
+// GeositeCategory describes one category of geosite.dat.
+type GeositeCategory struct {
+	Name    string `json:"name"`
+	Domains int    `json:"domains"`
+}
+
+// load (re)indexes geosite.dat when the file changed; callers hold g.mu.
+func (g *geositeCache) load() error {
+	info, err := os.Stat(geositeFile)
+	if err != nil {
+		return fmt.Errorf("geosite.dat 不可用: %w", err)
+	}
+	if g.entries != nil && info.ModTime().Equal(g.modTime) && info.Size() == g.size {
+		return nil
+	}
+	data, err := os.ReadFile(geositeFile)
+	if err != nil {
+		return fmt.Errorf("读取 geosite.dat 失败: %w", err)
+	}
+	entries := make(map[string][]byte)
+	err = readProtoFields(data, func(num int, _ uint64, entry []byte) error {
+		if num != 1 {
+			return nil
+		}
+		var code string
+		if err := readProtoFields(entry, func(num int, _ uint64, b []byte) error {
+			if num == 1 {
+				code = strings.ToLower(string(b))
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+		if code != "" {
+			entries[code] = entry
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("解析 geosite.dat 失败: %w", err)
+	}
+	g.entries, g.modTime, g.size = entries, info.ModTime(), info.Size()
+	g.rendered = make(map[string]string)
+	return nil
+}
+
+// GeositeModTime returns the modification time of geosite.dat, for HTTP caching headers.
+func GeositeModTime() time.Time {
+	geosite.mu.Lock()
+	defer geosite.mu.Unlock()
+	return geosite.modTime
+}
+
+// ListGeositeCategories returns every category with its domain count, sorted by name.
+func ListGeositeCategories() ([]GeositeCategory, error) {
+	geosite.mu.Lock()
+	defer geosite.mu.Unlock()
+	if err := geosite.load(); err != nil {
+		return nil, err
+	}
+	list := make([]GeositeCategory, 0, len(geosite.entries))
+	for name, entry := range geosite.entries {
+		count := 0
+		readProtoFields(entry, func(num int, _ uint64, _ []byte) error {
+			if num == 2 {
+				count++
+			}
+			return nil
+		})
+		list = append(list, GeositeCategory{Name: name, Domains: count})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list, nil
+}
+
+// RenderGeositeRuleSet renders a category ("name" or "name@attr") in the given format; results are cached
+// until geosite.dat changes. policy is only used by the quanx format.
+func RenderGeositeRuleSet(category, format, policy string) (string, error) {
+	category = strings.ToLower(strings.TrimSpace(category))
+	name, attr, _ := strings.Cut(category, "@")
+	resolved, ok := NormalizeGeositeFormat(format)
+	if !ok {
+		return "", fmt.Errorf("不支持的规则集格式: %s", format)
+	}
+	format = resolved
+	// 只有 quanx 的输出带策略，其余格式不按策略区分缓存
+	if format != "quanx" {
+		policy = ""
+	} else if policy == "" {
+		policy = "proxy"
+	}
+
+	geosite.mu.Lock()
+	defer geosite.mu.Unlock()
+	if err := geosite.load(); err != nil {
+		return "", err
+	}
+	key := category + "|" + format + "|" + policy
+	if result, ok := geosite.rendered[key]; ok {
+		return result, nil
+	}
+	entry, ok := geosite.entries[name]
+	if !ok {
+		return "", ErrGeositeCategoryNotFound
+	}
+	domains, err := decodeGeositeDomains(entry, attr)
+	if err != nil {
+		return "", err
+	}
+
+	var result string
+	switch format {
+	case "clash":
+		result = renderGeositeClash(domains)
+	case "singbox-source":
+		result, err = renderGeositeSingBox(domains)
+	case "surge", "loon":
+		result = renderGeositeList(domains, "DOMAIN", "DOMAIN-SUFFIX", "DOMAIN-KEYWORD", "")
+	case "quanx":
+		result = renderGeositeList(domains, "HOST", "HOST-SUFFIX", "HOST-KEYWORD", ","+policy)
+	default:
+		return "", fmt.Errorf("不支持的规则集格式: %s", format)
+	}
+	if err != nil {
+		return "", err
+	}
+	// 分类名后的属性与策略来自请求参数，限制缓存条目数防止被刷爆内存
+	if len(geosite.rendered) >= geositeRenderedLimit {
+		geosite.rendered = make(map[string]string)
+	}
+	geosite.rendered[key] = result
+	return result, nil
+}
+
+// decodeGeositeDomains decodes a GeoSite message; a non-empty attr keeps only domains carrying that attribute.
+func decodeGeositeDomains(entry []byte, attr string) ([]geositeDomain, error) {
+	var domains []geositeDomain
+	err := readProtoFields(entry, func(num int, _ uint64, b []byte) error {
+		if num != 2 {
+			return nil
+		}
+		var d geositeDomain
+		err := readProtoFields(b, func(num int, v uint64, b []byte) error {
+			switch num {
+			case 1:
+				d.kind = v
+			case 2:
+				d.value = string(b)
+			case 3:
+				return readProtoFields(b, func(num int, _ uint64, b []byte) error {
+					if num == 1 {
+						d.attrs = append(d.attrs, strings.ToLower(string(b)))
+					}
+					return nil
+				})
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		if attr != "" && !containsString(d.attrs, attr) {
+			return nil
+		}
+		domains = append(domains, d)
+		return nil
+	})
+	return domains, err
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func renderGeositeClash(domains []geositeDomain) string {
+	var sb strings.Builder
+	sb.WriteString("payload:\n")
+	for _, d := range domains {
+		rule := ""
+		switch d.kind {
+		case geositeTypeFull:
+			rule = "DOMAIN,"
+		case geositeTypeDomain:
+			rule = "DOMAIN-SUFFIX,"
+		case geositeTypePlain:
+			rule = "DOMAIN-KEYWORD,"
+		case geositeTypeRegex:
+			rule = "DOMAIN-REGEX,"
+		default:
+			continue
+		}
+		sb.WriteString("  - " + escapeYAML(rule+d.value) + "\n")
+	}
+	return sb.String()
+}
+
+func renderGeositeSingBox(domains []geositeDomain) (string, error) {
+	rule := make(map[string][]string)
+	keys := map[uint64]string{
+		geositeTypeFull: "domain", geositeTypeDomain: "domain_suffix", geositeTypePlain: "domain_keyword", geositeTypeRegex: "domain_regex",
+	}
+	for _, d := range domains {
+		if key, ok := keys[d.kind]; ok {
+			rule[key] = append(rule[key], d.value)
+		}
+	}
+	b, err := json.MarshalIndent(map[string]interface{}{"version": 2, "rules": []interface{}{rule}}, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// renderGeositeList renders Surge / Loon / QuanX style "TYPE,value[,policy]" lines; regex entries are skipped.
+func renderGeositeList(domains []geositeDomain, full, suffix, keyword, policy string) string {
+	var sb strings.Builder
+	for _, d := range domains {
+		switch d.kind {
+		case geositeTypeFull:
+			sb.WriteString(full)
+		case geositeTypeDomain:
+			sb.WriteString(suffix)
+		case geositeTypePlain:
+			sb.WriteString(keyword)
+		default:
+			continue
+		}
+		sb.WriteString("," + d.value + policy + "\n")
+	}
+	return sb.String()
+}
+
+// readProtoFields walks the fields of a protobuf message, passing varint values as v and
+// length-delimited payloads as b; fixed32/fixed64 fields are skipped.
+func readProtoFields(data []byte, fn func(num int, v uint64, b []byte) error) error {
+	for len(data) > 0 {
+		tag, n := binary.Uvarint(data)
+		if n <= 0 {
+			return errors.New("protobuf: 无效的字段标签")
+		}
+		data = data[n:]
+		num := int(tag >> 3)
+		switch tag & 7 {
+		case 0:
+			v, n := binary.Uvarint(data)
+			if n <= 0 {
+				return errors.New("protobuf: 无效的 varint")
+			}
+			data = data[n:]
+			if err := fn(num, v, nil); err != nil {
+				return err
+			}
+		case 1:
+			if len(data) < 8 {
+				return errors.New("protobuf: 数据截断")
+			}
+			data = data[8:]
+		case 2:
+			l, n := binary.Uvarint(data)
+			if n <= 0 || uint64(len(data)-n) < l {
+				return errors.New("protobuf: 数据截断")
+			}
+			if err := fn(num, 0, data[n:n+int(l)]); err != nil {
+				return err
+			}
+			data = data[n+int(l):]
+		case 5:
+			if len(data) < 4 {
+				return errors.New("protobuf: 数据截断")
+			}
+			data = data[4:]
+		default:
+			return fmt.Errorf("protobuf: 不支持的类型 %d", tag&7)
+		}
+	}
+	return nil
+}
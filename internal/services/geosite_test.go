@@ -0,0 +1,69 @@
+package services
+
+import (
+	"encoding/binary"
+	"strings"
+	"testing"
+)
+
+func protoBytes(num int, b []byte) []byte {
+	out := binary.AppendUvarint(nil, uint64(num<<3|2))
+	out = binary.AppendUvarint(out, uint64(len(b)))
+	return append(out, b...)
+}
+
+func protoVarint(num int, v uint64) []byte {
+	return binary.AppendUvarint(binary.AppendUvarint(nil, uint64(num<<3)), v)
+}
+
+func geositeTestDomain(kind uint64, value string, attrs ...string) []byte {
+	b := append(protoVarint(1, kind), protoBytes(2, []byte(value))...)
+	for _, a := range attrs {
+		b = append(b, protoBytes(3, append(protoBytes(1, []byte(a)), protoVarint(2, 1)...))...)
+	}
+	return protoBytes(2, b)
+}
+
+func TestDecodeGeositeDomains(t *testing.T) {
+	entry := protoBytes(1, []byte("TEST"))
+	entry = append(entry, geositeTestDomain(geositeTypeDomain, "example.com")...)
+	entry = append(entry, geositeTestDomain(geositeTypeFull, "www.example.cn", "cn")...)
+	entry = append(entry, geositeTestDomain(geositeTypePlain, "example")...)
+	entry = append(entry, geositeTestDomain(geositeTypeRegex, `^ex\d+\.com$`)...)
+
+	domains, err := decodeGeositeDomains(entry, "")
+	if err != nil || len(domains) != 4 {
+		t.Fatalf("unexpected domains: %+v, err %v", domains, err)
+	}
+	clash := renderGeositeClash(domains)
+	for _, want := range []string{"DOMAIN-SUFFIX,example.com", "DOMAIN,www.example.cn", "DOMAIN-KEYWORD,example", "DOMAIN-REGEX"} {
+		if !strings.Contains(clash, want) {
+			t.Fatalf("clash payload missing %q:\n%s", want, clash)
+		}
+	}
+	quanx := renderGeositeList(domains, "HOST", "HOST-SUFFIX", "HOST-KEYWORD", ",proxy")
+	if quanx != "HOST-SUFFIX,example.com,proxy\nHOST,www.example.cn,proxy\nHOST-KEYWORD,example,proxy\n" {
+		t.Fatalf("unexpected quanx list:\n%s", quanx)
+	}
+	singbox, err := renderGeositeSingBox(domains)
+	if err != nil || !strings.Contains(singbox, `"domain_suffix": [`) || !strings.Contains(singbox, `"version": 2`) {
+		t.Fatalf("unexpected sing-box rule-set: %s %v", singbox, err)
+	}
+
+	cn, _ := decodeGeositeDomains(entry, "cn")
+	if len(cn) != 1 || cn[0].value != "www.example.cn" {
+		t.Fatalf("attribute filter failed: %+v", cn)
+	}
+	if _, err := decodeGeositeDomains(entry[:len(entry)-3], ""); err == nil {
+		t.Fatalf("expected error for truncated message")
+	}
+}
+
+func TestNormalizeGeositeFormat(t *testing.T) {
+	if f, ok := NormalizeGeositeFormat("SingBox"); !ok || f != "singbox-source" {
+		t.Fatalf("legacy singbox format should map to singbox-source, got %q", f)
+	}
+	if _, ok := NormalizeGeositeFormat("srs"); ok {
+		t.Fatalf("binary srs is not served")
+	}
+}
@@ -0,0 +1,84 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+
+	"cboard/v2/internal/models"
+)
+
+// ── 跨来源节点去重 ──
+// 多个上游转售同一后端时，合并后的节点列表会出现重复服务器。去重方式：
+//   - identity：协议 + 服务器 + 端口 + 凭据均相同（同 NodeIdentity）
+//   - endpoint：仅服务器 + 端口相同
+// 重复的节点指向同一服务器，测速结果无从区分，因此总是保留排在前面（来源顺序靠前）的一份。
+
+const (
+	NodeDedupOff      = "off"
+	NodeDedupIdentity = "identity"
+	NodeDedupEndpoint = "endpoint"
+)
+
+// NodeDuplicate records a node dropped by de-duplication and the copy that was kept.
+type NodeDuplicate struct {
+	Dropped models.Node
+	Kept    models.Node
+}
+
+// NodeEndpoint returns "server:port" of a node link, or "" when the link cannot be parsed.
+func NodeEndpoint(node models.Node) string {
+	if node.Config == nil || *node.Config == "" {
+		return ""
+	}
+	m, err := NodeConfigToClashMap(node.Type, *node.Config, node.Name)
+	if err != nil {
+		return ""
+	}
+	server := strings.ToLower(strings.TrimSpace(fmt.Sprint(m["server"])))
+	if server == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s:%v", server, m["port"])
+}
+
+func nodeDedupKey(node models.Node, mode string) string {
+	switch mode {
+	case NodeDedupIdentity:
+		return NodeIdentity(node)
+	case NodeDedupEndpoint:
+		return NodeEndpoint(node)
+	}
+	return ""
+}
+
+// DedupNodes drops every node whose dedup key matches an earlier node. Kept nodes stay at their original positions.
+func DedupNodes(nodes []models.Node, mode string) ([]models.Node, []NodeDuplicate) {
+	if mode != NodeDedupIdentity && mode != NodeDedupEndpoint {
+		return nodes, nil
+	}
+
+	// 先为每组记下第一份节点，再按原顺序输出
+	keys := make([]string, len(nodes))
+	winner := make(map[string]int)
+	for i, node := range nodes {
+		key := nodeDedupKey(node, mode)
+		keys[i] = key
+		if key == "" {
+			continue
+		}
+		if _, ok := winner[key]; !ok {
+			winner[key] = i
+		}
+	}
+
+	kept := make([]models.Node, 0, len(nodes))
+	var dropped []NodeDuplicate
+	for i, node := range nodes {
+		if w, ok := winner[keys[i]]; keys[i] != "" && ok && w != i {
+			dropped = append(dropped, NodeDuplicate{Dropped: node, Kept: nodes[w]})
+			continue
+		}
+		kept = append(kept, node)
+	}
+	return kept, dropped
+}
@@ -0,0 +1,34 @@
+package services
+
+import (
+	"testing"
+
+	"cboard/v2/internal/models"
+)
+
+func TestDedupNodes(t *testing.T) {
+	nodes := []models.Node{
+		syncTestNode(0, "A 香港", "trojan://pw@1.1.1.1:443#a", 0),
+		syncTestNode(0, "A 日本", "trojan://pw@2.2.2.2:443#b", 1),
+		syncTestNode(0, "B 香港", "trojan://pw@1.1.1.1:443#c", 2),
+		syncTestNode(0, "B 日本", "trojan://other@2.2.2.2:443#d", 3),
+	}
+
+	kept, dropped := DedupNodes(nodes, NodeDedupIdentity)
+	if len(kept) != 3 || len(dropped) != 1 || dropped[0].Dropped.Name != "B 香港" || dropped[0].Kept.Name != "A 香港" {
+		t.Fatalf("identity: kept = %d, dropped = %+v", len(kept), dropped)
+	}
+
+	// 仅按服务器 + 端口时凭据不同也视为重复
+	kept, dropped = DedupNodes(nodes, NodeDedupEndpoint)
+	if len(kept) != 2 || kept[0].Name != "A 香港" || kept[1].Name != "A 日本" {
+		t.Fatalf("endpoint: kept = %+v", kept)
+	}
+	if len(dropped) != 2 || dropped[1].Dropped.Name != "B 日本" || dropped[1].Kept.Name != "A 日本" {
+		t.Fatalf("endpoint: dropped = %+v", dropped)
+	}
+
+	if kept, _ = DedupNodes(nodes, NodeDedupOff); len(kept) != 4 {
+		t.Fatalf("off: kept %d nodes", len(kept))
+	}
+}
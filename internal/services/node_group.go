@@ -0,0 +1,35 @@
+package services
+
+import (
+	"cboard/v2/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// ── 节点分组授权 ──
+// 未分组的节点对所有订阅可见；分组节点只下发给套餐授权了该分组的订阅。
+// 自定义套餐（无 package_id）只能使用未分组节点。
+
+// PackageNodeGroupIDs returns the node groups granted by a package.
+func PackageNodeGroupIDs(db *gorm.DB, packageID uint) []uint {
+	var ids []uint
+	if packageID == 0 {
+		return ids
+	}
+	db.Model(&models.PackageNodeGroup{}).Where("package_id = ?", packageID).Pluck("node_group_id", &ids)
+	return ids
+}
+
+// SubscriptionNodeScope restricts a node query to the groups the subscription's package grants.
+func SubscriptionNodeScope(db *gorm.DB, sub *models.Subscription) func(*gorm.DB) *gorm.DB {
+	var groupIDs []uint
+	if sub != nil && sub.PackageID != nil {
+		groupIDs = PackageNodeGroupIDs(db, uint(*sub.PackageID))
+	}
+	return func(q *gorm.DB) *gorm.DB {
+		if len(groupIDs) == 0 {
+			return q.Where("group_id IS NULL")
+		}
+		return q.Where("group_id IS NULL OR group_id IN ?", groupIDs)
+	}
+}
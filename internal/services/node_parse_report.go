@@ -0,0 +1,103 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ── 节点解析诊断 ──
+// 解析订阅时记录每个未能生成节点的条目：行号（Clash / JSON 为条目序号）、识别到的协议、原因及脱敏片段，
+// 便于排查“导入 55 条只成功 40 条”之类的问题。片段去掉凭据与查询参数，只保留协议、地址和名称。
+
+const (
+	NodeParseFormatLinks = "links"
+	NodeParseFormatClash = "clash"
+	NodeParseFormatJSON  = "json"
+
+	nodeParseSnippetMax = 120
+	// 无法识别的文本可能本身就是凭据或编码后的链接，只保留开头一小段
+	nodeParseRawSnippetMax = 24
+)
+
+// NodeParseIssue describes one entry that did not produce a node.
+type NodeParseIssue struct {
+	Line    int    `json:"line"`
+	Scheme  string `json:"scheme"`
+	Reason  string `json:"reason"`
+	Snippet string `json:"snippet"`
+}
+
+// NodeParseReport summarizes one ParseSubscriptionContentWithReport call.
+type NodeParseReport struct {
+	Format   string           `json:"format"`
+	Total    int              `json:"total"`
+	Parsed   int              `json:"parsed"`
+	Rejected []NodeParseIssue `json:"rejected"`
+}
+
+func newNodeParseReport(format string) *NodeParseReport {
+	return &NodeParseReport{Format: format, Rejected: []NodeParseIssue{}}
+}
+
+func (r *NodeParseReport) reject(line int, scheme, reason, snippet string) {
+	r.Rejected = append(r.Rejected, NodeParseIssue{Line: line, Scheme: scheme, Reason: reason, Snippet: snippet})
+}
+
+// Summary is a one-line description of the rejected entries for logs and error messages.
+func (r *NodeParseReport) Summary() string {
+	if r == nil || len(r.Rejected) == 0 {
+		return ""
+	}
+	first := r.Rejected[0]
+	return fmt.Sprintf("%d 条未能解析，首条（第 %d 条）: %s", len(r.Rejected), first.Line, first.Reason)
+}
+
+// linkScheme returns the lower-cased scheme of a link, or "" when the text does not look like one.
+func linkScheme(link string) string {
+	scheme, _, ok := strings.Cut(link, "://")
+	if !ok || scheme == "" || len(scheme) > 16 || strings.ContainsAny(scheme, " \t/") {
+		return ""
+	}
+	return strings.ToLower(scheme)
+}
+
+// redactNodeLink keeps the scheme, host:port and #name of a link and hides credentials, base64 bodies
+// and query parameters.
+func redactNodeLink(link string) string {
+	scheme := linkScheme(link)
+	if scheme == "" {
+		if r := []rune(link); len(r) > nodeParseRawSnippetMax {
+			return string(r[:nodeParseRawSnippetMax]) + "…"
+		}
+		return link
+	}
+	rest := link[len(scheme)+3:]
+	rest, fragment, hasFragment := strings.Cut(rest, "#")
+	if i := strings.IndexAny(rest, "?/"); i >= 0 {
+		rest = rest[:i]
+	}
+	host := "***"
+	if at := strings.LastIndex(rest, "@"); at >= 0 {
+		host = "***@" + rest[at+1:]
+	} else if scheme == "http" || scheme == "https" || scheme == "socks" || scheme == "socks5" {
+		host = rest
+	}
+	snippet := scheme + "://" + host
+	if hasFragment {
+		snippet += "#" + decodeFragment(fragment)
+	}
+	return truncateSnippet(snippet)
+}
+
+// redactClashProxy describes a Clash proxy entry without its credentials.
+func redactClashProxy(proxy map[string]interface{}) string {
+	return truncateSnippet(fmt.Sprintf("name=%s type=%s server=%s port=%s",
+		stringFromMap(proxy, "name"), stringFromMap(proxy, "type"), stringFromMap(proxy, "server"), stringFromMap(proxy, "port")))
+}
+
+func truncateSnippet(s string) string {
+	if r := []rune(s); len(r) > nodeParseSnippetMax {
+		return string(r[:nodeParseSnippetMax]) + "…"
+	}
+	return s
+}
@@ -25,17 +25,32 @@ const (
 	maxResponseSize = 10 * 1024 * 1024 // 10MB limit for subscription content
 )
 
+const defaultFetchUserAgent = "ClashForAndroid/2.5.12"
+
+// FetchOptions customizes the upstream request made by FetchSubscriptionContentWithOptions.
+type FetchOptions struct {
+	UserAgent string            // 为空时使用 ClashForAndroid 的 UA
+	Headers   map[string]string // 额外请求头
+}
+
 // FetchSubscriptionContent fetches and base64-decodes subscription content from a URL.
 func FetchSubscriptionContent(urlStr string) (string, error) {
+	content, _, err := FetchSubscriptionContentWithOptions(urlStr, FetchOptions{})
+	return content, err
+}
+
+// FetchSubscriptionContentWithOptions is FetchSubscriptionContent with a custom User-Agent and headers;
+// it also returns the HTTP status code (0 when no response was received).
+func FetchSubscriptionContentWithOptions(urlStr string, opts FetchOptions) (string, int, error) {
 	// Validate URL
 	parsedURL, err := url.Parse(urlStr)
 	if err != nil {
-		return "", fmt.Errorf("invalid URL: %w", err)
+		return "", 0, fmt.Errorf("invalid URL: %w", err)
 	}
 
 	// Only allow http and https schemes to prevent SSRF
 	if parsedURL.Scheme != "http" && parsedURL.Scheme != "https" {
-		return "", fmt.Errorf("unsupported URL scheme: %s", parsedURL.Scheme)
+		return "", 0, fmt.Errorf("unsupported URL scheme: %s", parsedURL.Scheme)
 	}
 
 	// Prevent access to private IP ranges
@@ -43,11 +58,11 @@ func FetchSubscriptionContent(urlStr string) (string, error) {
 		if ips, err := net.LookupIP(parsedURL.Hostname()); err == nil {
 			for _, ip := range ips {
 				if isPrivateIP(ip) {
-					return "", fmt.Errorf("access to private IP addresses is not allowed")
+					return "", 0, fmt.Errorf("access to private IP addresses is not allowed")
 				}
 			}
 		} else {
-			return "", fmt.Errorf("DNS lookup failed for %s: %w", parsedURL.Hostname(), err)
+			return "", 0, fmt.Errorf("DNS lookup failed for %s: %w", parsedURL.Hostname(), err)
 		}
 	}
 
@@ -78,24 +93,31 @@ func FetchSubscriptionContent(urlStr string) (string, error) {
 	}
 	req, err := http.NewRequest("GET", urlStr, nil)
 	if err != nil {
-		return "", err
+		return "", 0, err
 	}
-	req.Header.Set("User-Agent", "ClashForAndroid/2.5.12")
+	for k, v := range opts.Headers {
+		req.Header.Set(k, v)
+	}
+	userAgent := opts.UserAgent
+	if userAgent == "" {
+		userAgent = defaultFetchUserAgent
+	}
+	req.Header.Set("User-Agent", userAgent)
 	resp, err := client.Do(req)
 	if err != nil {
-		return "", err
+		return "", 0, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
-		return "", fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+		return "", resp.StatusCode, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
 	}
 
 	// Limit response size to prevent memory exhaustion
 	limitedReader := io.LimitReader(resp.Body, maxResponseSize)
 	body, err := io.ReadAll(limitedReader)
 	if err != nil {
-		return "", err
+		return "", resp.StatusCode, err
 	}
 
 	content := normalizeSubscriptionContent(string(body))
@@ -108,7 +130,7 @@ func FetchSubscriptionContent(urlStr string) (string, error) {
 			}
 		}
 	}
-	return content, nil
+	return content, resp.StatusCode, nil
 }
 
 func normalizeSubscriptionContent(content string) string {
@@ -156,22 +178,40 @@ func isPrivateIP(ip net.IP) bool {
 
 // ParseNodeLinks parses multi-line node links into Node models.
 func ParseNodeLinks(content string) ([]models.Node, error) {
+	nodes, _ := parseNodeLinksWithReport(content)
+	return nodes, nil
+}
+
+func parseNodeLinksWithReport(content string) ([]models.Node, *NodeParseReport) {
 	lines := strings.Split(content, "\n")
 	var nodes []models.Node
+	report := newNodeParseReport(NodeParseFormatLinks)
 
-	for _, line := range lines {
+	for i, line := range lines {
 		line = strings.TrimSpace(line)
 		if line == "" {
 			continue
 		}
 
+		report.Total++
 		node, err := parseNodeFromLine(line)
 		if err == nil && node != nil {
 			nodes = append(nodes, *node)
+			continue
 		}
+		report.reject(i+1, linkScheme(line), nodeLineRejectReason(err), redactNodeLink(line))
 	}
 
-	return nodes, nil
+	report.Parsed = len(nodes)
+	return nodes, report
+}
+
+// nodeLineRejectReason explains why parseNodeFromLine produced no node.
+func nodeLineRejectReason(err error) string {
+	if err != nil {
+		return "解析失败: " + err.Error()
+	}
+	return "不支持的协议或格式"
 }
 
 type clashSubscription struct {
@@ -180,48 +220,62 @@ type clashSubscription struct {
 
 // ParseSubscriptionContent parses either Clash YAML subscriptions, JSON node lists, or traditional node links.
 func ParseSubscriptionContent(content string) ([]models.Node, error) {
+	nodes, _, err := ParseSubscriptionContentWithReport(content)
+	return nodes, err
+}
+
+// ParseSubscriptionContentWithReport is ParseSubscriptionContent that also reports the entries it had to drop.
+func ParseSubscriptionContentWithReport(content string) ([]models.Node, *NodeParseReport, error) {
 	content = normalizeSubscriptionContent(content)
 	if content == "" {
-		return nil, nil
+		return nil, newNodeParseReport(NodeParseFormatLinks), nil
 	}
 
 	// Try JSON extraction (e.g. {"data":[{"vmessLink":"..."}]})
 	if len(content) > 0 && (content[0] == '{' || content[0] == '[') {
-		if nodes, ok := parseJSONNodeList(content); ok {
-			return nodes, nil
+		if nodes, report, ok := parseJSONNodeList(content); ok {
+			return nodes, report, nil
 		}
 	}
 
-	if nodes, ok, err := parseClashSubscription(content); ok {
-		return nodes, err
+	if nodes, report, ok := parseClashSubscription(content); ok {
+		return nodes, report, nil
 	}
 
-	return ParseNodeLinks(content)
+	nodes, report := parseNodeLinksWithReport(content)
+	return nodes, report, nil
 }
 
-// parseJSONNodeList recursively walks any JSON structure and collects proxy links.
-func parseJSONNodeList(content string) ([]models.Node, bool) {
+// parseJSONNodeList recursively walks any JSON structure and collects proxy links; the report numbers
+// entries in the order the links were found. ok is true whenever links were found, even if none of them parsed,
+// so the rejected entries are reported instead of falling through to the other formats.
+func parseJSONNodeList(content string) ([]models.Node, *NodeParseReport, bool) {
 	var raw interface{}
 	if err := json.Unmarshal([]byte(content), &raw); err != nil {
-		return nil, false
+		return nil, nil, false
 	}
 	links := extractProxyLinksFromJSON(raw)
 	if len(links) == 0 {
-		return nil, false
+		return nil, nil, false
 	}
 	var nodes []models.Node
+	report := newNodeParseReport(NodeParseFormatJSON)
 	seen := make(map[string]bool)
-	for _, link := range links {
+	for i, link := range links {
 		if seen[link] {
 			continue
 		}
 		seen[link] = true
+		report.Total++
 		node, err := parseNodeFromLine(link)
 		if err == nil && node != nil {
 			nodes = append(nodes, *node)
+			continue
 		}
+		report.reject(i+1, linkScheme(link), nodeLineRejectReason(err), redactNodeLink(link))
 	}
-	return nodes, len(nodes) > 0
+	report.Parsed = len(nodes)
+	return nodes, report, true
 }
 
 var proxyLinkPrefixes = []string{
@@ -385,24 +439,34 @@ func extractDomainPortFromVmessLink(link string) (string, int, error) {
 	return stringFromMap(proxy, "server"), intFromMap(proxy, "port", 0), nil
 }
 
-func parseClashSubscription(content string) ([]models.Node, bool, error) {
+// parseClashSubscription parses the proxies of a Clash YAML document; the report numbers entries by
+// their position in the proxies list.
+func parseClashSubscription(content string) ([]models.Node, *NodeParseReport, bool) {
 	var sub clashSubscription
 	if err := yaml.Unmarshal([]byte(content), &sub); err != nil {
-		return nil, false, nil
+		return nil, nil, false
 	}
 	if len(sub.Proxies) == 0 {
-		return nil, false, nil
+		return nil, nil, false
 	}
 
 	var nodes []models.Node
-	for _, proxy := range sub.Proxies {
+	report := newNodeParseReport(NodeParseFormatClash)
+	for i, proxy := range sub.Proxies {
+		report.Total++
 		node, err := clashProxyToNode(proxy)
 		if err != nil || node == nil {
+			reason := "不支持的代理类型"
+			if err != nil {
+				reason = "解析失败: " + err.Error()
+			}
+			report.reject(i+1, strings.ToLower(stringFromMap(proxy, "type")), reason, redactClashProxy(proxy))
 			continue
 		}
 		nodes = append(nodes, *node)
 	}
-	return nodes, true, nil
+	report.Parsed = len(nodes)
+	return nodes, report, true
 }
 
 func clashProxyToNode(proxy map[string]interface{}) (*models.Node, error) {
@@ -1752,72 +1816,72 @@ func portToInt(port string) int {
 	return p
 }
 
+// regionKeywords maps region names to the keywords DetectRegion looks for; keywords padded with spaces
+// or dashes only match on word boundaries, and the flag emoji (last keyword) is also used by RegionFlag.
+var regionKeywords = map[string][]string{
+	"香港":    {"香港", " hk ", " hk-", "-hk-", "-hk ", "hong kong", "hongkong", "🇭🇰"},
+	"美国":    {"美国", " us ", " us-", "-us-", "-us ", "usa", "united states", "america", "🇺🇸"},
+	"日本":    {"日本", " jp ", " jp-", "-jp-", "-jp ", "japan", "tokyo", "🇯🇵"},
+	"新加坡":   {"新加坡", " sg ", " sg-", "-sg-", "-sg ", "singapore", "🇸🇬"},
+	"台湾":    {"台湾", " tw ", " tw-", "-tw-", "-tw ", "taiwan", "🇹🇼"},
+	"韩国":    {"韩国", " kr ", " kr-", "-kr-", "-kr ", "korea", "seoul", "🇰🇷"},
+	"英国":    {"英国", " uk ", " uk-", "-uk-", "-uk ", "united kingdom", "london", "🇬🇧"},
+	"德国":    {"德国", " de ", " de-", "-de-", "-de ", "germany", "🇩🇪"},
+	"法国":    {"法国", " fr ", " fr-", "-fr-", "-fr ", "france", "🇫🇷"},
+	"加拿大":   {"加拿大", " ca ", " ca-", "-ca-", "-ca ", "canada", "🇨🇦"},
+	"澳大利亚":  {"澳大利亚", "澳", " au ", " au-", "-au-", "-au ", "australia", "🇦🇺"},
+	"俄罗斯":   {"俄罗斯", " ru ", " ru-", "-ru-", "-ru ", "russia", "🇷🇺"},
+	"印度":    {"印度", " in ", " in-", "-in-", "-in ", "india", "🇮🇳"},
+	"马来西亚":  {"马来西亚", "大马", " my ", " my-", "-my-", "-my ", "malaysia", "🇲🇾"},
+	"菲律宾":   {"菲律宾", " ph ", " ph-", "-ph-", "-ph ", "philippines", "🇵🇭"},
+	"柬埔寨":   {"柬埔寨", " kh ", " kh-", "-kh-", "-kh ", "cambodia", "🇰🇭"},
+	"越南":    {"越南", " vn ", " vn-", "-vn-", "-vn ", "vietnam", "🇻🇳"},
+	"泰国":    {"泰国", " th ", " th-", "-th-", "-th ", "thailand", "🇹🇭"},
+	"印度尼西亚": {"印度尼西亚", "印尼", " id ", " id-", "-id-", "-id ", "indonesia", "🇮🇩"},
+	"土耳其":   {"土耳其", " tr ", " tr-", "-tr-", "-tr ", "turkey", "🇹🇷"},
+	"巴西":    {"巴西", " br ", " br-", "-br-", "-br ", "brazil", "🇧🇷"},
+	"荷兰":    {"荷兰", " nl ", " nl-", "-nl-", "-nl ", "netherlands", "🇳🇱"},
+	"意大利":   {"意大利", " it ", " it-", "-it-", "-it ", "italy", "🇮🇹"},
+	"西班牙":   {"西班牙", " es ", " es-", "-es-", "-es ", "spain", "🇪🇸"},
+	"瑞士":    {"瑞士", " ch ", " ch-", "-ch-", "-ch ", "switzerland", "🇨🇭"},
+	"瑞典":    {"瑞典", " se ", " se-", "-se-", "-se ", "sweden", "🇸🇪"},
+	"波兰":    {"波兰", " pl ", " pl-", "-pl-", "-pl ", "poland", "🇵🇱"},
+	"阿联酋":   {"阿联酋", " ae ", " ae-", "-ae-", "-ae ", "uae", "🇦🇪"},
+	"新西兰":   {"新西兰", " nz ", " nz-", "-nz-", "-nz ", "new zealand", "🇳🇿"},
+	"南非":    {"南非", " za ", " za-", "-za-", "-za ", "south africa", "🇿🇦"},
+	"爱尔兰":   {"爱尔兰", " ie ", " ie-", "-ie-", "-ie ", "ireland", "🇮🇪"},
+	"墨西哥":   {"墨西哥", " mx ", " mx-", "-mx-", "-mx ", "mexico", "🇲🇽"},
+	"阿根廷":   {"阿根廷", " ar ", " ar-", "-ar-", "-ar ", "argentina", "🇦🇷"},
+	"哥伦比亚":  {"哥伦比亚", " co ", " co-", "-co-", "-co ", "colombia", "🇨🇴"},
+	"智利":    {"智利", " cl ", " cl-", "-cl-", "-cl ", "chile", "🇨🇱"},
+	"埃及":    {"埃及", " eg ", " eg-", "-eg-", "-eg ", "egypt", "🇪🇬"},
+	"以色列":   {"以色列", " il ", " il-", "-il-", "-il ", "israel", "🇮🇱"},
+	"乌克兰":   {"乌克兰", " ua ", " ua-", "-ua-", "-ua ", "ukraine", "🇺🇦"},
+	"罗马尼亚":  {"罗马尼亚", " ro ", " ro-", "-ro-", "-ro ", "romania", "🇷🇴"},
+	"匈牙利":   {"匈牙利", " hu ", " hu-", "-hu-", "-hu ", "hungary", "🇭🇺"},
+	"捷克":    {"捷克", " cz ", " cz-", "-cz-", "-cz ", "czech", "🇨🇿"},
+	"希腊":    {"希腊", " gr ", " gr-", "-gr-", "-gr ", "greece", "🇬🇷"},
+	"葡萄牙":   {"葡萄牙", " pt ", " pt-", "-pt-", "-pt ", "portugal", "🇵🇹"},
+	"芬兰":    {"芬兰", " fi ", " fi-", "-fi-", "-fi ", "finland", "🇫🇮"},
+	"挪威":    {"挪威", " no ", " no-", "-no-", "-no ", "norway", "🇳🇴"},
+	"丹麦":    {"丹麦", " dk ", " dk-", "-dk-", "-dk ", "denmark", "🇩🇰"},
+	"奥地利":   {"奥地利", " at ", " at-", "-at-", "-at ", "austria", "🇦🇹"},
+	"比利时":   {"比利时", " be ", " be-", "-be-", "-be ", "belgium", "🇧🇪"},
+	"缅甸":    {"缅甸", " mm ", " mm-", "-mm-", "-mm ", "myanmar", "🇲🇲"},
+	"老挝":    {"老挝", " la ", " la-", "-la-", "-la ", "laos", "🇱🇦"},
+	"巴基斯坦":  {"巴基斯坦", " pk ", " pk-", "-pk-", "-pk ", "pakistan", "🇵🇰"},
+	"孟加拉":   {"孟加拉", " bd ", " bd-", "-bd-", "-bd ", "bangladesh", "🇧🇩"},
+	"蒙古":    {"蒙古", " mn ", " mn-", "-mn-", "-mn ", "mongolia", "🇲🇳"},
+	"哈萨克斯坦": {"哈萨克斯坦", " kz ", " kz-", "-kz-", "-kz ", "kazakhstan", "🇰🇿"},
+}
+
 func DetectRegion(name string) string {
 	lower := strings.ToLower(name)
 
-	// Use word boundary matching to avoid false positives
-	// Check for exact matches or matches with word boundaries
-	regionMap := map[string][]string{
-		"香港":    {"香港", " hk ", " hk-", "-hk-", "-hk ", "hong kong", "hongkong", "🇭🇰"},
-		"美国":    {"美国", " us ", " us-", "-us-", "-us ", "usa", "united states", "america", "🇺🇸"},
-		"日本":    {"日本", " jp ", " jp-", "-jp-", "-jp ", "japan", "tokyo", "🇯🇵"},
-		"新加坡":   {"新加坡", " sg ", " sg-", "-sg-", "-sg ", "singapore", "🇸🇬"},
-		"台湾":    {"台湾", " tw ", " tw-", "-tw-", "-tw ", "taiwan", "🇹🇼"},
-		"韩国":    {"韩国", " kr ", " kr-", "-kr-", "-kr ", "korea", "seoul", "🇰🇷"},
-		"英国":    {"英国", " uk ", " uk-", "-uk-", "-uk ", "united kingdom", "london", "🇬🇧"},
-		"德国":    {"德国", " de ", " de-", "-de-", "-de ", "germany", "🇩🇪"},
-		"法国":    {"法国", " fr ", " fr-", "-fr-", "-fr ", "france", "🇫🇷"},
-		"加拿大":   {"加拿大", " ca ", " ca-", "-ca-", "-ca ", "canada", "🇨🇦"},
-		"澳大利亚":  {"澳大利亚", "澳", " au ", " au-", "-au-", "-au ", "australia", "🇦🇺"},
-		"俄罗斯":   {"俄罗斯", " ru ", " ru-", "-ru-", "-ru ", "russia", "🇷🇺"},
-		"印度":    {"印度", " in ", " in-", "-in-", "-in ", "india", "🇮🇳"},
-		"马来西亚":  {"马来西亚", "大马", " my ", " my-", "-my-", "-my ", "malaysia", "🇲🇾"},
-		"菲律宾":   {"菲律宾", " ph ", " ph-", "-ph-", "-ph ", "philippines", "🇵🇭"},
-		"柬埔寨":   {"柬埔寨", " kh ", " kh-", "-kh-", "-kh ", "cambodia", "🇰🇭"},
-		"越南":    {"越南", " vn ", " vn-", "-vn-", "-vn ", "vietnam", "🇻🇳"},
-		"泰国":    {"泰国", " th ", " th-", "-th-", "-th ", "thailand", "🇹🇭"},
-		"印度尼西亚": {"印度尼西亚", "印尼", " id ", " id-", "-id-", "-id ", "indonesia", "🇮🇩"},
-		"土耳其":   {"土耳其", " tr ", " tr-", "-tr-", "-tr ", "turkey", "🇹🇷"},
-		"巴西":    {"巴西", " br ", " br-", "-br-", "-br ", "brazil", "🇧🇷"},
-		"荷兰":    {"荷兰", " nl ", " nl-", "-nl-", "-nl ", "netherlands", "🇳🇱"},
-		"意大利":   {"意大利", " it ", " it-", "-it-", "-it ", "italy", "🇮🇹"},
-		"西班牙":   {"西班牙", " es ", " es-", "-es-", "-es ", "spain", "🇪🇸"},
-		"瑞士":    {"瑞士", " ch ", " ch-", "-ch-", "-ch ", "switzerland", "🇨🇭"},
-		"瑞典":    {"瑞典", " se ", " se-", "-se-", "-se ", "sweden", "🇸🇪"},
-		"波兰":    {"波兰", " pl ", " pl-", "-pl-", "-pl ", "poland", "🇵🇱"},
-		"阿联酋":   {"阿联酋", " ae ", " ae-", "-ae-", "-ae ", "uae", "🇦🇪"},
-		"新西兰":   {"新西兰", " nz ", " nz-", "-nz-", "-nz ", "new zealand", "🇳🇿"},
-		"南非":    {"南非", " za ", " za-", "-za-", "-za ", "south africa", "🇿🇦"},
-		"爱尔兰":   {"爱尔兰", " ie ", " ie-", "-ie-", "-ie ", "ireland", "🇮🇪"},
-		"墨西哥":   {"墨西哥", " mx ", " mx-", "-mx-", "-mx ", "mexico", "🇲🇽"},
-		"阿根廷":   {"阿根廷", " ar ", " ar-", "-ar-", "-ar ", "argentina", "🇦🇷"},
-		"哥伦比亚":  {"哥伦比亚", " co ", " co-", "-co-", "-co ", "colombia", "🇨🇴"},
-		"智利":    {"智利", " cl ", " cl-", "-cl-", "-cl ", "chile", "🇨🇱"},
-		"埃及":    {"埃及", " eg ", " eg-", "-eg-", "-eg ", "egypt", "🇪🇬"},
-		"以色列":   {"以色列", " il ", " il-", "-il-", "-il ", "israel", "🇮🇱"},
-		"乌克兰":   {"乌克兰", " ua ", " ua-", "-ua-", "-ua ", "ukraine", "🇺🇦"},
-		"罗马尼亚":  {"罗马尼亚", " ro ", " ro-", "-ro-", "-ro ", "romania", "🇷🇴"},
-		"匈牙利":   {"匈牙利", " hu ", " hu-", "-hu-", "-hu ", "hungary", "🇭🇺"},
-		"捷克":    {"捷克", " cz ", " cz-", "-cz-", "-cz ", "czech", "🇨🇿"},
-		"希腊":    {"希腊", " gr ", " gr-", "-gr-", "-gr ", "greece", "🇬🇷"},
-		"葡萄牙":   {"葡萄牙", " pt ", " pt-", "-pt-", "-pt ", "portugal", "🇵🇹"},
-		"芬兰":    {"芬兰", " fi ", " fi-", "-fi-", "-fi ", "finland", "🇫🇮"},
-		"挪威":    {"挪威", " no ", " no-", "-no-", "-no ", "norway", "🇳🇴"},
-		"丹麦":    {"丹麦", " dk ", " dk-", "-dk-", "-dk ", "denmark", "🇩🇰"},
-		"奥地利":   {"奥地利", " at ", " at-", "-at-", "-at ", "austria", "🇦🇹"},
-		"比利时":   {"比利时", " be ", " be-", "-be-", "-be ", "belgium", "🇧🇪"},
-		"缅甸":    {"缅甸", " mm ", " mm-", "-mm-", "-mm ", "myanmar", "🇲🇲"},
-		"老挝":    {"老挝", " la ", " la-", "-la-", "-la ", "laos", "🇱🇦"},
-		"巴基斯坦":  {"巴基斯坦", " pk ", " pk-", "-pk-", "-pk ", "pakistan", "🇵🇰"},
-		"孟加拉":   {"孟加拉", " bd ", " bd-", "-bd-", "-bd ", "bangladesh", "🇧🇩"},
-		"蒙古":    {"蒙古", " mn ", " mn-", "-mn-", "-mn ", "mongolia", "🇲🇳"},
-		"哈萨克斯坦": {"哈萨克斯坦", " kz ", " kz-", "-kz-", "-kz ", "kazakhstan", "🇰🇿"},
-	}
-
 	// Add spaces around the name for boundary matching
 	paddedLower := " " + lower + " "
 
-	for region, keywords := range regionMap {
+	for region, keywords := range regionKeywords {
 		for _, keyword := range keywords {
 			// For keywords with spaces (word boundaries), check in padded string
 			if strings.HasPrefix(keyword, " ") || strings.HasSuffix(keyword, " ") {
@@ -2009,6 +2073,20 @@ func VlessLinkToClashMap(link string, name string) (map[string]interface{}, erro
 			if len(huOpts) > 0 {
 				m["httpupgrade-opts"] = huOpts
 			}
+		} else if t == "xhttp" {
+			xhOpts := map[string]interface{}{}
+			if p := q.Get("path"); p != "" {
+				xhOpts["path"] = p
+			}
+			if h := q.Get("host"); h != "" {
+				xhOpts["host"] = h
+			}
+			if mode := q.Get("mode"); mode != "" {
+				xhOpts["mode"] = mode
+			}
+			if len(xhOpts) > 0 {
+				m["xhttp-opts"] = xhOpts
+			}
 		}
 	}
 	sec := q.Get("security")
@@ -2794,48 +2872,17 @@ func GenerateClashYAML(nodes []models.Node) string {
 // GenerateClashYAMLWithDomain generates Clash YAML using the template file (uploads/config/temp.yaml).
 // subscriptionName is used for the YAML `name` field (e.g. "到期: 2026-03-15").
 func GenerateClashYAMLWithDomain(nodes []models.Node, siteDomain string, subscriptionName string) string {
-	var proxies []map[string]interface{}
-	var proxyNames []string
-	var infoNames []string
-	usedNames := make(map[string]bool)
-
-	for _, n := range nodes {
-		if n.Config == nil || *n.Config == "" {
-			continue
-		}
-		name := sanitizeNodeName(n.Name)
-		if name == "" {
-			name = fmt.Sprintf("%s Node", strings.ToUpper(n.Type))
-		}
-		origName := name
-		counter := 1
-		for usedNames[name] {
-			name = fmt.Sprintf("%s_%d", origName, counter)
-			counter++
-		}
-		usedNames[name] = true
+	return GenerateClashYAMLWithTemplate(nodes, siteDomain, subscriptionName, "")
+}
 
-		m, err := NodeConfigToClashMap(n.Type, *n.Config, name)
-		if err != nil {
-			continue
-		}
-		proxies = append(proxies, m)
-		proxyNames = append(proxyNames, name)
+// GenerateClashYAMLWithTemplate generates Clash YAML from an admin-managed template;
+// an empty template falls back to the template file and then to the built-in default.
+func GenerateClashYAMLWithTemplate(nodes []models.Node, siteDomain, subscriptionName, template string) string {
+	proxies, proxyNames, realNames := buildClashProxies(nodes)
 
-		if server, ok := m["server"].(string); ok && server == "baidu.com" {
-			infoNames = append(infoNames, name)
-		}
-	}
-
-	// Real proxy names (exclude info nodes) for auto-select groups
-	infoSet := make(map[string]bool)
-	for _, n := range infoNames {
-		infoSet[n] = true
-	}
-	var realNames []string
-	for _, n := range proxyNames {
-		if !infoSet[n] {
-			realNames = append(realNames, n)
+	if template != "" {
+		if result := generateFromTemplateData([]byte(template), proxies, proxyNames, realNames, subscriptionName); result != "" {
+			return result
 		}
 	}
 
@@ -2851,8 +2898,29 @@ func GenerateClashYAMLWithDomain(nodes []models.Node, siteDomain string, subscri
 // GenerateStashYAMLWithDomain generates Stash YAML using stash_temp.yaml template.
 // Falls back to Clash YAML if the Stash template is not found.
 func GenerateStashYAMLWithDomain(nodes []models.Node, siteDomain string, subscriptionName string) string {
-	var proxies []map[string]interface{}
-	var proxyNames []string
+	return GenerateStashYAMLWithTemplate(nodes, siteDomain, subscriptionName, "", "")
+}
+
+// GenerateStashYAMLWithTemplate generates Stash YAML from an admin-managed template, falling back to
+// stash_temp.yaml and then to Clash YAML (rendered with clashTemplate).
+func GenerateStashYAMLWithTemplate(nodes []models.Node, siteDomain, subscriptionName, template, clashTemplate string) string {
+	proxies, proxyNames, realNames := buildClashProxies(nodes)
+
+	if template != "" {
+		if result := generateFromTemplateData([]byte(template), proxies, proxyNames, realNames, subscriptionName); result != "" {
+			return result
+		}
+	}
+	if result := generateFromTemplateFile("uploads/config/stash_temp.yaml", proxies, proxyNames, realNames, subscriptionName); result != "" {
+		return result
+	}
+	// Fall back to Clash YAML
+	return GenerateClashYAMLWithTemplate(nodes, siteDomain, subscriptionName, clashTemplate)
+}
+
+// buildClashProxies converts nodes to Clash proxy maps with unique names.
+// realNames excludes info nodes (server baidu.com) and is used for auto-select groups.
+func buildClashProxies(nodes []models.Node) (proxies []map[string]interface{}, proxyNames, realNames []string) {
 	var infoNames []string
 	usedNames := make(map[string]bool)
 
@@ -2871,12 +2939,14 @@ func GenerateStashYAMLWithDomain(nodes []models.Node, siteDomain string, subscri
 			counter++
 		}
 		usedNames[name] = true
+
 		m, err := NodeConfigToClashMap(n.Type, *n.Config, name)
 		if err != nil {
 			continue
 		}
 		proxies = append(proxies, m)
 		proxyNames = append(proxyNames, name)
+
 		if server, ok := m["server"].(string); ok && server == "baidu.com" {
 			infoNames = append(infoNames, name)
 		}
@@ -2886,18 +2956,12 @@ func GenerateStashYAMLWithDomain(nodes []models.Node, siteDomain string, subscri
 	for _, n := range infoNames {
 		infoSet[n] = true
 	}
-	var realNames []string
 	for _, n := range proxyNames {
 		if !infoSet[n] {
 			realNames = append(realNames, n)
 		}
 	}
-
-	if result := generateFromTemplateFile("uploads/config/stash_temp.yaml", proxies, proxyNames, realNames, subscriptionName); result != "" {
-		return result
-	}
-	// Fall back to Clash YAML
-	return GenerateClashYAMLWithDomain(nodes, siteDomain, subscriptionName)
+	return proxies, proxyNames, realNames
 }
 
 // generateFromTemplate loads uploads/config/temp.yaml and injects proxies + updates proxy-groups.
@@ -2910,7 +2974,18 @@ func generateFromTemplateFile(templatePath string, proxies []map[string]interfac
 	if err != nil {
 		return ""
 	}
+	return generateFromTemplateData(data, proxies, allNames, realNames, subscriptionName)
+}
+
+func generateFromTemplateData(data []byte, proxies []map[string]interface{}, allNames, realNames []string, subscriptionName string) string {
+	return renderClashTemplate(data, proxies, subscriptionName, nil, func(groupsNode *yaml.Node) {
+		updateProxyGroupsYAML(groupsNode, allNames, realNames)
+	})
+}
 
+// renderClashTemplate injects proxies, the profile name and (when non-nil) a proxy-providers mapping into a
+// Clash template and lets updateGroups rewrite the proxy-groups sequence; it returns "" when the template cannot be parsed.
+func renderClashTemplate(data []byte, proxies []map[string]interface{}, subscriptionName string, providers *yaml.Node, updateGroups func(groupsNode *yaml.Node)) string {
 	var templateConfig yaml.Node
 	if err := yaml.Unmarshal(data, &templateConfig); err != nil {
 		return ""
@@ -2970,7 +3045,7 @@ func generateFromTemplateFile(templatePath string, proxies []map[string]interfac
 		}
 
 		if keyNode.Value == "proxy-groups" && valNode.Kind == yaml.SequenceNode {
-			updateProxyGroupsYAML(valNode, allNames, realNames)
+			updateGroups(valNode)
 		}
 
 		// 为 Sparkle 等客户端：模板中的 profile 增加自动更新间隔（小时）
@@ -2979,6 +3054,10 @@ func generateFromTemplateFile(templatePath string, proxies []map[string]interfac
 		}
 	}
 
+	if providers != nil {
+		setClashProviders(root, providers)
+	}
+
 	output, err := yaml.Marshal(&templateConfig)
 	if err != nil {
 		return ""
@@ -64,6 +64,49 @@ func TestParseSubscriptionContentSupportsLinks(t *testing.T) {
 	}
 }
 
+func TestParseSubscriptionContentReportsRejectedLines(t *testing.T) {
+	content := "trojan://secret@1.2.3.4:443?sni=x.com#ok\n\nvmess://@@@\nfoo://user:pw@host:1#未知\n"
+
+	nodes, report, err := ParseSubscriptionContentWithReport(content)
+	if err != nil {
+		t.Fatalf("ParseSubscriptionContentWithReport returned error: %v", err)
+	}
+	if len(nodes) != 1 || report.Format != NodeParseFormatLinks || report.Total != 3 || report.Parsed != 1 {
+		t.Fatalf("unexpected result: nodes=%d report=%+v", len(nodes), report)
+	}
+	if len(report.Rejected) != 2 {
+		t.Fatalf("expected 2 rejected lines, got %+v", report.Rejected)
+	}
+	vmess, unknown := report.Rejected[0], report.Rejected[1]
+	if vmess.Line != 3 || vmess.Scheme != "vmess" || !strings.HasPrefix(vmess.Reason, "解析失败") {
+		t.Fatalf("unexpected vmess issue: %+v", vmess)
+	}
+	if unknown.Line != 4 || unknown.Scheme != "foo" || unknown.Snippet != "foo://***@host:1#未知" {
+		t.Fatalf("unexpected unknown issue: %+v", unknown)
+	}
+}
+
+func TestParseSubscriptionContentReportsAllInvalidJSONList(t *testing.T) {
+	nodes, report, err := ParseSubscriptionContentWithReport(`{"data":["vmess://@@@","trojan://@:bad#x"]}`)
+	if err != nil {
+		t.Fatalf("ParseSubscriptionContentWithReport returned error: %v", err)
+	}
+	if len(nodes) != 0 || report.Format != NodeParseFormatJSON || report.Total != 2 || report.Parsed != 0 {
+		t.Fatalf("unexpected result: nodes=%d report=%+v", len(nodes), report)
+	}
+	if len(report.Rejected) != 2 {
+		t.Fatalf("expected 2 rejected entries, got %+v", report.Rejected)
+	}
+	for i, scheme := range []string{"vmess", "trojan"} {
+		if issue := report.Rejected[i]; issue.Line != i+1 || issue.Scheme != scheme || issue.Reason == "" {
+			t.Fatalf("unexpected issue %d: %+v", i, issue)
+		}
+	}
+	if summary := report.Summary(); !strings.HasPrefix(summary, "2 条未能解析") {
+		t.Fatalf("unexpected summary %q", summary)
+	}
+}
+
 func TestExtractDomainPortFromNodeLink(t *testing.T) {
 	tests := []struct {
 		name    string
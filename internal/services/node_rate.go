@@ -0,0 +1,106 @@
+package services
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"cboard/v2/internal/models"
+)
+
+// ── 节点流量倍率 ──
+// 中转/专线等成本较高的节点按倍率计费：节点端上报的流量乘以倍率后计入订阅，
+// 同时在下发给客户端的节点名称后追加 "[x2.0]" 提示用户。
+
+// NodeRate returns the effective billing rate of a node; unset or invalid values count as 1.
+func NodeRate(node *models.Node) float64 {
+	if node.Rate <= 0 || math.IsNaN(node.Rate) || math.IsInf(node.Rate, 0) {
+		return 1
+	}
+	return node.Rate
+}
+
+// ScaleTrafficByRate applies a billing rate to a reported byte count.
+func ScaleTrafficByRate(bytes int64, rate float64) int64 {
+	if bytes <= 0 {
+		return 0
+	}
+	if rate == 1 {
+		return bytes
+	}
+	return int64(math.Round(float64(bytes) * rate))
+}
+
+// FormatNodeRate renders a rate for node name suffixes, always keeping one decimal (2 → "2.0", 1.25 → "1.25").
+func FormatNodeRate(rate float64) string {
+	s := strconv.FormatFloat(rate, 'f', -1, 64)
+	if !strings.Contains(s, ".") {
+		s += ".0"
+	}
+	return s
+}
+
+// ApplyNodeRateSuffix appends the rate to the node name (and the name embedded in its link) when it is not 1.
+func ApplyNodeRateSuffix(node models.Node) models.Node {
+	rate := NodeRate(&node)
+	if rate == 1 {
+		return node
+	}
+	name := node.Name + " [x" + FormatNodeRate(rate) + "]"
+	node.Name = name
+	if node.Config == nil || *node.Config == "" {
+		return node
+	}
+	// 通用 Base64 订阅直接输出原始链接，需要同步改写链接中的名称；只替换名称部分，其余参数原样保留
+	link := renameNodeLink(*node.Config, name)
+	node.Config = &link
+	return node
+}
+
+var ssrRemarksRe = regexp.MustCompile(`(^|&)remarks=[^&]*`)
+
+// renameNodeLink replaces only the display name of a node link: the ps field of base64 JSON vmess links,
+// the remarks parameter of ssr links and the #fragment of everything else. Links it cannot decode are
+// returned unchanged.
+func renameNodeLink(link, name string) string {
+	base, _, hasFragment := strings.Cut(link, "#")
+	switch {
+	case strings.HasPrefix(link, "vmess://") && !hasFragment:
+		body := strings.TrimPrefix(link, "vmess://")
+		decoded, err := decodeBase64Flexible(body)
+		if err != nil {
+			return link
+		}
+		dec := json.NewDecoder(strings.NewReader(decoded))
+		dec.UseNumber()
+		var m map[string]interface{}
+		if err := dec.Decode(&m); err != nil {
+			return link
+		}
+		m["ps"] = name
+		data, err := json.Marshal(m)
+		if err != nil {
+			return link
+		}
+		return "vmess://" + base64.StdEncoding.EncodeToString(data)
+	case strings.HasPrefix(link, "ssr://"):
+		decoded, err := decodeBase64Flexible(strings.TrimPrefix(link, "ssr://"))
+		if err != nil {
+			return link
+		}
+		main, params, _ := strings.Cut(decoded, "/?")
+		remarks := "remarks=" + base64.RawURLEncoding.EncodeToString([]byte(name))
+		if ssrRemarksRe.MatchString(params) {
+			params = ssrRemarksRe.ReplaceAllString(params, "${1}"+remarks)
+		} else if params != "" {
+			params += "&" + remarks
+		} else {
+			params = remarks
+		}
+		return "ssr://" + base64.RawURLEncoding.EncodeToString([]byte(main+"/?"+params))
+	}
+	return base + encodeNameFragment(name)
+}
@@ -0,0 +1,59 @@
+package services
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"cboard/v2/internal/models"
+)
+
+func TestScaleTrafficByRate(t *testing.T) {
+	if got := ScaleTrafficByRate(1000, 2); got != 2000 {
+		t.Fatalf("expected 2000, got %d", got)
+	}
+	if got := ScaleTrafficByRate(1000, 0.5); got != 500 {
+		t.Fatalf("expected 500, got %d", got)
+	}
+	if got := ScaleTrafficByRate(-10, 2); got != 0 {
+		t.Fatalf("negative traffic should be ignored, got %d", got)
+	}
+}
+
+func TestApplyNodeRateSuffix(t *testing.T) {
+	link := "trojan://secret@example.com:443?sni=example.com#HK-01"
+	node := models.Node{Name: "HK-01", Type: "trojan", Config: &link, Rate: 2}
+	got := ApplyNodeRateSuffix(node)
+	if got.Name != "HK-01 [x2.0]" {
+		t.Fatalf("unexpected name %q", got.Name)
+	}
+	if want := "trojan://secret@example.com:443?sni=example.com#HK-01+%5Bx2.0%5D"; got.Config == nil || *got.Config != want {
+		t.Fatalf("expected only the name fragment to change, got %v", *got.Config)
+	}
+
+	// 未设置倍率（内存中构造的提示节点等）不加后缀
+	plain := models.Node{Name: "HK-02", Type: "trojan", Config: &link}
+	if got := ApplyNodeRateSuffix(plain); got.Name != "HK-02" || *got.Config != link {
+		t.Fatalf("rate 1 node should be untouched, got %q", got.Name)
+	}
+}
+
+func TestRenameNodeLinkKeepsUnknownFields(t *testing.T) {
+	vmess := "vmess://" + base64.StdEncoding.EncodeToString([]byte(`{"v":"2","ps":"old","add":"a.com","port":"443","id":"u","x-custom":"keep"}`))
+	renamed := renameNodeLink(vmess, "new")
+	decoded, err := decodeBase64Flexible(strings.TrimPrefix(renamed, "vmess://"))
+	if err != nil || !strings.Contains(decoded, `"ps":"new"`) || !strings.Contains(decoded, `"x-custom":"keep"`) {
+		t.Fatalf("unexpected vmess payload %q (%v)", decoded, err)
+	}
+
+	ssr := "ssr://" + base64.RawURLEncoding.EncodeToString([]byte("a.com:443:origin:aes-256-cfb:plain:cHc/?obfsparam=&remarks="+
+		base64.RawURLEncoding.EncodeToString([]byte("old"))+"&group=Zw"))
+	node, err := ParseSSRLink(renameNodeLink(ssr, "new"))
+	if err != nil || node.Name != "new" {
+		t.Fatalf("unexpected ssr rename: %+v, %v", node, err)
+	}
+	decoded, _ = decodeBase64Flexible(strings.TrimPrefix(renameNodeLink(ssr, "new"), "ssr://"))
+	if !strings.Contains(decoded, "group=Zw") {
+		t.Fatalf("ssr params were lost: %q", decoded)
+	}
+}
@@ -0,0 +1,252 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"cboard/v2/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// ── 导入节点重命名 ──
+// 管理员按顺序配置的规则作用于自动更新与手动导入的节点名称：
+//   - replace：正则查找替换，替换文本支持 ${1} 引用分组
+//   - strip：删除指定文字（如 【官方】、🚀）
+//   - template：按模板重写名称，可用 {flag} {region} {index} {name} {type} {rate}
+//     {index} 为同一地区内的序号（01 起），{rate} 为名称中的倍率（如 0.5x）
+// 每条规则可用 match 正则限定只作用于匹配的节点；会把名称改为空的规则对该节点不生效。
+
+const (
+	NodeRenameReplace  = "replace"
+	NodeRenameStrip    = "strip"
+	NodeRenameTemplate = "template"
+
+	nodeRenameConfigKey = "node_rename_rules"
+)
+
+// NodeRenameRule is one admin-defined rename step.
+type NodeRenameRule struct {
+	Type        string   `json:"type"`
+	Enabled     bool     `json:"enabled"`
+	Match       string   `json:"match"`       // 可选：只处理名称匹配该正则的节点
+	Pattern     string   `json:"pattern"`     // replace：查找正则
+	Replacement string   `json:"replacement"` // replace：替换文本
+	Tokens      []string `json:"tokens"`      // strip：要删除的文字
+	Template    string   `json:"template"`    // template：名称模板
+}
+
+var (
+	nodeRateRe      = regexp.MustCompile(`(?i)(\d+(?:\.\d+)?)\s*(?:x|倍)`)
+	nodeNameSpaceRe = regexp.MustCompile(`\s{2,}`)
+)
+
+type compiledRenameRule struct {
+	NodeRenameRule
+	match   *regexp.Regexp
+	pattern *regexp.Regexp
+}
+
+// NodeRenamer applies a compiled rule list; the zero value leaves names unchanged.
+type NodeRenamer struct {
+	rules []compiledRenameRule
+}
+
+// CompileNodeRenameRules validates the rules and compiles the enabled ones.
+func CompileNodeRenameRules(rules []NodeRenameRule) (*NodeRenamer, error) {
+	r := &NodeRenamer{}
+	for i, rule := range rules {
+		label := fmt.Sprintf("第 %d 条规则", i+1)
+		c := compiledRenameRule{NodeRenameRule: rule}
+		var err error
+		if rule.Match != "" {
+			if c.match, err = regexp.Compile(rule.Match); err != nil {
+				return nil, fmt.Errorf("%s的匹配正则无效: %v", label, err)
+			}
+		}
+		switch rule.Type {
+		case NodeRenameReplace:
+			if rule.Pattern == "" {
+				return nil, fmt.Errorf("%s缺少查找正则", label)
+			}
+			if c.pattern, err = regexp.Compile(rule.Pattern); err != nil {
+				return nil, fmt.Errorf("%s的查找正则无效: %v", label, err)
+			}
+		case NodeRenameStrip:
+			if len(rule.Tokens) == 0 {
+				return nil, fmt.Errorf("%s缺少要删除的文字", label)
+			}
+		case NodeRenameTemplate:
+			if strings.TrimSpace(rule.Template) == "" {
+				return nil, fmt.Errorf("%s缺少名称模板", label)
+			}
+		default:
+			return nil, fmt.Errorf("%s的类型不支持: %s", label, rule.Type)
+		}
+		if rule.Enabled {
+			r.rules = append(r.rules, c)
+		}
+	}
+	return r, nil
+}
+
+// Empty reports whether the renamer has no enabled rules.
+func (r *NodeRenamer) Empty() bool {
+	return r == nil || len(r.rules) == 0
+}
+
+// Apply renames nodes in place, in list order; {index} counts per region within this call.
+func (r *NodeRenamer) Apply(nodes []models.Node) []models.Node {
+	if r.Empty() {
+		return nodes
+	}
+	counters := make(map[string]int)
+	for i := range nodes {
+		node := &nodes[i]
+		region := node.Region
+		if region == "" || region == "其他" {
+			region = DetectRegion(node.Name)
+		}
+		index := 0
+
+		name := node.Name
+		for _, rule := range r.rules {
+			if rule.match != nil && !rule.match.MatchString(name) {
+				continue
+			}
+			renamed := name
+			switch rule.Type {
+			case NodeRenameReplace:
+				renamed = rule.pattern.ReplaceAllString(renamed, rule.Replacement)
+			case NodeRenameStrip:
+				for _, token := range rule.Tokens {
+					if token != "" {
+						renamed = strings.ReplaceAll(renamed, token, "")
+					}
+				}
+			case NodeRenameTemplate:
+				// 只为实际套用模板的节点编号，避免 match 限定范围后序号出现空缺
+				if index == 0 {
+					counters[region]++
+					index = counters[region]
+				}
+				rate := ""
+				if m := nodeRateRe.FindStringSubmatch(renamed); m != nil {
+					rate = m[1] + "x"
+				}
+				renamed = strings.NewReplacer(
+					"{flag}", RegionFlag(region),
+					"{region}", region,
+					"{index}", fmt.Sprintf("%02d", index),
+					"{name}", renamed,
+					"{type}", strings.ToUpper(node.Type),
+					"{rate}", rate,
+				).Replace(rule.Template)
+			}
+			// 规则把名称替换为空时跳过该规则，避免生成无名节点
+			if renamed = strings.TrimSpace(nodeNameSpaceRe.ReplaceAllString(renamed, " ")); renamed != "" {
+				name = renamed
+			}
+		}
+		node.Name = name
+	}
+	return nodes
+}
+
+// PreviewAutoNodeRename runs renamer over the current auto-imported nodes the way the next update would: on the
+// upstream names parsed from their links rather than the stored, already renamed names, then adds the source prefix.
+func PreviewAutoNodeRename(db *gorm.DB, renamer *NodeRenamer, limit int) (before, after []string) {
+	var stored []models.Node
+	db.Where("is_manual = ? AND retired_at IS NULL", false).Order("order_index ASC, id ASC").Limit(limit).Find(&stored)
+	prefixes := make(map[string]string)
+	if cfg, err := GetConfigUpdateService().LoadConfig(); err == nil {
+		for _, src := range cfg.Sources {
+			prefixes[src.key()] = src.Prefix
+		}
+	}
+
+	nodes := make([]models.Node, 0, len(stored))
+	var keys []string
+	for _, node := range stored {
+		if node.Config == nil {
+			continue
+		}
+		parsed, err := parseNodeFromLine(strings.TrimSpace(*node.Config))
+		if err != nil || parsed == nil {
+			continue
+		}
+		nodes = append(nodes, *parsed)
+		before = append(before, parsed.Name)
+		keys = append(keys, node.SourceKey)
+	}
+	renamer.Apply(nodes)
+	for i, node := range nodes {
+		after = append(after, prefixes[keys[i]]+node.Name)
+	}
+	return before, after
+}
+
+// RegionFlag returns the flag emoji of a DetectRegion region name, or "" for unknown regions.
+func RegionFlag(region string) string {
+	for _, keyword := range regionKeywords[region] {
+		if r := []rune(keyword); len(r) == 2 && r[0] >= 0x1F1E6 && r[0] <= 0x1F1FF {
+			return keyword
+		}
+	}
+	return ""
+}
+
+// LoadNodeRenameRules reads the admin rename rules; a missing config means no rules.
+func LoadNodeRenameRules(db *gorm.DB) ([]NodeRenameRule, error) {
+	var cfg models.SystemConfig
+	if err := db.Where("key = ? AND category = ?", nodeRenameConfigKey, "node").First(&cfg).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return []NodeRenameRule{}, nil
+		}
+		return nil, err
+	}
+	rules := []NodeRenameRule{}
+	if err := json.Unmarshal([]byte(cfg.Value), &rules); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// SaveNodeRenameRules validates and stores the admin rename rules.
+func SaveNodeRenameRules(db *gorm.DB, rules []NodeRenameRule) error {
+	if _, err := CompileNodeRenameRules(rules); err != nil {
+		return err
+	}
+	data, err := json.Marshal(rules)
+	if err != nil {
+		return err
+	}
+	var existing models.SystemConfig
+	if err := db.Where("key = ? AND category = ?", nodeRenameConfigKey, "node").First(&existing).Error; err != nil {
+		return db.Create(&models.SystemConfig{
+			Key:         nodeRenameConfigKey,
+			Value:       string(data),
+			Type:        "json",
+			Category:    "node",
+			DisplayName: "节点重命名规则",
+			Description: "自动更新与手动导入节点时按顺序应用的重命名规则",
+		}).Error
+	}
+	return db.Model(&existing).Update("value", string(data)).Error
+}
+
+// LoadNodeRenamer loads and compiles the stored rules; invalid stored rules yield an empty renamer and the error.
+func LoadNodeRenamer(db *gorm.DB) (*NodeRenamer, error) {
+	rules, err := LoadNodeRenameRules(db)
+	if err != nil {
+		return &NodeRenamer{}, err
+	}
+	renamer, err := CompileNodeRenameRules(rules)
+	if err != nil {
+		return &NodeRenamer{}, err
+	}
+	return renamer, nil
+}
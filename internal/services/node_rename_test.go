@@ -0,0 +1,70 @@
+package services
+
+import (
+	"testing"
+
+	"cboard/v2/internal/models"
+)
+
+func TestRegionFlag(t *testing.T) {
+	if got := RegionFlag("日本"); got != "🇯🇵" {
+		t.Fatalf("RegionFlag(日本) = %q", got)
+	}
+	if got := RegionFlag("其他"); got != "" {
+		t.Fatalf("RegionFlag(其他) = %q", got)
+	}
+}
+
+func TestNodeRenamerApply(t *testing.T) {
+	renamer, err := CompileNodeRenameRules([]NodeRenameRule{
+		{Type: NodeRenameStrip, Enabled: true, Tokens: []string{"【官方】", "🚀"}},
+		{Type: NodeRenameReplace, Enabled: true, Pattern: `(?i)\bIEPL\b`, Replacement: "专线"},
+		{Type: NodeRenameReplace, Enabled: false, Pattern: ".*", Replacement: "disabled"},
+		{Type: NodeRenameTemplate, Enabled: true, Match: "专线", Template: "{flag} {region} {index} {rate}"},
+		{Type: NodeRenameReplace, Enabled: true, Pattern: ".*", Replacement: ""},
+	})
+	if err != nil {
+		t.Fatalf("compile: %v", err)
+	}
+	nodes := []models.Node{
+		{Name: "【官方】香港 IEPL 🚀 0.5x", Region: "香港"},
+		{Name: "香港 IEPL 2倍", Region: "香港"},
+		{Name: "日本 普通", Region: "日本"},
+	}
+	renamer.Apply(nodes)
+	want := []string{"🇭🇰 香港 01 0.5x", "🇭🇰 香港 02 2x", "日本 普通"}
+	for i, node := range nodes {
+		if node.Name != want[i] {
+			t.Fatalf("nodes[%d] = %q, want %q", i, node.Name, want[i])
+		}
+	}
+
+	if _, err := CompileNodeRenameRules([]NodeRenameRule{{Type: NodeRenameReplace, Pattern: "("}}); err == nil {
+		t.Fatalf("invalid pattern accepted")
+	}
+	if _, err := CompileNodeRenameRules([]NodeRenameRule{{Type: "upper"}}); err == nil {
+		t.Fatalf("unknown rule type accepted")
+	}
+}
+
+func TestPreviewAutoNodeRenameUsesUpstreamNames(t *testing.T) {
+	db := setupNodeSyncTestDB(t)
+	src := ConfigUpdateSource{URL: "https://a.example.com/sub", Enabled: true, Prefix: "[A] "}
+	if err := GetConfigUpdateService().SaveConfig(&ConfigUpdateConfig{Sources: []ConfigUpdateSource{src}}); err != nil {
+		t.Fatalf("save config: %v", err)
+	}
+	// 库中名称已经过上次更新的模板与前缀处理，预览应从链接中的上游名称开始
+	node := syncTestNode(0, "[A] 🇭🇰 香港 01", "trojan://pw@1.1.1.1:443#香港 01", 0)
+	node.SourceKey = src.key()
+	if err := db.Create(&node).Error; err != nil {
+		t.Fatalf("create node: %v", err)
+	}
+	renamer, err := CompileNodeRenameRules([]NodeRenameRule{{Type: NodeRenameTemplate, Enabled: true, Template: "{flag} {name}"}})
+	if err != nil {
+		t.Fatalf("compile: %v", err)
+	}
+	before, after := PreviewAutoNodeRename(db, renamer, 10)
+	if len(before) != 1 || before[0] != "香港 01" || after[0] != "[A] 🇭🇰 香港 01" {
+		t.Fatalf("preview = %q -> %q", before, after)
+	}
+}
@@ -0,0 +1,274 @@
+package services
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"cboard/v2/internal/models"
+	"cboard/v2/internal/utils"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ── 自建节点对接（XrayR / V2bX UniProxy 协议） ──
+//
+// 节点端使用 node_id + node_type + token 拉取节点配置与可用用户列表，
+// 定时上报每个用户的流量和在线 IP。用户 ID 即订阅 ID，UUID 由订阅地址派生，
+// 重置订阅地址后节点端凭据随之失效。
+
+// serverUUIDNamespace 用于从订阅地址派生节点端 UUID（UUID v5）
+var serverUUIDNamespace = uuid.MustParse("6ba7b811-9dad-11d1-80b4-00c04fd430c8")
+
+// ServerUser 下发给节点端的用户信息
+type ServerUser struct {
+	ID          uint   `json:"id"`
+	UUID        string `json:"uuid"`
+	SpeedLimit  int    `json:"speed_limit"`
+	DeviceLimit int    `json:"device_limit"`
+}
+
+// ServerUUID returns the node credential derived from a subscription URL.
+func ServerUUID(subscriptionURL string) string {
+	return uuid.NewSHA1(serverUUIDNamespace, []byte(subscriptionURL)).String()
+}
+
+// ListServerUsers returns the subscriptions that may currently connect to the given self-hosted node.
+// Grouped nodes only accept subscriptions whose package grants the node's group.
+func ListServerUsers(db *gorm.DB, node *models.Node) ([]ServerUser, error) {
+	var subs []models.Subscription
+	query := db.Select("id, subscription_url, device_limit, speed_limit").
+		Where("is_active = ? AND status = ? AND expire_time > ?", true, "active", time.Now()).
+		Where("traffic_quota = 0 OR upload_traffic + download_traffic < traffic_quota")
+	if node.GroupID != nil {
+		query = query.Where("package_id IN (?)",
+			db.Model(&models.PackageNodeGroup{}).Select("package_id").Where("node_group_id = ?", *node.GroupID))
+	}
+	if err := query.Find(&subs).Error; err != nil {
+		return nil, err
+	}
+	users := make([]ServerUser, 0, len(subs))
+	for _, sub := range subs {
+		users = append(users, ServerUser{
+			ID:          sub.ID,
+			UUID:        ServerUUID(sub.SubscriptionURL),
+			SpeedLimit:  sub.SpeedLimit,
+			DeviceLimit: sub.DeviceLimit,
+		})
+	}
+	return users, nil
+}
+
+// RecordSubscriptionTraffic adds node-reported traffic (bytes) to a subscription.
+func RecordSubscriptionTraffic(db *gorm.DB, subID uint, upload, download int64) error {
+	if upload <= 0 && download <= 0 {
+		return nil
+	}
+	if upload < 0 {
+		upload = 0
+	}
+	if download < 0 {
+		download = 0
+	}
+	return db.Model(&models.Subscription{}).Where("id = ?", subID).Updates(map[string]interface{}{
+		"upload_traffic":   gorm.Expr("upload_traffic + ?", upload),
+		"download_traffic": gorm.Expr("download_traffic + ?", download),
+	}).Error
+}
+
+// BuildServerNodeConfig converts a self-hosted node's share link into the UniProxy config response.
+func BuildServerNodeConfig(node *models.Node) (map[string]interface{}, error) {
+	if node.Config == nil || *node.Config == "" {
+		return nil, fmt.Errorf("节点未配置链接")
+	}
+	m, err := NodeConfigToClashMap(node.Type, *node.Config, node.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := map[string]interface{}{
+		"server_port": toInt(m["port"]),
+		"base_config": map[string]interface{}{
+			"push_interval": utils.GetIntSetting("server_push_interval", 60),
+			"pull_interval": utils.GetIntSetting("server_pull_interval", 60),
+		},
+	}
+	network := stringFromMap(m, "network")
+	if network == "" {
+		network = "tcp"
+	}
+	networkSettings := map[string]interface{}{}
+	if ws, ok := m["ws-opts"].(map[string]interface{}); ok {
+		if p, ok := ws["path"].(string); ok {
+			networkSettings["path"] = p
+		}
+		if h, ok := ws["headers"].(map[string]interface{}); ok {
+			networkSettings["headers"] = h
+		}
+	}
+	if grpc, ok := m["grpc-opts"].(map[string]interface{}); ok {
+		networkSettings["serviceName"] = grpc["grpc-service-name"]
+	}
+	serverName := stringFromMap(m, "servername")
+	if serverName == "" {
+		serverName = stringFromMap(m, "sni")
+	}
+
+	switch node.Type {
+	case "vmess", "vless":
+		resp["network"] = network
+		resp["networkSettings"] = networkSettings
+		tls := 0
+		if boolFromMap(m, "tls") {
+			tls = 1
+		}
+		if reality, ok := m["reality-opts"].(map[string]interface{}); ok {
+			tls = 2
+			resp["tls_settings"] = map[string]interface{}{
+				"server_name": serverName,
+				"short_id":    reality["short-id"],
+			}
+		} else if tls == 1 {
+			resp["tls_settings"] = map[string]interface{}{"server_name": serverName}
+		}
+		resp["tls"] = tls
+		if flow := stringFromMap(m, "flow"); flow != "" {
+			resp["flow"] = flow
+		}
+	case "trojan":
+		resp["host"] = stringFromMap(m, "server")
+		resp["server_name"] = serverName
+		resp["network"] = network
+		resp["networkSettings"] = networkSettings
+	case "ss":
+		resp["cipher"] = stringFromMap(m, "cipher")
+		if strings.HasPrefix(stringFromMap(m, "cipher"), "2022-") {
+			resp["server_key"] = stringFromMap(m, "password")
+		}
+	case "hysteria", "hysteria2":
+		resp["host"] = stringFromMap(m, "server")
+		resp["server_name"] = serverName
+		if node.Type == "hysteria2" {
+			resp["version"] = 2
+		} else {
+			resp["version"] = 1
+		}
+		resp["obfs"] = stringFromMap(m, "obfs-password")
+	case "tuic", "anytls":
+		resp["server_name"] = serverName
+	default:
+		return nil, fmt.Errorf("不支持的节点类型: %s", node.Type)
+	}
+	return resp, nil
+}
+
+// ApplyServerCredential rewrites a self-hosted node link with the subscription's own credential.
+func ApplyServerCredential(node models.Node, subscriptionURL string) models.Node {
+	if !node.IsServer || node.Config == nil {
+		return node
+	}
+	m, err := NodeConfigToClashMap(node.Type, *node.Config, node.Name)
+	if err != nil {
+		return node
+	}
+	cred := ServerUUID(subscriptionURL)
+	switch node.Type {
+	case "vmess", "vless":
+		m["uuid"] = cred
+	case "tuic":
+		m["uuid"] = cred
+		m["password"] = cred
+	case "trojan", "hysteria2", "anytls":
+		m["password"] = cred
+	case "hysteria":
+		m["auth-str"] = cred
+	case "ss":
+		// SS2022 多用户密码格式为 服务端密钥:用户密钥
+		if strings.HasPrefix(stringFromMap(m, "cipher"), "2022-") {
+			m["password"] = stringFromMap(m, "password") + ":" + serverSS2022Key(cred, stringFromMap(m, "cipher"))
+		} else {
+			m["password"] = cred
+		}
+	default:
+		return node
+	}
+	link, _, err := clashProxyToLink(m, node.Type, node.Name)
+	if err != nil {
+		return node
+	}
+	node.Config = &link
+	return node
+}
+
+// serverSS2022Key mirrors the user key V2bX derives for SS2022 ciphers (uuid prefix, base64).
+func serverSS2022Key(cred, cipher string) string {
+	size := 32
+	if cipher == "2022-blake3-aes-128-gcm" {
+		size = 16
+	}
+	return base64.StdEncoding.EncodeToString([]byte(cred[:size]))
+}
+
+// ── 在线 IP 统计（内存，节点端每个上报周期刷新） ──
+
+const serverAliveTTL = 3 * time.Minute
+
+type serverAliveEntry struct {
+	ips       map[uint][]string // subscription ID → IPs
+	updatedAt time.Time
+}
+
+var (
+	serverAliveMu sync.Mutex
+	serverAlive   = make(map[uint]serverAliveEntry) // node ID → entry
+)
+
+// ReportServerAlive replaces the online IP list reported by one node.
+func ReportServerAlive(nodeID uint, data map[string][]string) int {
+	ips := make(map[uint][]string, len(data))
+	for uid, list := range data {
+		id, err := strconv.ParseUint(uid, 10, 64)
+		if err != nil || len(list) == 0 {
+			continue
+		}
+		ips[uint(id)] = list
+	}
+	serverAliveMu.Lock()
+	serverAlive[nodeID] = serverAliveEntry{ips: ips, updatedAt: time.Now()}
+	serverAliveMu.Unlock()
+	return len(ips)
+}
+
+// ServerAliveCounts returns the number of distinct online IPs per subscription across all nodes.
+func ServerAliveCounts() map[uint]int {
+	serverAliveMu.Lock()
+	defer serverAliveMu.Unlock()
+	seen := make(map[uint]map[string]bool)
+	for nodeID, entry := range serverAlive {
+		if time.Since(entry.updatedAt) > serverAliveTTL {
+			delete(serverAlive, nodeID)
+			continue
+		}
+		for uid, list := range entry.ips {
+			if seen[uid] == nil {
+				seen[uid] = make(map[string]bool)
+			}
+			for _, ip := range list {
+				// 节点端上报格式为 "ip_节点ID"，跨节点去重时只看 IP
+				if i := strings.LastIndex(ip, "_"); i > 0 {
+					ip = ip[:i]
+				}
+				seen[uid][ip] = true
+			}
+		}
+	}
+	counts := make(map[uint]int, len(seen))
+	for uid, set := range seen {
+		counts[uid] = len(set)
+	}
+	return counts
+}
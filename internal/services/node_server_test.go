@@ -0,0 +1,51 @@
+package services
+
+import (
+	"strings"
+	"testing"
+
+	"cboard/v2/internal/models"
+)
+
+func TestApplyServerCredentialRewritesSelfHostedNodes(t *testing.T) {
+	link := "trojan://placeholder@node.example.com:443?sni=node.example.com#自建"
+	node := models.Node{Name: "自建", Type: "trojan", Config: &link, IsServer: true}
+
+	got := ApplyServerCredential(node, "sub-token")
+	want := ServerUUID("sub-token")
+	if !strings.HasPrefix(*got.Config, "trojan://"+want+"@node.example.com:443") {
+		t.Fatalf("expected credential %s in link, got %s", want, *got.Config)
+	}
+	if *node.Config != link {
+		t.Fatalf("original node config should not be modified")
+	}
+
+	node.IsServer = false
+	if got := ApplyServerCredential(node, "sub-token"); *got.Config != link {
+		t.Fatalf("imported nodes should keep their link, got %s", *got.Config)
+	}
+}
+
+func TestApplyServerCredentialRewritesHysteriaAuth(t *testing.T) {
+	link := "hysteria://node.example.com:443?auth=old&peer=node.example.com&upmbps=50&downmbps=100#自建"
+	node := models.Node{Name: "自建", Type: "hysteria", Config: &link, IsServer: true}
+
+	got := ApplyServerCredential(node, "sub-token")
+	m, err := NodeConfigToClashMap(got.Type, *got.Config, got.Name)
+	if err != nil {
+		t.Fatalf("rewritten link does not parse: %v", err)
+	}
+	if m["auth-str"] != ServerUUID("sub-token") {
+		t.Fatalf("expected auth %s, got %v (%s)", ServerUUID("sub-token"), m["auth-str"], *got.Config)
+	}
+}
+
+func TestServerAliveCountsDeduplicatesAcrossNodes(t *testing.T) {
+	ReportServerAlive(9001, map[string][]string{"7": {"1.1.1.1_9001", "2.2.2.2_9001"}})
+	ReportServerAlive(9002, map[string][]string{"7": {"1.1.1.1_9002"}, "bad": {"3.3.3.3"}})
+
+	counts := ServerAliveCounts()
+	if counts[7] != 2 {
+		t.Fatalf("expected 2 distinct IPs for subscription 7, got %d", counts[7])
+	}
+}
@@ -0,0 +1,222 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"cboard/v2/internal/models"
+	"cboard/v2/internal/utils"
+
+	"gorm.io/gorm"
+)
+
+// ── 自动导入节点的增量同步 ──
+// 上游节点与库中自动导入节点按身份（协议 + 服务器 + 端口 + 凭据哈希）配对：
+//   - 配对成功：原地更新名称、地区、链接与排序，保留 ID、测速记录及启用状态、分组、倍率等后台设置
+//   - 上游新增：插入新节点
+//   - 上游消失：软下线（只记录 retired_at，不改动 is_active），重新出现时清空 retired_at 自动恢复
+// 同一身份出现多次时按出现顺序一一配对，多出的部分按新增 / 下线处理。
+// 已下线节点由 LiveNodeScope 排除在订阅与节点列表之外，超过保留天数后删除。
+
+// nodeRetiredRetentionDays is the default number of days a retired node is kept before it is deleted.
+const nodeRetiredRetentionDays = 30
+
+// LiveNodeScope restricts a node query to enabled nodes that have not been retired by the upstream sync.
+func LiveNodeScope(q *gorm.DB) *gorm.DB {
+	return q.Where("is_active = ? AND retired_at IS NULL", true)
+}
+
+// nodeIdentityCredentialKeys are the Clash proxy fields that identify the account on a server.
+var nodeIdentityCredentialKeys = []string{"uuid", "password", "auth", "auth-str", "private-key", "psk", "username"}
+
+// NodeIdentity returns a stable identity for a node link, independent of its display name; credentials are
+// only included as part of the hash.
+func NodeIdentity(node models.Node) string {
+	if node.Config == nil || *node.Config == "" {
+		return ""
+	}
+	parts := []string{strings.ToLower(node.Type)}
+	if m, err := NodeConfigToClashMap(node.Type, *node.Config, node.Name); err == nil {
+		parts = append(parts, strings.ToLower(fmt.Sprint(m["server"])), fmt.Sprint(m["port"]))
+		for _, key := range nodeIdentityCredentialKeys {
+			if v, ok := m[key]; ok {
+				parts = append(parts, key+"="+fmt.Sprint(v))
+			}
+		}
+	} else {
+		// 无法解析的链接去掉 #名称 后整体参与计算
+		link, _, _ := strings.Cut(strings.TrimSpace(*node.Config), "#")
+		parts = append(parts, link)
+	}
+	sum := sha256.Sum256([]byte(strings.Join(parts, "|")))
+	return hex.EncodeToString(sum[:16])
+}
+
+// NodeSyncSummary counts the changes made by one sync run; the name lists are capped for logging.
+type NodeSyncSummary struct {
+	Created   int
+	Updated   int
+	Unchanged int
+	Restored  int
+	Retired   int
+	Purged    int64
+
+	CreatedNames []string
+	UpdatedNames []string
+	RetiredNames []string
+}
+
+const nodeSyncLogNames = 10
+
+func (s *NodeSyncSummary) String() string {
+	text := fmt.Sprintf("新增 %d，更新 %d（其中恢复 %d），未变 %d，下线 %d", s.Created, s.Updated, s.Restored, s.Unchanged, s.Retired)
+	if s.Purged > 0 {
+		text += fmt.Sprintf("，清理过期下线节点 %d", s.Purged)
+	}
+	return text
+}
+
+func appendSyncName(names []string, name string) []string {
+	if len(names) < nodeSyncLogNames {
+		return append(names, name)
+	}
+	return names
+}
+
+type nodeSyncUpdate struct {
+	node    models.Node
+	changes map[string]interface{}
+}
+
+type nodeSyncPlan struct {
+	creates []models.Node
+	updates []nodeSyncUpdate
+	retires []models.Node
+	summary NodeSyncSummary
+}
+
+// planNodeSync pairs incoming nodes (with OrderIndex / SourceIndex / SourceKey already assigned) with the existing
+// auto-imported nodes and works out the rows to insert, update and retire.
+func planNodeSync(existing, incoming []models.Node) nodeSyncPlan {
+	var plan nodeSyncPlan
+	pool := make(map[string][]models.Node)
+	for _, node := range existing {
+		key := NodeIdentity(node)
+		pool[key] = append(pool[key], node)
+	}
+
+	for _, node := range incoming {
+		key := NodeIdentity(node)
+		candidates := pool[key]
+		if key == "" || len(candidates) == 0 {
+			plan.creates = append(plan.creates, node)
+			plan.summary.Created++
+			plan.summary.CreatedNames = appendSyncName(plan.summary.CreatedNames, node.Name)
+			continue
+		}
+		old := candidates[0]
+		pool[key] = candidates[1:]
+
+		changes := make(map[string]interface{})
+		if old.Name != node.Name {
+			changes["name"] = node.Name
+		}
+		if old.Region != node.Region {
+			changes["region"] = node.Region
+		}
+		if old.Type != node.Type {
+			changes["type"] = node.Type
+		}
+		if old.Config == nil || *old.Config != *node.Config {
+			changes["config"] = *node.Config
+		}
+		if old.SourceIndex != node.SourceIndex {
+			changes["source_index"] = node.SourceIndex
+		}
+		if old.SourceKey != node.SourceKey {
+			changes["source_key"] = node.SourceKey
+		}
+		if old.OrderIndex != node.OrderIndex {
+			changes["order_index"] = node.OrderIndex
+		}
+		if old.RetiredAt != nil {
+			changes["retired_at"] = nil
+			plan.summary.Restored++
+		}
+		if len(changes) == 0 {
+			plan.summary.Unchanged++
+			continue
+		}
+		plan.updates = append(plan.updates, nodeSyncUpdate{node: old, changes: changes})
+		plan.summary.Updated++
+		plan.summary.UpdatedNames = appendSyncName(plan.summary.UpdatedNames, node.Name)
+	}
+
+	for _, node := range existing {
+		key := NodeIdentity(node)
+		if len(pool[key]) == 0 || pool[key][0].ID != node.ID {
+			continue
+		}
+		pool[key] = pool[key][1:]
+		if node.RetiredAt != nil {
+			continue
+		}
+		plan.retires = append(plan.retires, node)
+		plan.summary.Retired++
+		plan.summary.RetiredNames = appendSyncName(plan.summary.RetiredNames, node.Name)
+	}
+	return plan
+}
+
+// SyncAutoNodes applies incoming upstream nodes to the auto-imported (is_manual = false) nodes in one transaction;
+// nodes whose SourceKey is in keepSources (sources that failed or are disabled) are left untouched. Nodes retired for
+// longer than node_retired_retention_days (default 30, 0 keeps them forever) are deleted afterwards.
+func SyncAutoNodes(db *gorm.DB, incoming []models.Node, keepSources map[string]bool) (*NodeSyncSummary, error) {
+	var all []models.Node
+	if err := db.Where("is_manual = ?", false).Order("order_index ASC, id ASC").Find(&all).Error; err != nil {
+		return nil, err
+	}
+	existing := all[:0]
+	for _, node := range all {
+		if !keepSources[node.SourceKey] {
+			existing = append(existing, node)
+		}
+	}
+	plan := planNodeSync(existing, incoming)
+	now := time.Now()
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		for _, u := range plan.updates {
+			if err := tx.Model(&models.Node{}).Where("id = ?", u.node.ID).Updates(u.changes).Error; err != nil {
+				return fmt.Errorf("更新节点失败(%s): %w", u.node.Name, err)
+			}
+		}
+		for i := range plan.creates {
+			plan.creates[i].IsManual = false
+			if err := tx.Create(&plan.creates[i]).Error; err != nil {
+				return fmt.Errorf("导入节点失败(%s): %w", plan.creates[i].Name, err)
+			}
+		}
+		for _, node := range plan.retires {
+			if err := tx.Model(&models.Node{}).Where("id = ?", node.ID).
+				Update("retired_at", now).Error; err != nil {
+				return fmt.Errorf("下线节点失败(%s): %w", node.Name, err)
+			}
+		}
+		if days := utils.GetIntSetting("node_retired_retention_days", nodeRetiredRetentionDays); days > 0 {
+			result := tx.Where("is_manual = ? AND retired_at < ?", false, now.AddDate(0, 0, -days)).Delete(&models.Node{})
+			if result.Error != nil {
+				return fmt.Errorf("清理下线节点失败: %w", result.Error)
+			}
+			plan.summary.Purged = result.RowsAffected
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &plan.summary, nil
+}
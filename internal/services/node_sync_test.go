@@ -0,0 +1,164 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"cboard/v2/internal/database"
+	"cboard/v2/internal/models"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func syncTestNode(id uint, name, link string, order int) models.Node {
+	return models.Node{ID: id, Name: name, Type: "trojan", Region: DetectRegion(name), Config: &link, OrderIndex: order}
+}
+
+func TestNodeIdentityIgnoresName(t *testing.T) {
+	a := syncTestNode(0, "香港 01", "trojan://pw@1.2.3.4:443#a", 0)
+	b := syncTestNode(0, "香港 01 改名", "trojan://pw@1.2.3.4:443?sni=x.com#b", 0)
+	c := syncTestNode(0, "香港 01", "trojan://other@1.2.3.4:443#a", 0)
+	if NodeIdentity(a) != NodeIdentity(b) {
+		t.Fatalf("identity changed with name / params")
+	}
+	if NodeIdentity(a) == NodeIdentity(c) {
+		t.Fatalf("identity ignored the credential")
+	}
+}
+
+func TestPlanNodeSync(t *testing.T) {
+	retired := time.Now()
+	existing := []models.Node{
+		syncTestNode(1, "香港 01", "trojan://pw@1.1.1.1:443#香港 01", 0),
+		syncTestNode(2, "日本 01", "trojan://pw@2.2.2.2:443#日本 01", 1),
+		syncTestNode(3, "美国 01", "trojan://pw@3.3.3.3:443#美国 01", 2),
+		syncTestNode(4, "新加坡 01", "trojan://pw@4.4.4.4:443#新加坡 01", 3),
+	}
+	existing[3].RetiredAt = &retired
+	incoming := []models.Node{
+		syncTestNode(0, "香港 01", "trojan://pw@1.1.1.1:443#香港 01", 0),
+		syncTestNode(0, "日本 01 IPLC", "trojan://pw@2.2.2.2:443#日本 01 IPLC", 1),
+		syncTestNode(0, "新加坡 01", "trojan://pw@4.4.4.4:443#新加坡 01", 2),
+		syncTestNode(0, "台湾 01", "trojan://pw@5.5.5.5:443#台湾 01", 3),
+	}
+	plan := planNodeSync(existing, incoming)
+
+	s := plan.summary
+	if s.Created != 1 || s.Updated != 2 || s.Restored != 1 || s.Unchanged != 1 || s.Retired != 1 {
+		t.Fatalf("summary = %s", s.String())
+	}
+	if len(plan.retires) != 1 || plan.retires[0].ID != 3 {
+		t.Fatalf("retired = %+v, want node 3", plan.retires)
+	}
+	for _, u := range plan.updates {
+		switch u.node.ID {
+		case 2:
+			if u.changes["name"] != "日本 01 IPLC" || u.changes["config"] == nil {
+				t.Fatalf("node 2 changes = %v", u.changes)
+			}
+		case 4:
+			if _, restored := u.changes["retired_at"]; !restored || u.changes["is_active"] != nil || u.changes["order_index"] != 2 {
+				t.Fatalf("node 4 changes = %v", u.changes)
+			}
+		default:
+			t.Fatalf("unexpected update for node %d", u.node.ID)
+		}
+	}
+	if len(plan.creates) != 1 || plan.creates[0].Name != "台湾 01" {
+		t.Fatalf("creates = %+v", plan.creates)
+	}
+}
+
+func setupNodeSyncTestDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	if err := db.AutoMigrate(&models.Node{}, &models.SystemConfig{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	// 保留天数通过全局连接读取
+	previous := database.DB
+	database.DB = db
+	t.Cleanup(func() { database.DB = previous })
+	return db
+}
+
+func TestSyncAutoNodesKeepsDisabledState(t *testing.T) {
+	db := setupNodeSyncTestDB(t)
+
+	disabled := syncTestNode(0, "香港 01", "trojan://pw@1.1.1.1:443#香港 01", 0)
+	stale := syncTestNode(0, "日本 01", "trojan://pw@2.2.2.2:443#日本 01", 1)
+	longAgo := time.Now().AddDate(0, 0, -nodeRetiredRetentionDays-1)
+	stale.RetiredAt = &longAgo
+	for _, node := range []*models.Node{&disabled, &stale} {
+		if err := db.Create(node).Error; err != nil {
+			t.Fatalf("create node: %v", err)
+		}
+	}
+	if err := db.Model(&disabled).Update("is_active", false).Error; err != nil {
+		t.Fatalf("disable node: %v", err)
+	}
+
+	summary, err := SyncAutoNodes(db, nil, nil)
+	if err != nil || summary.Retired != 1 || summary.Purged != 1 {
+		t.Fatalf("retire run: %+v, %v", summary, err)
+	}
+	var got models.Node
+	db.First(&got, disabled.ID)
+	if got.RetiredAt == nil || got.IsActive {
+		t.Fatalf("after retire: retired_at=%v is_active=%v", got.RetiredAt, got.IsActive)
+	}
+	if db.First(&models.Node{}, stale.ID).Error == nil {
+		t.Fatalf("node retired past the retention period was kept")
+	}
+
+	back := syncTestNode(0, "香港 01", "trojan://pw@1.1.1.1:443#香港 01", 0)
+	if summary, err = SyncAutoNodes(db, []models.Node{back}, nil); err != nil || summary.Restored != 1 {
+		t.Fatalf("restore run: %+v, %v", summary, err)
+	}
+	got = models.Node{}
+	db.First(&got, disabled.ID)
+	if got.RetiredAt != nil || got.IsActive {
+		t.Fatalf("after restore: retired_at=%v is_active=%v, want restored but still disabled", got.RetiredAt, got.IsActive)
+	}
+	var live int64
+	db.Model(&models.Node{}).Scopes(LiveNodeScope).Count(&live)
+	if live != 0 {
+		t.Fatalf("disabled node is visible to subscriptions")
+	}
+}
+
+func TestSyncAutoNodesKeepsSourcesByKey(t *testing.T) {
+	db := setupNodeSyncTestDB(t)
+	a := ConfigUpdateSource{URL: "https://a.example.com/sub"}
+	b := ConfigUpdateSource{URL: "https://b.example.com/sub"}
+	// 旧版本导入的节点只有 source_index：按当前来源顺序补全 source_key
+	legacyA := syncTestNode(0, "香港 01", "trojan://pw@1.1.1.1:443#香港 01", 0)
+	legacyA.SourceIndex = 1
+	legacyB := syncTestNode(0, "日本 01", "trojan://pw@2.2.2.2:443#日本 01", 1)
+	legacyB.SourceIndex = 2
+	for _, node := range []*models.Node{&legacyA, &legacyB} {
+		if err := db.Create(node).Error; err != nil {
+			t.Fatalf("create node: %v", err)
+		}
+	}
+	backfillNodeSourceKeys(db, []ConfigUpdateSource{a, {URL: configUpdateManualPlaceholder}, b})
+
+	// 来源 A 被移到第二位后获取失败：其节点按地址保留，B 的节点照常下线
+	summary, err := SyncAutoNodes(db, nil, map[string]bool{a.key(): true})
+	if err != nil || summary.Retired != 1 {
+		t.Fatalf("sync: %+v, %v", summary, err)
+	}
+	var got models.Node
+	db.First(&got, legacyA.ID)
+	if got.SourceKey != a.key() || got.RetiredAt != nil {
+		t.Fatalf("source A node: key=%q retired_at=%v", got.SourceKey, got.RetiredAt)
+	}
+	got = models.Node{}
+	db.First(&got, legacyB.ID)
+	if got.SourceKey != b.key() || got.RetiredAt == nil {
+		t.Fatalf("source B node: key=%q retired_at=%v", got.SourceKey, got.RetiredAt)
+	}
+}
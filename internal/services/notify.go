@@ -55,7 +55,7 @@ func userPrefAllowed(user *models.User, emailTemplate string) bool {
 		return user.NotifyOrder
 	case "expiry_reminder", "expiry_notice":
 		return user.NotifyExpiry
-	case "subscription_reset", "account_enabled", "account_disabled", "account_deleted":
+	case "subscription_reset", "subscription_leak", "subscription_resumed", "account_enabled", "account_disabled", "account_deleted":
 		return user.NotifySubscription
 	case "abnormal_login":
 		return user.AbnormalLoginAlertEnabled
@@ -138,6 +138,8 @@ func NotifyAdmin(eventType string, data map[string]string) {
 		settingKey = "notify_unpaid_order"
 	case "expiry_reminder":
 		settingKey = "notify_expiry_reminder"
+	case "subscription_leak":
+		// 是否通知由订阅泄露检测策略（sub_leak_action）决定，不再单独开关
 	default:
 		return
 	}
@@ -150,7 +152,7 @@ func NotifyAdmin(eventType string, data map[string]string) {
 		"site_name",
 	)
 
-	if settings[settingKey] != "true" && settings[settingKey] != "1" {
+	if settingKey != "" && settings[settingKey] != "true" && settings[settingKey] != "1" {
 		return
 	}
 
@@ -115,6 +115,18 @@ func GetNotifyTemplate(eventType string) *NotifyTemplate {
 				{"⏰", "到期时间", "expire_time"},
 			},
 		},
+		"subscription_leak": {
+			Emoji: "🕵️",
+			Title: "订阅疑似泄露",
+			Fields: []NotifyField{
+				{"👤", "用户", "username"},
+				{"🆔", "订阅ID", "sub_id"},
+				{"🌐", "独立IP数", "ip_count"},
+				{"⏱️", "统计窗口", "window"},
+				{"🔧", "处理动作", "action"},
+			},
+			Footer: "⚠️ 可在后台「泄露检测」中查看并解除标记",
+		},
 		"security_alert": {
 			Emoji: "🚨",
 			Title: "安全告警",
@@ -0,0 +1,360 @@
+package services
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"cboard/v2/internal/models"
+)
+
+// ── Surge / Surfboard / Loon / Quantumult X 配置模板 ──
+// 模板是完整的客户端配置文本，以下占位符在下发时展开：
+//   - 独占一行的 {{proxies}}：节点行（Surge/Surfboard/Loon 放在 [Proxy]，Quantumult X 放在 [server_local]）
+//   - 独占一行的 {{region_groups}}：按 DetectRegion 自动生成的地区测速分组，每个地区一行
+//   - 分组行中的 {{all}} / {{regions}}：展开为全部节点名 / 地区分组名
+//   - {{site}}：站点地址
+// 分组展开后若没有任何成员，会自动填入 DIRECT，避免客户端因空分组拒绝加载。
+
+const (
+	profilePlaceholderProxies      = "{{proxies}}"
+	profilePlaceholderRegionGroups = "{{region_groups}}"
+	profilePlaceholderAll          = "{{all}}"
+	profilePlaceholderRegions      = "{{regions}}"
+	profilePlaceholderSite         = "{{site}}"
+
+	profileTestURL = "http://www.gstatic.com/generate_204"
+)
+
+// profileFormat describes how one INI-style client renders nodes and groups.
+type profileFormat struct {
+	proxySection    string
+	direct          string
+	file            string
+	defaultTemplate string
+	proxyLine       func(node models.Node, name string) string
+	regionGroup     func(name string, members []string) string
+}
+
+var profileFormats = map[string]profileFormat{
+	ConfigTemplateFormatSurge: {
+		proxySection:    "[Proxy]",
+		direct:          "DIRECT",
+		file:            "uploads/config/surge_temp.conf",
+		defaultTemplate: defaultSurgeTemplate,
+		proxyLine: func(node models.Node, name string) string {
+			node.Name = name
+			return convertNodeToSurgeLine(node)
+		},
+		regionGroup: func(name string, members []string) string {
+			return fmt.Sprintf("%s = url-test, %s, url=%s, interval=300, tolerance=50", name, strings.Join(members, ", "), profileTestURL)
+		},
+	},
+	ConfigTemplateFormatSurfboard: {
+		proxySection:    "[Proxy]",
+		direct:          "DIRECT",
+		file:            "uploads/config/surfboard_temp.conf",
+		defaultTemplate: defaultSurfboardTemplate,
+		proxyLine:       surfboardLine,
+		regionGroup: func(name string, members []string) string {
+			return fmt.Sprintf("%s = url-test, %s, url=%s, interval=600, tolerance=50", name, strings.Join(members, ", "), profileTestURL)
+		},
+	},
+	ConfigTemplateFormatLoon: {
+		proxySection:    "[Proxy]",
+		direct:          "DIRECT",
+		file:            "uploads/config/loon_temp.conf",
+		defaultTemplate: defaultLoonTemplate,
+		proxyLine: func(node models.Node, name string) string {
+			m, err := NodeConfigToClashMap(node.Type, *node.Config, node.Name)
+			if err != nil {
+				return ""
+			}
+			return clashMapToLoonLine(name, m)
+		},
+		regionGroup: func(name string, members []string) string {
+			return fmt.Sprintf("%s = url-test, %s, url=%s, interval=600", name, strings.Join(members, ", "), profileTestURL)
+		},
+	},
+	ConfigTemplateFormatQuantumultX: {
+		proxySection:    "[server_local]",
+		direct:          "direct",
+		file:            "uploads/config/quanx_temp.conf",
+		defaultTemplate: defaultQuantumultXTemplate,
+		proxyLine:       quantumultXLine,
+		regionGroup: func(name string, members []string) string {
+			return fmt.Sprintf("url-latency-benchmark=%s, %s, check-interval=600, tolerance=50, alive-checking=false", name, strings.Join(members, ", "))
+		},
+	},
+}
+
+const defaultSurgeTemplate = `# {{site}} Surge Config
+[General]
+loglevel = notify
+dns-server = 223.5.5.5, 119.29.29.29, system
+skip-proxy = 127.0.0.1, 192.168.0.0/16, 10.0.0.0/8, 172.16.0.0/12, 100.64.0.0/10, localhost, *.local
+internet-test-url = http://www.gstatic.com/generate_204
+proxy-test-url = http://www.gstatic.com/generate_204
+
+[Proxy]
+DIRECT = direct
+{{proxies}}
+
+[Proxy Group]
+Proxy = select, AutoTest, {{regions}}, DIRECT, {{all}}
+AutoTest = url-test, {{all}}, url=http://www.gstatic.com/generate_204, interval=300, tolerance=50
+{{region_groups}}
+
+[Rule]
+DOMAIN-SET,https://cdn.jsdelivr.net/gh/Loyalsoldier/surge-rules@release/reject.txt,REJECT
+DOMAIN-SET,https://cdn.jsdelivr.net/gh/Loyalsoldier/surge-rules@release/proxy.txt,Proxy
+DOMAIN-SET,https://cdn.jsdelivr.net/gh/Loyalsoldier/surge-rules@release/direct.txt,DIRECT
+RULE-SET,https://cdn.jsdelivr.net/gh/Loyalsoldier/surge-rules@release/cncidr.txt,DIRECT
+GEOIP,CN,DIRECT,no-resolve
+FINAL,Proxy,dns-failed
+`
+
+const defaultLoonTemplate = `# {{site}} - Loon Config
+
+[General]
+ip-mode = dual
+dns-server = system, 223.5.5.5, 119.29.29.29
+skip-proxy = 192.168.0.0/16, 10.0.0.0/8, 172.16.0.0/12, localhost, *.local
+bypass-tun = 10.0.0.0/8, 100.64.0.0/10, 127.0.0.0/8, 169.254.0.0/16, 172.16.0.0/12, 192.0.0.0/24, 192.168.0.0/16, 224.0.0.0/4, 255.255.255.255/32
+
+[Proxy]
+{{proxies}}
+
+[Proxy Group]
+Proxy = select, AutoTest, {{regions}}, DIRECT, {{all}}
+AutoTest = url-test, {{all}}, url=http://www.gstatic.com/generate_204, interval=600
+{{region_groups}}
+
+[Remote Rule]
+https://raw.githubusercontent.com/blackmatrix7/ios_rule_script/master/rule/Loon/Advertising/Advertising.list, policy=REJECT, tag=Advertising, enabled=true
+https://raw.githubusercontent.com/blackmatrix7/ios_rule_script/master/rule/Loon/Global/Global.list, policy=Proxy, tag=Global, enabled=true
+https://raw.githubusercontent.com/blackmatrix7/ios_rule_script/master/rule/Loon/ChinaMax/ChinaMax.list, policy=DIRECT, tag=ChinaMax, enabled=true
+
+[Rule]
+GEOIP,CN,DIRECT,no-resolve
+FINAL,Proxy
+`
+
+const defaultQuantumultXTemplate = `[general]
+network_check_url = http://www.gstatic.com/generate_204
+server_check_url = http://www.gstatic.com/generate_204
+geo_location_checker = http://www.gstatic.com/generate_204
+
+[dns]
+server = 223.5.5.5
+server = 119.29.29.29
+
+[policy]
+static=Proxy, AutoTest, {{regions}}, direct, {{all}}
+url-latency-benchmark=AutoTest, {{all}}, check-interval=600, tolerance=50, alive-checking=false
+{{region_groups}}
+
+[server_remote]
+
+[server_local]
+{{proxies}}
+
+[filter_remote]
+https://raw.githubusercontent.com/blackmatrix7/ios_rule_script/master/rule/QuantumultX/Advertising/Advertising.list, tag=Advertising, force-policy=REJECT, update-interval=86400, opt-parser=true, enabled=true
+https://raw.githubusercontent.com/blackmatrix7/ios_rule_script/master/rule/QuantumultX/Global/Global.list, tag=Global, force-policy=Proxy, update-interval=86400, opt-parser=true, enabled=true
+https://raw.githubusercontent.com/blackmatrix7/ios_rule_script/master/rule/QuantumultX/China/China.list, tag=China, force-policy=DIRECT, update-interval=86400, opt-parser=true, enabled=true
+
+[filter_local]
+GEOIP,CN,DIRECT
+FINAL,Proxy
+
+[rewrite_remote]
+
+[rewrite_local]
+
+[task_local]
+
+[mitm]
+skip_validating_cert = true
+`
+
+// profileNodes holds the rendered node lines and the region grouping derived from node names.
+type profileNodes struct {
+	lines   []string
+	names   []string
+	regions []string            // 地区分组名，按节点首次出现的顺序
+	members map[string][]string // 地区分组名 -> 节点名
+}
+
+// buildProfileNodes converts nodes to proxy lines with unique names and groups them by DetectRegion.
+func buildProfileNodes(f profileFormat, nodes []models.Node) profileNodes {
+	var lines []string
+	p := collectProfileNodes(nodes, func(node models.Node, name string) bool {
+		line := f.proxyLine(node, name)
+		if line == "" {
+			return false
+		}
+		lines = append(lines, line)
+		return true
+	})
+	p.lines = lines
+	return p
+}
+
+// collectProfileNodes walks the usable nodes with a unique comma-safe name each; convert returns false to skip a node.
+// Accepted nodes are grouped into regions by DetectRegion.
+func collectProfileNodes(nodes []models.Node, convert func(node models.Node, name string) bool) profileNodes {
+	p := profileNodes{members: make(map[string][]string)}
+	used := make(map[string]bool)
+	for _, node := range nodes {
+		if node.Config == nil || *node.Config == "" {
+			continue
+		}
+		// Skip info nodes (server = baidu.com placeholder)
+		if isInfoNode(node) {
+			continue
+		}
+		name := formatSafeCommaName(node.Name)
+		if name == "" {
+			name = "Node"
+		}
+		for base, i := name, 1; used[name]; i++ {
+			name = fmt.Sprintf("%s_%d", base, i)
+		}
+		if !convert(node, name) {
+			continue
+		}
+		used[name] = true
+		p.names = append(p.names, name)
+
+		region := DetectRegion(node.Name)
+		if region == "其他" {
+			continue
+		}
+		group := region + "节点"
+		if _, ok := p.members[group]; !ok {
+			p.regions = append(p.regions, group)
+		}
+		p.members[group] = append(p.members[group], name)
+	}
+	return p
+}
+
+// validateProfileTemplate checks the placeholders renderProfileTemplate relies on.
+func validateProfileTemplate(f profileFormat, content string) error {
+	hasSection, hasProxies := false, false
+	for _, line := range strings.Split(content, "\n") {
+		switch strings.TrimSpace(line) {
+		case f.proxySection:
+			hasSection = true
+		case profilePlaceholderProxies:
+			hasProxies = true
+		}
+	}
+	if !hasSection {
+		return fmt.Errorf("模板缺少 %s 段", f.proxySection)
+	}
+	if !hasProxies {
+		return fmt.Errorf("模板 %s 段中需要独占一行的 %s 占位符", f.proxySection, profilePlaceholderProxies)
+	}
+	if !strings.Contains(content, profilePlaceholderAll) && !strings.Contains(content, profilePlaceholderRegionGroups) {
+		return fmt.Errorf("模板至少需要在分组中使用 %s 或 %s", profilePlaceholderAll, profilePlaceholderRegionGroups)
+	}
+	return nil
+}
+
+// renderProfileTemplate expands the placeholders of a Surge / Loon / Quantumult X template.
+func renderProfileTemplate(f profileFormat, content string, p profileNodes, siteName string) string {
+	var sb strings.Builder
+	for _, line := range strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n") {
+		switch strings.TrimSpace(line) {
+		case profilePlaceholderProxies:
+			for _, l := range p.lines {
+				sb.WriteString(l + "\n")
+			}
+			continue
+		case profilePlaceholderRegionGroups:
+			for _, region := range p.regions {
+				sb.WriteString(f.regionGroup(region, p.members[region]) + "\n")
+			}
+			continue
+		}
+		line = strings.ReplaceAll(line, profilePlaceholderSite, siteName)
+		if strings.Contains(line, profilePlaceholderAll) || strings.Contains(line, profilePlaceholderRegions) {
+			line = expandProfileGroupLine(line, p, f.direct)
+		}
+		sb.WriteString(line + "\n")
+	}
+	return strings.TrimRight(sb.String(), "\n") + "\n"
+}
+
+// expandProfileGroupLine expands {{all}} / {{regions}} in a comma-separated group line,
+// removing duplicates and falling back to the direct policy when no member is left.
+func expandProfileGroupLine(line string, p profileNodes, direct string) string {
+	fields := strings.Split(line, ",")
+	out := []string{strings.TrimSpace(fields[0])}
+	seen := make(map[string]bool)
+	members := 0
+	add := func(v string) {
+		if v == "" || seen[v] {
+			return
+		}
+		seen[v] = true
+		out = append(out, v)
+		if !strings.Contains(v, "=") {
+			members++
+		}
+	}
+	for _, field := range fields[1:] {
+		switch v := strings.TrimSpace(field); v {
+		case profilePlaceholderAll:
+			for _, n := range p.names {
+				add(n)
+			}
+		case profilePlaceholderRegions:
+			for _, r := range p.regions {
+				add(r)
+			}
+		default:
+			add(v)
+		}
+	}
+	if members == 0 {
+		out = append(out[:1], append([]string{direct}, out[1:]...)...)
+	}
+	return strings.Join(out, ", ")
+}
+
+// generateProfileConfig renders nodes with the admin template, then the template file, then the built-in default.
+func generateProfileConfig(format string, nodes []models.Node, siteName, template string) string {
+	f := profileFormats[format]
+	p := buildProfileNodes(f, nodes)
+	if template != "" && validateProfileTemplate(f, template) == nil {
+		return renderProfileTemplate(f, template, p, siteName)
+	}
+	if data, err := os.ReadFile(f.file); err == nil && validateProfileTemplate(f, string(data)) == nil {
+		return renderProfileTemplate(f, string(data), p, siteName)
+	}
+	return renderProfileTemplate(f, f.defaultTemplate, p, siteName)
+}
+
+func profileTemplateValidator(format string) func(content string) error {
+	return func(content string) error {
+		return validateProfileTemplate(profileFormats[format], content)
+	}
+}
+
+// GenerateSurgeConfigWithTemplate generates a full Surge profile; an empty template uses surge_temp.conf or the built-in default.
+func GenerateSurgeConfigWithTemplate(nodes []models.Node, siteName, template string) string {
+	return generateProfileConfig(ConfigTemplateFormatSurge, nodes, siteName, template)
+}
+
+// GenerateLoonConfigWithTemplate generates a full Loon profile; an empty template uses loon_temp.conf or the built-in default.
+func GenerateLoonConfigWithTemplate(nodes []models.Node, siteName, template string) string {
+	return generateProfileConfig(ConfigTemplateFormatLoon, nodes, siteName, template)
+}
+
+// GenerateQuantumultXConfigWithTemplate generates a full Quantumult X profile; an empty template uses quanx_temp.conf or the built-in default.
+func GenerateQuantumultXConfigWithTemplate(nodes []models.Node, siteName, template string) string {
+	return generateProfileConfig(ConfigTemplateFormatQuantumultX, nodes, siteName, template)
+}
@@ -0,0 +1,59 @@
+package services
+
+import (
+	"strings"
+	"testing"
+)
+
+func profileLine(t *testing.T, out, prefix string) string {
+	t.Helper()
+	for _, line := range strings.Split(out, "\n") {
+		if strings.HasPrefix(line, prefix) {
+			return line
+		}
+	}
+	t.Fatalf("line %q not found in:\n%s", prefix, out)
+	return ""
+}
+
+func TestGenerateSurgeConfigRegionGroups(t *testing.T) {
+	out := GenerateSurgeConfigWithTemplate(templateTestNodes(testTrojanNode("香港 01", "hk2.example.com"), testTrojanNode("Relay", "x.example.com")), "example.com", "")
+	if strings.Contains(out, "{{") {
+		t.Fatalf("unexpanded placeholder:\n%s", out)
+	}
+	if got := profileLine(t, out, "Proxy = "); got != "Proxy = select, AutoTest, 香港节点, 日本节点, DIRECT, 香港 01, 日本 01, 香港 01_1, Relay" {
+		t.Fatalf("unexpected Proxy group: %s", got)
+	}
+	if got := profileLine(t, out, "香港节点 = "); !strings.HasPrefix(got, "香港节点 = url-test, 香港 01, 香港 01_1, url=") {
+		t.Fatalf("unexpected region group: %s", got)
+	}
+	if !strings.Contains(out, "香港 01_1 = trojan, hk2.example.com") || strings.Contains(out, "baidu.com") {
+		t.Fatalf("unexpected proxy lines:\n%s", out)
+	}
+}
+
+func TestGenerateQuantumultXConfigWithoutNodes(t *testing.T) {
+	out := GenerateQuantumultXConfigWithTemplate(nil, "", "")
+	if got := profileLine(t, out, "static=Proxy"); got != "static=Proxy, AutoTest, direct" {
+		t.Fatalf("unexpected static policy: %s", got)
+	}
+	if got := profileLine(t, out, "url-latency-benchmark=AutoTest"); !strings.HasPrefix(got, "url-latency-benchmark=AutoTest, direct, check-interval") {
+		t.Fatalf("empty group should fall back to direct: %s", got)
+	}
+}
+
+func TestValidateProfileTemplate(t *testing.T) {
+	valid := "[Proxy]\n{{proxies}}\n[Proxy Group]\nProxy = select, {{all}}\n"
+	if err := ValidateConfigTemplate(ConfigTemplateFormatLoon, valid); err != nil {
+		t.Fatalf("valid template rejected: %v", err)
+	}
+	if err := ValidateConfigTemplate(ConfigTemplateFormatQuantumultX, valid); err == nil {
+		t.Fatalf("QuantumultX template without [server_local] accepted")
+	}
+	if err := ValidateConfigTemplate(ConfigTemplateFormatSurge, "[Proxy]\n[Proxy Group]\nProxy = select, {{all}}\n"); err == nil {
+		t.Fatalf("template without {{proxies}} accepted")
+	}
+	if err := ValidateConfigTemplate(ConfigTemplateFormatSurge, "[Proxy]\n{{proxies}}\n"); err == nil {
+		t.Fatalf("template without groups accepted")
+	}
+}
@@ -48,6 +48,8 @@ func (s *Scheduler) Start() {
 
 	s.startLoop("EmailQueue", 30*time.Second, processEmailQueueTask)
 	s.startLoop("DeactivateExpired", 30*time.Minute, deactivateExpiredTask)
+	s.startLoop("TrafficReset", 10*time.Minute, resetSubscriptionTrafficTask)
+	s.startLoop("ResumePaused", 10*time.Minute, resumePausedSubscriptionsTask)
 	s.startLoop("ExpiryCheck", 1*time.Hour, checkExpiryStatusTask)
 	s.startLoop("ExpiryReminder", 6*time.Hour, sendExpiryRemindersTask)
 	s.startLoop("UnpaidOrderReminder", 1*time.Hour, sendUnpaidOrderRemindersTask)
@@ -121,6 +123,60 @@ func deactivateExpiredTask() {
 	}
 }
 
+// resetSubscriptionTrafficTask resets traffic counters at each subscription's reset boundary
+// and flags subscriptions whose usage has reached the quota.
+func resetSubscriptionTrafficTask() {
+	db := database.GetDB()
+	now := time.Now()
+
+	var subs []models.Subscription
+	db.Select("id, user_id, upload_traffic, download_traffic, traffic_reset_mode, traffic_reset_day, last_traffic_reset, created_at").
+		Where("is_active = ? AND traffic_reset_mode <> ?", true, TrafficResetNever).
+		Find(&subs)
+	resetCount := 0
+	for i := range subs {
+		sub := &subs[i]
+		next := SubscriptionNextTrafficReset(sub)
+		if next.IsZero() || now.Before(next) {
+			continue
+		}
+		if err := resetSubscriptionTraffic(db, sub, now); err != nil {
+			utils.SysError("scheduler", fmt.Sprintf("重置订阅流量失败: sub=%d err=%v", sub.ID, err))
+			continue
+		}
+		resetCount++
+	}
+
+	var exhausted []models.Subscription
+	db.Select("id, user_id, upload_traffic, download_traffic, traffic_quota").
+		Where("is_active = ? AND traffic_exhausted = ? AND traffic_quota > 0 AND upload_traffic + download_traffic >= traffic_quota", true, false).
+		Find(&exhausted)
+	for _, sub := range exhausted {
+		if err := db.Model(&models.Subscription{}).Where("id = ?", sub.ID).Update("traffic_exhausted", true).Error; err != nil {
+			continue
+		}
+		utils.CreateSubscriptionLog(sub.ID, sub.UserID, "traffic_exhausted", "system", nil,
+			fmt.Sprintf("流量已用尽: %s / %s", FormatTrafficBytes(sub.UploadTraffic+sub.DownloadTraffic), FormatTrafficBytes(sub.TrafficQuota)), nil, nil)
+	}
+	// 管理员调高配额或清零流量后解除标记
+	db.Model(&models.Subscription{}).
+		Where("traffic_exhausted = ? AND (traffic_quota = 0 OR upload_traffic + download_traffic < traffic_quota)", true).
+		Update("traffic_exhausted", false)
+
+	if resetCount > 0 || len(exhausted) > 0 {
+		log.Printf("[Scheduler] 流量周期: 已重置 %d 个订阅，新增流量用尽 %d 个", resetCount, len(exhausted))
+		utils.SysInfo("scheduler", fmt.Sprintf("流量周期: 已重置 %d 个订阅，新增流量用尽 %d 个", resetCount, len(exhausted)))
+	}
+}
+
+// resumePausedSubscriptionsTask resumes paused subscriptions whose yearly pause allowance has run out.
+func resumePausedSubscriptionsTask() {
+	if n := ResumeDuePausedSubscriptions(database.GetDB()); n > 0 {
+		log.Printf("[Scheduler] 已自动恢复 %d 个暂停订阅", n)
+		utils.SysInfo("scheduler", fmt.Sprintf("已自动恢复 %d 个暂停订阅", n))
+	}
+}
+
 // checkExpiryStatusTask marks subscriptions expiring within 24h.
 func checkExpiryStatusTask() {
 	db := database.GetDB()
@@ -363,6 +419,18 @@ func cleanOldLogsTask() {
 		}
 	}
 
+	// 订阅访问日志量大，单独使用较短的保留期
+	accessRetentionDays := utils.GetIntSetting("subscription_access_log_retention_days", 30)
+	if accessRetentionDays > 0 {
+		accessCutoff := time.Now().AddDate(0, 0, -accessRetentionDays)
+		result := db.Where("created_at < ?", accessCutoff).Delete(&models.SubscriptionAccessLog{})
+		if result.Error != nil {
+			log.Printf("[Scheduler] 清理 subscription_access_logs 失败: %v", result.Error)
+		} else {
+			totalDeleted += result.RowsAffected
+		}
+	}
+
 	// Also clean old file-based logs
 	cleanOldLogFiles(retentionDays)
 
@@ -0,0 +1,366 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+
+	"cboard/v2/internal/models"
+)
+
+// ── sing-box 配置模板 ──
+// 模板是完整的 sing-box JSON（dns / route / rule_set / 分组均由模板决定），节点通过占位符注入：
+//   - outbounds 数组中的字符串 "{nodes}"：在此位置插入全部节点出站（省略时追加到末尾）
+//   - 分组 outbounds 列表中的 "{all}"：展开为节点 tag，可配合分组上的 "filter" / "exclude"
+//     正则筛选节点（这两个字段在输出前会被移除）
+// 筛选后没有任何出站的分组会被删除，其他分组对它的引用也会一并移除；route.rules / route.final 中的引用
+// 改指 direct 出站（没有时为第一个剩余分组），dns.servers 的 detour 则去掉，避免 sing-box 因出站不存在拒绝加载。
+
+const (
+	singBoxNodesPlaceholder = "{nodes}"
+	singBoxAllPlaceholder   = "{all}"
+
+	singBoxTemplateFile = "uploads/config/singbox_temp.json"
+)
+
+// defaultSingBoxTemplate is used when neither an admin template nor singbox_temp.json is available.
+const defaultSingBoxTemplate = `{
+  "dns": {
+    "servers": [
+      {"tag": "dns-direct", "address": "223.5.5.5", "strategy": "ipv4_only"},
+      {"tag": "dns-remote", "address": "8.8.8.8", "strategy": "ipv4_only", "detour": "Proxy"}
+    ],
+    "rules": [
+      {"outbound": "any", "server": "dns-direct"},
+      {"rule_set": "geosite-cn", "server": "dns-direct"}
+    ],
+    "final": "dns-remote"
+  },
+  "outbounds": [
+    {"type": "selector", "tag": "Proxy", "outbounds": ["{all}", "direct"]},
+    "{nodes}",
+    {"type": "direct", "tag": "direct"},
+    {"type": "block", "tag": "block"},
+    {"type": "dns", "tag": "dns-out"}
+  ],
+  "route": {
+    "rule_set": [
+      {"tag": "geosite-ads", "type": "remote", "format": "binary", "url": "https://raw.githubusercontent.com/SagerNet/sing-geosite/rule-set/geosite-category-ads-all.srs", "download_detour": "direct"},
+      {"tag": "geosite-cn", "type": "remote", "format": "binary", "url": "https://raw.githubusercontent.com/SagerNet/sing-geosite/rule-set/geosite-cn.srs", "download_detour": "direct"},
+      {"tag": "geoip-cn", "type": "remote", "format": "binary", "url": "https://raw.githubusercontent.com/SagerNet/sing-geoip/rule-set/geoip-cn.srs", "download_detour": "direct"}
+    ],
+    "rules": [
+      {"protocol": "dns", "outbound": "dns-out"},
+      {"rule_set": ["geosite-ads"], "outbound": "block"},
+      {"rule_set": ["geosite-cn", "geoip-cn"], "outbound": "direct"}
+    ],
+    "final": "Proxy",
+    "auto_detect_interface": true
+  },
+  "cache_file": {"enabled": true}
+}`
+
+// GenerateSingBoxConfigWithTemplate renders nodes with an admin-managed sing-box template;
+// an empty or unusable template falls back to singbox_temp.json and then to the built-in default.
+func GenerateSingBoxConfigWithTemplate(nodes []models.Node, template string) string {
+	outbounds, tags := buildSingBoxOutbounds(nodes)
+	if template != "" {
+		if result, err := renderSingBoxTemplate([]byte(template), outbounds, tags); err == nil {
+			return result
+		}
+	}
+	if data, err := os.ReadFile(singBoxTemplateFile); err == nil {
+		if result, err := renderSingBoxTemplate(data, outbounds, tags); err == nil {
+			return result
+		}
+	}
+	result, err := renderSingBoxTemplate([]byte(defaultSingBoxTemplate), outbounds, tags)
+	if err != nil {
+		return "{}"
+	}
+	return result
+}
+
+// buildSingBoxOutbounds converts nodes to sing-box outbounds with unique tags, skipping info nodes.
+func buildSingBoxOutbounds(nodes []models.Node) (outbounds []interface{}, tags []string) {
+	used := make(map[string]bool)
+	for _, node := range nodes {
+		if node.Config == nil || *node.Config == "" {
+			continue
+		}
+		if isInfoNode(node) {
+			continue
+		}
+		m, err := NodeConfigToClashMap(node.Type, *node.Config, node.Name)
+		if err != nil {
+			continue
+		}
+		tag := formatSafeSingBoxTag(node.Name)
+		for base, i := tag, 1; used[tag]; i++ {
+			tag = fmt.Sprintf("%s_%d", base, i)
+		}
+		ob := clashMapToSingBoxOutbound(tag, m)
+		if ob == nil {
+			continue
+		}
+		used[tag] = true
+		outbounds = append(outbounds, ob)
+		tags = append(tags, tag)
+	}
+	return outbounds, tags
+}
+
+func parseSingBoxTemplate(data []byte) (map[string]interface{}, []interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var root map[string]interface{}
+	if err := dec.Decode(&root); err != nil {
+		return nil, nil, fmt.Errorf("JSON 解析失败: %v", err)
+	}
+	if root == nil {
+		return nil, nil, errors.New("模板根节点必须是 JSON 对象")
+	}
+	outbounds, ok := root["outbounds"].([]interface{})
+	if !ok {
+		return nil, nil, errors.New("模板缺少 outbounds 数组")
+	}
+	return root, outbounds, nil
+}
+
+// validateSingBoxTemplate checks the placeholders renderSingBoxTemplate relies on.
+func validateSingBoxTemplate(content string) error {
+	_, outbounds, err := parseSingBoxTemplate([]byte(content))
+	if err != nil {
+		return err
+	}
+	nodesMarkers, groupsWithAll := 0, 0
+	for idx, item := range outbounds {
+		if s, ok := item.(string); ok {
+			if s != singBoxNodesPlaceholder {
+				return fmt.Errorf("outbounds 第 %d 项: 未知占位符 %q", idx+1, s)
+			}
+			nodesMarkers++
+			continue
+		}
+		ob, ok := item.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("outbounds 第 %d 项不是对象", idx+1)
+		}
+		if t, _ := ob["type"].(string); t == "" {
+			return fmt.Errorf("outbounds 第 %d 项缺少 type", idx+1)
+		}
+		if t, _ := ob["tag"].(string); t == "" {
+			return fmt.Errorf("outbounds 第 %d 项缺少 tag", idx+1)
+		}
+		for _, key := range []string{"filter", "exclude"} {
+			if pattern, ok := ob[key].(string); ok {
+				if _, err := regexp.Compile(pattern); err != nil {
+					return fmt.Errorf("outbounds 第 %d 项 %s 正则无效: %v", idx+1, key, err)
+				}
+			}
+		}
+		if list, ok := ob["outbounds"].([]interface{}); ok {
+			for _, v := range list {
+				if v == singBoxAllPlaceholder {
+					groupsWithAll++
+					break
+				}
+			}
+		}
+	}
+	if nodesMarkers > 1 {
+		return errors.New("outbounds 中 \"{nodes}\" 只能出现一次")
+	}
+	if groupsWithAll == 0 {
+		return errors.New("至少需要一个分组在 outbounds 中包含 \"{all}\" 占位符")
+	}
+	return nil
+}
+
+// renderSingBoxTemplate expands the {nodes} and {all} placeholders of a sing-box template.
+func renderSingBoxTemplate(data []byte, nodeOutbounds []interface{}, tags []string) (string, error) {
+	root, outbounds, err := parseSingBoxTemplate(data)
+	if err != nil {
+		return "", err
+	}
+
+	result := make([]interface{}, 0, len(outbounds)+len(nodeOutbounds))
+	inserted := false
+	removed := make(map[string]bool)
+	for _, item := range outbounds {
+		if item == singBoxNodesPlaceholder {
+			if !inserted {
+				result = append(result, nodeOutbounds...)
+				inserted = true
+			}
+			continue
+		}
+		ob, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if list, ok := ob["outbounds"].([]interface{}); ok {
+			expanded, err := expandSingBoxGroup(ob, list, tags)
+			if err != nil {
+				return "", err
+			}
+			if len(expanded) == 0 {
+				if tag, _ := ob["tag"].(string); tag != "" {
+					removed[tag] = true
+				}
+				continue
+			}
+			ob["outbounds"] = expanded
+		}
+		result = append(result, ob)
+	}
+	if !inserted {
+		result = append(result, nodeOutbounds...)
+	}
+
+	// 删除空分组后，引用它们的分组可能也随之变空，循环直到稳定
+	dropped := make(map[string]bool)
+	for len(removed) > 0 {
+		for tag := range removed {
+			dropped[tag] = true
+		}
+		next := make(map[string]bool)
+		kept := result[:0]
+		for _, item := range result {
+			ob := item.(map[string]interface{})
+			if list, ok := ob["outbounds"].([]interface{}); ok {
+				filtered := make([]interface{}, 0, len(list))
+				for _, v := range list {
+					if s, _ := v.(string); !removed[s] {
+						filtered = append(filtered, v)
+					}
+				}
+				if len(filtered) == 0 {
+					if tag, _ := ob["tag"].(string); tag != "" {
+						next[tag] = true
+					}
+					continue
+				}
+				ob["outbounds"] = filtered
+				if def, _ := ob["default"].(string); removed[def] {
+					delete(ob, "default")
+				}
+			}
+			kept = append(kept, ob)
+		}
+		result = kept
+		removed = next
+	}
+	root["outbounds"] = result
+	redirectSingBoxReferences(root, dropped, singBoxFallbackTag(result))
+
+	b, err := json.MarshalIndent(root, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// singBoxFallbackTag picks the outbound that takes over references to removed groups: the direct outbound,
+// else the first remaining group.
+func singBoxFallbackTag(outbounds []interface{}) string {
+	group := ""
+	for _, item := range outbounds {
+		ob, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		tag, _ := ob["tag"].(string)
+		if ob["type"] == "direct" && tag != "" {
+			return tag
+		}
+		if _, isGroup := ob["outbounds"]; isGroup && group == "" {
+			group = tag
+		}
+	}
+	return group
+}
+
+// redirectSingBoxReferences points route rules and route.final that used a removed group at fallback and drops
+// DNS server detours to it, so sing-box does not reject the config for a missing outbound.
+func redirectSingBoxReferences(root map[string]interface{}, removed map[string]bool, fallback string) {
+	if len(removed) == 0 {
+		return
+	}
+	if route, ok := root["route"].(map[string]interface{}); ok {
+		if final, _ := route["final"].(string); removed[final] {
+			if fallback != "" {
+				route["final"] = fallback
+			} else {
+				delete(route, "final")
+			}
+		}
+		if rules, ok := route["rules"].([]interface{}); ok {
+			kept := rules[:0]
+			for _, item := range rules {
+				rule, ok := item.(map[string]interface{})
+				if outbound, _ := rule["outbound"].(string); ok && removed[outbound] {
+					if fallback == "" {
+						continue
+					}
+					rule["outbound"] = fallback
+				}
+				kept = append(kept, item)
+			}
+			route["rules"] = kept
+		}
+	}
+	if dns, ok := root["dns"].(map[string]interface{}); ok {
+		if servers, ok := dns["servers"].([]interface{}); ok {
+			for _, item := range servers {
+				if server, ok := item.(map[string]interface{}); ok {
+					if detour, _ := server["detour"].(string); removed[detour] {
+						delete(server, "detour")
+					}
+				}
+			}
+		}
+	}
+}
+
+// expandSingBoxGroup replaces {all} in a group's outbounds with the node tags selected by its filter/exclude.
+func expandSingBoxGroup(ob map[string]interface{}, list []interface{}, tags []string) ([]interface{}, error) {
+	var include, exclude *regexp.Regexp
+	if pattern, ok := ob["filter"].(string); ok && pattern != "" {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("filter 正则无效: %v", err)
+		}
+		include = re
+	}
+	if pattern, ok := ob["exclude"].(string); ok && pattern != "" {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("exclude 正则无效: %v", err)
+		}
+		exclude = re
+	}
+	delete(ob, "filter")
+	delete(ob, "exclude")
+
+	expanded := make([]interface{}, 0, len(list)+len(tags))
+	for _, v := range list {
+		if v != singBoxAllPlaceholder {
+			expanded = append(expanded, v)
+			continue
+		}
+		for _, tag := range tags {
+			if include != nil && !include.MatchString(tag) {
+				continue
+			}
+			if exclude != nil && exclude.MatchString(tag) {
+				continue
+			}
+			expanded = append(expanded, tag)
+		}
+	}
+	return expanded, nil
+}
@@ -0,0 +1,109 @@
+package services
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func singBoxOutboundsByTag(t *testing.T, out string) map[string]map[string]interface{} {
+	t.Helper()
+	var cfg struct {
+		Outbounds []map[string]interface{} `json:"outbounds"`
+	}
+	if err := json.Unmarshal([]byte(out), &cfg); err != nil {
+		t.Fatalf("invalid JSON output: %v\n%s", err, out)
+	}
+	byTag := make(map[string]map[string]interface{})
+	for _, ob := range cfg.Outbounds {
+		byTag[ob["tag"].(string)] = ob
+	}
+	return byTag
+}
+
+func TestGenerateSingBoxConfigDefaultTemplate(t *testing.T) {
+	byTag := singBoxOutboundsByTag(t, GenerateSingBoxConfigWithTemplate(templateTestNodes(), ""))
+	proxy := byTag["Proxy"]
+	if proxy == nil {
+		t.Fatalf("missing Proxy selector")
+	}
+	got := proxy["outbounds"].([]interface{})
+	if len(got) != 3 || got[0] != "香港 01" || got[1] != "日本 01" || got[2] != "direct" {
+		t.Fatalf("unexpected Proxy outbounds: %v", got)
+	}
+	if byTag["香港 01"]["type"] != "trojan" || byTag["dns-out"] == nil {
+		t.Fatalf("node or builtin outbounds missing: %v", byTag)
+	}
+}
+
+func TestRenderSingBoxTemplateFilterAndEmptyGroups(t *testing.T) {
+	tpl := `{
+  "outbounds": [
+    {"type": "selector", "tag": "Proxy", "outbounds": ["HK", "US", "{all}"]},
+    {"type": "urltest", "tag": "HK", "outbounds": ["{all}"], "filter": "香港"},
+    {"type": "urltest", "tag": "US", "outbounds": ["{all}"], "filter": "美国"},
+    {"type": "direct", "tag": "direct"}
+  ],
+  "dns": {"servers": [{"tag": "remote", "address": "8.8.8.8", "detour": "US"}]},
+  "route": {"rules": [{"domain_suffix": ["netflix.com"], "outbound": "US"}], "final": "US"}
+}`
+	if err := ValidateConfigTemplate(ConfigTemplateFormatSingBox, tpl); err != nil {
+		t.Fatalf("valid template rejected: %v", err)
+	}
+	out, err := RenderConfigTemplate(ConfigTemplateFormatSingBox, tpl, templateTestNodes(), "test")
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	byTag := singBoxOutboundsByTag(t, out)
+	if byTag["US"] != nil {
+		t.Fatalf("empty group US should be removed")
+	}
+	hk := byTag["HK"]
+	if _, ok := hk["filter"]; ok {
+		t.Fatalf("filter key should be stripped from output")
+	}
+	if list := hk["outbounds"].([]interface{}); len(list) != 1 || list[0] != "香港 01" {
+		t.Fatalf("unexpected HK outbounds: %v", list)
+	}
+	if list := byTag["Proxy"]["outbounds"].([]interface{}); len(list) != 3 || list[0] != "HK" {
+		t.Fatalf("unexpected Proxy outbounds: %v", list)
+	}
+	if byTag["日本 01"] == nil {
+		t.Fatalf("node outbounds should be appended when {nodes} is absent")
+	}
+
+	// 指向被删除分组的路由与 DNS 引用需要改写
+	var cfg struct {
+		DNS struct {
+			Servers []map[string]interface{} `json:"servers"`
+		} `json:"dns"`
+		Route struct {
+			Rules []map[string]interface{} `json:"rules"`
+			Final string                   `json:"final"`
+		} `json:"route"`
+	}
+	if err := json.Unmarshal([]byte(out), &cfg); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if cfg.Route.Final != "direct" || cfg.Route.Rules[0]["outbound"] != "direct" {
+		t.Fatalf("route still references the removed group: %+v", cfg.Route)
+	}
+	if _, ok := cfg.DNS.Servers[0]["detour"]; ok {
+		t.Fatalf("dns server still detours through the removed group: %v", cfg.DNS.Servers[0])
+	}
+}
+
+func TestValidateSingBoxTemplate(t *testing.T) {
+	cases := map[string]string{
+		"not json":     `{"outbounds": [`,
+		"no outbounds": `{"route": {}}`,
+		"no all":       `{"outbounds": [{"type": "selector", "tag": "Proxy", "outbounds": ["direct"]}]}`,
+		"bad marker":   `{"outbounds": ["{servers}", {"type": "selector", "tag": "Proxy", "outbounds": ["{all}"]}]}`,
+		"bad regex":    `{"outbounds": [{"type": "selector", "tag": "Proxy", "outbounds": ["{all}"], "filter": "("}]}`,
+		"missing tag":  `{"outbounds": [{"type": "selector", "outbounds": ["{all}"]}]}`,
+	}
+	for name, content := range cases {
+		if err := ValidateConfigTemplate(ConfigTemplateFormatSingBox, content); err == nil {
+			t.Fatalf("%s: expected validation error", name)
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"cboard/v2/internal/database"
+	"cboard/v2/internal/models"
+	"cboard/v2/internal/utils"
+
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+// ── 订阅泄露检测（防合租/倒卖） ──
+// 统计检测窗口内拉取同一订阅的独立 IP 数，超过阈值后按 sub_leak_action 执行处理动作。
+// sub_leak_action 支持逗号分隔组合多个动作，例如 "notify_admin,block"；无论配置如何都会写入安全日志。
+
+const (
+	LeakActionLog         = "log"          // 仅记录安全日志
+	LeakActionNotifyAdmin = "notify_admin" // 通知管理员
+	LeakActionEmailUser   = "email_user"   // 邮件提醒用户
+	LeakActionResetURL    = "reset_url"    // 自动重置订阅地址
+	LeakActionBlock       = "block"        // 临时封禁订阅链接
+)
+
+// LeakPolicy is the admin-configured leak-detection policy.
+type LeakPolicy struct {
+	Threshold     int             // 窗口内独立 IP 数超过该值即触发，0 表示关闭检测
+	Window        time.Duration   // 统计窗口
+	Actions       map[string]bool // 触发后执行的动作
+	BlockDuration time.Duration   // block 动作的封禁时长
+}
+
+// GetLeakPolicy loads the policy from system settings.
+func GetLeakPolicy() LeakPolicy {
+	windowHours := utils.GetIntSetting("sub_leak_window_hours", 24)
+	if windowHours < 1 {
+		windowHours = 24
+	}
+	blockHours := utils.GetIntSetting("sub_leak_block_hours", 24)
+	if blockHours < 1 {
+		blockHours = 24
+	}
+	return LeakPolicy{
+		Threshold:     utils.GetIntSetting("sub_leak_ip_threshold", 15),
+		Window:        time.Duration(windowHours) * time.Hour,
+		Actions:       ParseLeakActions(utils.GetSetting("sub_leak_action")),
+		BlockDuration: time.Duration(blockHours) * time.Hour,
+	}
+}
+
+// ParseLeakActions parses a comma-separated action list, ignoring unknown values; empty means log only.
+func ParseLeakActions(raw string) map[string]bool {
+	actions := map[string]bool{LeakActionLog: true}
+	for _, a := range strings.Split(raw, ",") {
+		switch a = strings.TrimSpace(a); a {
+		case LeakActionNotifyAdmin, LeakActionEmailUser, LeakActionResetURL, LeakActionBlock:
+			actions[a] = true
+		}
+	}
+	return actions
+}
+
+// IsLeakBlocked reports whether the subscription link is temporarily blocked by leak detection.
+func IsLeakBlocked(sub *models.Subscription) bool {
+	return sub.LeakBlockedUntil != nil && time.Now().Before(*sub.LeakBlockedUntil)
+}
+
+func subscriptionIPKey(subID uint) string {
+	return fmt.Sprintf("sub_ips:%d", subID)
+}
+
+// TrackSubscriptionIP records a fetch IP and returns the number of distinct IPs within the window.
+// 优先使用 Redis 有序集合做滑动窗口；未启用 Redis 时回退到订阅访问日志统计。
+func TrackSubscriptionIP(db *gorm.DB, subID uint, ip string, window time.Duration) int64 {
+	now := time.Now()
+	if r := database.GetRedis(); r != nil {
+		ctx := context.Background()
+		key := subscriptionIPKey(subID)
+		pipe := r.TxPipeline()
+		pipe.ZAdd(ctx, key, redis.Z{Score: float64(now.Unix()), Member: ip})
+		pipe.ZRemRangeByScore(ctx, key, "-inf", strconv.FormatInt(now.Add(-window).Unix(), 10))
+		pipe.Expire(ctx, key, window)
+		card := pipe.ZCard(ctx, key)
+		if _, err := pipe.Exec(ctx); err == nil {
+			return card.Val()
+		}
+	}
+	// 本次访问日志为异步写入，这里单独计入当前 IP
+	var count int64
+	db.Model(&models.SubscriptionAccessLog{}).
+		Where("subscription_id = ? AND created_at > ? AND ip_address <> ?", subID, now.Add(-window), ip).
+		Distinct("ip_address").Count(&count)
+	return count + 1
+}
+
+// ClearSubscriptionIPs drops the tracked IP window (after the link is reset or the flag is cleared).
+func ClearSubscriptionIPs(subID uint) {
+	if r := database.GetRedis(); r != nil {
+		r.Del(context.Background(), subscriptionIPKey(subID))
+	}
+}
+
+// HandleSubscriptionLeak applies the policy to a subscription that exceeded the IP threshold.
+// 同一检测窗口内只处理一次；返回 true 表示本次请求应被拒绝（已封禁或地址已重置）。
+func HandleSubscriptionLeak(db *gorm.DB, sub *models.Subscription, ipCount int64, policy LeakPolicy) bool {
+	now := time.Now()
+	if sub.LeakFlaggedAt != nil && now.Sub(*sub.LeakFlaggedAt) < policy.Window {
+		return IsLeakBlocked(sub)
+	}
+
+	updates := map[string]interface{}{"leak_flagged_at": now, "leak_ip_count": ipCount}
+	if policy.Actions[LeakActionBlock] {
+		until := now.Add(policy.BlockDuration)
+		updates["leak_blocked_until"] = until
+		sub.LeakBlockedUntil = &until
+	}
+	if err := db.Model(&models.Subscription{}).Where("id = ?", sub.ID).Updates(updates).Error; err != nil {
+		utils.SysError("security_alert", fmt.Sprintf("记录订阅泄露标记失败: sub=%d err=%v", sub.ID, err))
+	}
+	sub.LeakFlaggedAt = &now
+	sub.LeakIPCount = int(ipCount)
+
+	actionNames := make([]string, 0, len(policy.Actions))
+	for _, a := range []string{LeakActionNotifyAdmin, LeakActionEmailUser, LeakActionResetURL, LeakActionBlock} {
+		if policy.Actions[a] {
+			actionNames = append(actionNames, a)
+		}
+	}
+	if len(actionNames) == 0 {
+		actionNames = append(actionNames, LeakActionLog)
+	}
+	window := fmt.Sprintf("%d 小时", int(policy.Window.Hours()))
+	desc := fmt.Sprintf("订阅疑似泄露：%s内被 %d 个不同 IP 拉取（阈值 %d），处理动作: %s",
+		window, ipCount, policy.Threshold, strings.Join(actionNames, ","))
+	utils.SysError("security_alert", fmt.Sprintf("【防滥用警报】订阅 ID: %d %s", sub.ID, desc))
+	utils.CreateSubscriptionLog(sub.ID, sub.UserID, "leak_detected", "system", nil, desc, nil, nil)
+
+	var user models.User
+	db.First(&user, sub.UserID)
+	if policy.Actions[LeakActionNotifyAdmin] {
+		go NotifyAdmin("subscription_leak", map[string]string{
+			"username": user.Username,
+			"sub_id":   strconv.FormatUint(uint64(sub.ID), 10),
+			"ip_count": strconv.FormatInt(ipCount, 10),
+			"window":   window,
+			"action":   strings.Join(actionNames, ","),
+		})
+	}
+	if policy.Actions[LeakActionEmailUser] {
+		go NotifyUser(sub.UserID, "subscription_leak", map[string]string{
+			"username": user.Username,
+			"ip_count": strconv.FormatInt(ipCount, 10),
+			"window":   window,
+		})
+	}
+
+	rejected := policy.Actions[LeakActionBlock]
+	if policy.Actions[LeakActionResetURL] {
+		if err := resetLeakedSubscription(db, sub); err != nil {
+			utils.SysError("security_alert", fmt.Sprintf("泄露订阅自动重置失败: sub=%d err=%v", sub.ID, err))
+		} else {
+			go NotifyUser(sub.UserID, "subscription_reset", map[string]string{"reset_by": "系统（检测到订阅疑似泄露）"})
+			rejected = true
+		}
+	}
+	return rejected
+}
+
+// resetLeakedSubscription rotates the subscription URL and clears its devices, like a manual reset.
+func resetLeakedSubscription(db *gorm.DB, sub *models.Subscription) error {
+	oldURL := sub.SubscriptionURL
+	newURL := utils.GenerateHexToken()
+	resetBy := "system"
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("subscription_id = ?", sub.ID).Delete(&models.Device{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.Subscription{}).Where("id = ?", sub.ID).Updates(map[string]interface{}{
+			"subscription_url": newURL, "current_devices": 0, "leak_blocked_until": nil,
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.SubscriptionReset{
+			UserID: sub.UserID, SubscriptionID: sub.ID, ResetType: "leak_reset",
+			Reason: "订阅疑似泄露，系统自动重置", OldSubscriptionURL: &oldURL,
+			NewSubscriptionURL: &newURL, DeviceCountBefore: sub.CurrentDevices,
+			DeviceCountAfter: 0, ResetBy: &resetBy,
+		}).Error
+	})
+	if err != nil {
+		return err
+	}
+	sub.SubscriptionURL = newURL
+	ClearSubscriptionIPs(sub.ID)
+	utils.CreateSubscriptionLog(sub.ID, sub.UserID, "reset", "system", nil, "订阅疑似泄露，系统自动重置订阅地址", nil, nil)
+	return nil
+}
+
+// ClearSubscriptionLeakFlag removes the flag and any block so the link works again.
+func ClearSubscriptionLeakFlag(db *gorm.DB, subID uint) error {
+	if err := db.Model(&models.Subscription{}).Where("id = ?", subID).Updates(map[string]interface{}{
+		"leak_flagged_at": nil, "leak_ip_count": 0, "leak_blocked_until": nil,
+	}).Error; err != nil {
+		return err
+	}
+	ClearSubscriptionIPs(subID)
+	return nil
+}
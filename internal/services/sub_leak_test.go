@@ -0,0 +1,16 @@
+package services
+
+import "testing"
+
+func TestParseLeakActions(t *testing.T) {
+	actions := ParseLeakActions(" notify_admin, block ,unknown")
+	if !actions[LeakActionLog] || !actions[LeakActionNotifyAdmin] || !actions[LeakActionBlock] {
+		t.Fatalf("unexpected actions %v", actions)
+	}
+	if actions["unknown"] || actions[LeakActionResetURL] {
+		t.Fatalf("unexpected actions %v", actions)
+	}
+	if got := ParseLeakActions(""); len(got) != 1 || !got[LeakActionLog] {
+		t.Fatalf("empty policy should only log, got %v", got)
+	}
+}
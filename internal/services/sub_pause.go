@@ -0,0 +1,218 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"cboard/v2/internal/models"
+	"cboard/v2/internal/utils"
+
+	"gorm.io/gorm"
+)
+
+// ── 订阅暂停（冻结） ──
+// 用户出差或长期不用时可暂停订阅：暂停期间订阅链接返回提示节点，到期时间停止流逝，
+// 恢复时到期时间顺延实际暂停时长。次数与时长按最近一年（滚动 365 天）统计，
+// 额度由 sub_pause_max_count / sub_pause_max_days 配置，次数为 0 表示关闭该功能。
+
+const pauseAllowanceWindow = 365 * 24 * time.Hour
+
+// PausePolicy is the admin-configured yearly pause allowance.
+type PausePolicy struct {
+	MaxCount    int           // 每年最多暂停次数，0 表示不允许暂停
+	MaxDuration time.Duration // 每年累计最长暂停时长
+}
+
+// GetPausePolicy loads the policy from system settings.
+func GetPausePolicy() PausePolicy {
+	days := utils.GetIntSetting("sub_pause_max_days", 30)
+	if days < 0 {
+		days = 0
+	}
+	return PausePolicy{
+		MaxCount:    utils.GetIntSetting("sub_pause_max_count", 2),
+		MaxDuration: time.Duration(days) * 24 * time.Hour,
+	}
+}
+
+// PauseUsage summarizes the pauses of a subscription within the allowance window.
+type PauseUsage struct {
+	Count int
+	Used  time.Duration
+}
+
+// Remaining returns how long the subscription may still be paused under the policy.
+func (u PauseUsage) Remaining(policy PausePolicy) time.Duration {
+	if policy.MaxCount <= 0 || u.Count >= policy.MaxCount || u.Used >= policy.MaxDuration {
+		return 0
+	}
+	return policy.MaxDuration - u.Used
+}
+
+// GetPauseUsage counts the pauses started within the last year and their total duration.
+func GetPauseUsage(db *gorm.DB, subID uint) PauseUsage {
+	var pauses []models.SubscriptionPause
+	db.Where("subscription_id = ? AND paused_at > ?", subID, time.Now().Add(-pauseAllowanceWindow)).Find(&pauses)
+	usage := PauseUsage{Count: len(pauses)}
+	for _, p := range pauses {
+		usage.Used += time.Duration(p.PausedSeconds) * time.Second
+	}
+	return usage
+}
+
+// IsSubscriptionPaused reports whether the subscription is currently frozen.
+func IsSubscriptionPaused(sub *models.Subscription) bool {
+	return sub.Status == "paused" && sub.PausedAt != nil
+}
+
+// PreservePauseState keeps a paused subscription paused when it is renewed or upgraded.
+func PreservePauseState(sub *models.Subscription, updates map[string]interface{}) map[string]interface{} {
+	if IsSubscriptionPaused(sub) {
+		delete(updates, "is_active")
+		delete(updates, "status")
+	}
+	return updates
+}
+
+// PauseSubscription freezes an active subscription; it is resumed automatically once the yearly allowance runs out.
+func PauseSubscription(db *gorm.DB, sub *models.Subscription, actionBy string, actionByUserID *uint) error {
+	if IsSubscriptionPaused(sub) {
+		return errors.New("订阅已处于暂停状态")
+	}
+	now := time.Now()
+	if !sub.IsActive || (sub.Status != "active" && sub.Status != "expiring") || !sub.ExpireTime.After(now) {
+		return errors.New("只有生效中的订阅可以暂停")
+	}
+	policy := GetPausePolicy()
+	if policy.MaxCount <= 0 {
+		return errors.New("系统未开启订阅暂停功能")
+	}
+	usage := GetPauseUsage(db, sub.ID)
+	if usage.Count >= policy.MaxCount {
+		return fmt.Errorf("每年最多可暂停 %d 次，本年度次数已用完", policy.MaxCount)
+	}
+	remaining := usage.Remaining(policy)
+	if remaining <= 0 {
+		return fmt.Errorf("每年最多可暂停 %d 天，本年度时长已用完", int(policy.MaxDuration.Hours()/24))
+	}
+
+	resumeAt := now.Add(remaining)
+	err := db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.Subscription{}).
+			Where("id = ? AND status IN ?", sub.ID, []string{"active", "expiring"}).
+			Updates(map[string]interface{}{"status": "paused", "is_active": false, "paused_at": now, "pause_resume_at": resumeAt})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("订阅状态已变化，请刷新后重试")
+		}
+		return tx.Create(&models.SubscriptionPause{SubscriptionID: sub.ID, UserID: sub.UserID, PausedAt: now}).Error
+	})
+	if err != nil {
+		return err
+	}
+	before := map[string]interface{}{"status": sub.Status, "expire_time": sub.ExpireTime}
+	sub.Status, sub.IsActive, sub.PausedAt, sub.PauseResumeAt = "paused", false, &now, &resumeAt
+	utils.CreateSubscriptionLog(sub.ID, sub.UserID, "pause", actionBy, actionByUserID,
+		fmt.Sprintf("暂停订阅，最迟 %s 自动恢复", resumeAt.Format("2006-01-02 15:04")),
+		before, map[string]interface{}{"status": "paused", "pause_resume_at": resumeAt})
+	return nil
+}
+
+// ResumeSubscription unfreezes a paused subscription and pushes its expiry forward by the paused duration.
+func ResumeSubscription(db *gorm.DB, sub *models.Subscription, actionBy string, actionByUserID *uint) error {
+	if !IsSubscriptionPaused(sub) {
+		return errors.New("订阅未处于暂停状态")
+	}
+	now := time.Now()
+	paused := pausedDuration(sub, now)
+	newExpire := sub.ExpireTime.Add(paused)
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.Subscription{}).Where("id = ? AND status = ?", sub.ID, "paused").Updates(map[string]interface{}{
+			"status": "active", "is_active": true, "expire_time": newExpire, "paused_at": nil, "pause_resume_at": nil,
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("订阅状态已变化，请刷新后重试")
+		}
+		return closeSubscriptionPause(tx, sub.ID, now, paused, actionBy)
+	})
+	if err != nil {
+		return err
+	}
+	before := map[string]interface{}{"status": "paused", "expire_time": sub.ExpireTime}
+	sub.Status, sub.IsActive, sub.ExpireTime, sub.PausedAt, sub.PauseResumeAt = "active", true, newExpire, nil, nil
+	utils.CreateSubscriptionLog(sub.ID, sub.UserID, "resume", actionBy, actionByUserID,
+		fmt.Sprintf("恢复订阅，暂停 %s，到期时间顺延至 %s", formatPauseDuration(paused), newExpire.Format("2006-01-02 15:04")),
+		before, map[string]interface{}{"status": "active", "expire_time": newExpire})
+	return nil
+}
+
+// pausedDuration is the time a paused subscription has been frozen, capped at its allowance.
+func pausedDuration(sub *models.Subscription, now time.Time) time.Duration {
+	paused := now.Sub(*sub.PausedAt)
+	// 定时任务可能晚于自动恢复时间执行，顺延时长不超过本次可用额度
+	if sub.PauseResumeAt != nil && now.After(*sub.PauseResumeAt) {
+		paused = sub.PauseResumeAt.Sub(*sub.PausedAt)
+	}
+	if paused < 0 {
+		paused = 0
+	}
+	return paused
+}
+
+// closeSubscriptionPause records the end of the open pause row of a subscription.
+func closeSubscriptionPause(tx *gorm.DB, subID uint, now time.Time, paused time.Duration, resumedBy string) error {
+	return tx.Model(&models.SubscriptionPause{}).
+		Where("subscription_id = ? AND resumed_at IS NULL", subID).
+		Updates(map[string]interface{}{"resumed_at": now, "paused_seconds": int64(paused.Seconds()), "resumed_by": resumedBy}).Error
+}
+
+// ExtendSubscriptionDays adds days to a subscription's expiry (from now when it has already lapsed) and
+// activates it; a paused subscription stays paused and keeps its frozen expiry as the base, since resuming
+// credits the paused time on top.
+func ExtendSubscriptionDays(tx *gorm.DB, sub *models.Subscription, days int) (time.Time, error) {
+	newExpire := sub.ExpireTime
+	if !IsSubscriptionPaused(sub) && newExpire.Before(time.Now()) {
+		newExpire = time.Now()
+	}
+	newExpire = newExpire.AddDate(0, 0, days)
+	if err := tx.Model(sub).Updates(PreservePauseState(sub, map[string]interface{}{
+		"expire_time": newExpire, "is_active": true, "status": "active",
+	})).Error; err != nil {
+		return newExpire, err
+	}
+	return newExpire, nil
+}
+
+// ResumeDuePausedSubscriptions resumes subscriptions whose pause allowance has run out.
+func ResumeDuePausedSubscriptions(db *gorm.DB) int {
+	var subs []models.Subscription
+	db.Where("status = ? AND pause_resume_at <= ?", "paused", time.Now()).Find(&subs)
+	resumed := 0
+	for i := range subs {
+		if err := ResumeSubscription(db, &subs[i], "system", nil); err != nil {
+			utils.SysError("scheduler", fmt.Sprintf("自动恢复暂停订阅失败: sub=%d err=%v", subs[i].ID, err))
+			continue
+		}
+		go NotifyUser(subs[i].UserID, "subscription_resumed", map[string]string{
+			"expire_time": subs[i].ExpireTime.Format("2006-01-02 15:04"),
+		})
+		resumed++
+	}
+	return resumed
+}
+
+func formatPauseDuration(d time.Duration) string {
+	days := int(d.Hours()) / 24
+	hours := int(d.Hours()) % 24
+	if days > 0 {
+		return fmt.Sprintf("%d 天 %d 小时", days, hours)
+	}
+	return fmt.Sprintf("%d 小时", hours)
+}
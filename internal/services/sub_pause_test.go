@@ -0,0 +1,123 @@
+package services
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"cboard/v2/internal/database"
+	"cboard/v2/internal/models"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestPauseUsageRemaining(t *testing.T) {
+	policy := PausePolicy{MaxCount: 2, MaxDuration: 30 * 24 * time.Hour}
+	if got := (PauseUsage{}).Remaining(policy); got != policy.MaxDuration {
+		t.Fatalf("expected full allowance, got %v", got)
+	}
+	if got := (PauseUsage{Count: 1, Used: 10 * 24 * time.Hour}).Remaining(policy); got != 20*24*time.Hour {
+		t.Fatalf("expected 20 days left, got %v", got)
+	}
+	if got := (PauseUsage{Count: 2, Used: time.Hour}).Remaining(policy); got != 0 {
+		t.Fatalf("count exhausted should leave nothing, got %v", got)
+	}
+	if got := (PauseUsage{Count: 1, Used: 31 * 24 * time.Hour}).Remaining(policy); got != 0 {
+		t.Fatalf("duration exhausted should leave nothing, got %v", got)
+	}
+	if got := (PauseUsage{}).Remaining(PausePolicy{MaxDuration: time.Hour}); got != 0 {
+		t.Fatalf("disabled policy should leave nothing, got %v", got)
+	}
+}
+
+func setupPauseTestDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	if err := db.AutoMigrate(&models.Subscription{}, &models.SubscriptionPause{}, &models.SubscriptionLog{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	// 订阅日志通过全局连接异步写入；内存库每个连接都是独立的库，只保留一个连接
+	if sqlDB, err := db.DB(); err == nil {
+		sqlDB.SetMaxOpenConns(1)
+	}
+	previous := database.DB
+	database.DB = db
+	t.Cleanup(func() { database.DB = previous })
+	return db
+}
+
+func createPausedSubscription(t *testing.T, db *gorm.DB, userID uint, pausedFor time.Duration) (models.Subscription, time.Time) {
+	now := time.Now()
+	pausedAt := now.Add(-pausedFor)
+	resumeAt := now.Add(10 * 24 * time.Hour)
+	expire := now.Add(5 * 24 * time.Hour).Truncate(time.Second)
+	sub := models.Subscription{
+		UserID: userID, SubscriptionURL: fmt.Sprintf("pause-test-%d", userID), DeviceLimit: 3,
+		IsActive: false, Status: "paused", ExpireTime: expire, PausedAt: &pausedAt, PauseResumeAt: &resumeAt,
+	}
+	if err := db.Create(&sub).Error; err != nil {
+		t.Fatalf("create subscription: %v", err)
+	}
+	// is_active 带默认值，零值需单独写入
+	db.Model(&sub).Update("is_active", false)
+	if err := db.Create(&models.SubscriptionPause{SubscriptionID: sub.ID, UserID: userID, PausedAt: pausedAt}).Error; err != nil {
+		t.Fatalf("create pause: %v", err)
+	}
+	return sub, expire
+}
+
+func TestExtendPausedSubscriptionKeepsPause(t *testing.T) {
+	db := setupPauseTestDB(t)
+	sub, expire := createPausedSubscription(t, db, 1, 2*24*time.Hour)
+
+	// 兑换码、盲盒与管理员延期都走 ExtendSubscriptionDays
+	if _, err := ExtendSubscriptionDays(db, &sub, 30); err != nil {
+		t.Fatalf("extend: %v", err)
+	}
+	var got models.Subscription
+	db.First(&got, sub.ID)
+	if got.Status != "paused" || got.IsActive || got.PausedAt == nil || got.PauseResumeAt == nil {
+		t.Fatalf("extending must keep the pause, got status=%s active=%v paused_at=%v", got.Status, got.IsActive, got.PausedAt)
+	}
+	if want := expire.AddDate(0, 0, 30); !got.ExpireTime.Equal(want) {
+		t.Fatalf("expire = %v, want %v", got.ExpireTime, want)
+	}
+
+	// 恢复时再顺延暂停时长，并关闭暂停记录
+	if err := ResumeSubscription(db, &got, "user", nil); err != nil {
+		t.Fatalf("resume: %v", err)
+	}
+	var pause models.SubscriptionPause
+	db.Where("subscription_id = ?", sub.ID).First(&pause)
+	if pause.ResumedAt == nil || pause.PausedSeconds < int64((2*24*time.Hour).Seconds()) {
+		t.Fatalf("pause row not closed: %+v", pause)
+	}
+	if got.ExpireTime.Before(expire.AddDate(0, 0, 32)) {
+		t.Fatalf("paused time not credited: expire = %v", got.ExpireTime)
+	}
+}
+
+func TestDisablePausedSubscriptionClosesPause(t *testing.T) {
+	db := setupPauseTestDB(t)
+	sub, expire := createPausedSubscription(t, db, 2, 24*time.Hour)
+
+	if err := DisableUserSubscriptions(db, []uint{2}); err != nil {
+		t.Fatalf("disable: %v", err)
+	}
+	var got models.Subscription
+	db.First(&got, sub.ID)
+	if got.Status != "disabled" || got.IsActive || got.PausedAt != nil || got.PauseResumeAt != nil {
+		t.Fatalf("unexpected subscription after disable: status=%s paused_at=%v", got.Status, got.PausedAt)
+	}
+	if got.ExpireTime.Before(expire.Add(24 * time.Hour)) {
+		t.Fatalf("paused time not credited: expire = %v", got.ExpireTime)
+	}
+	var open int64
+	db.Model(&models.SubscriptionPause{}).Where("subscription_id = ? AND resumed_at IS NULL", sub.ID).Count(&open)
+	if open != 0 {
+		t.Fatalf("pause row left open")
+	}
+}
@@ -0,0 +1,172 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"cboard/v2/internal/models"
+)
+
+// ── 订阅筛选方案 ──
+// 用户保存的方案按 地区 → 协议 → 名称正则 的顺序筛选节点，再按 SortBy 排序；
+// ProtocolOrder 中的协议排在最前（按列表顺序），其余节点保持 SortBy 的顺序。
+
+// SubscriptionProfileSorts lists the accepted SortBy values ("" keeps the admin order).
+var SubscriptionProfileSorts = []string{"", "name", "latency", "region"}
+
+// SubscriptionProfileLists is the decoded form of the JSON list columns of a profile.
+type SubscriptionProfileLists struct {
+	Regions       []string `json:"regions"`
+	Protocols     []string `json:"protocols"`
+	ProtocolOrder []string `json:"protocol_order"`
+}
+
+// DecodeSubscriptionProfileLists decodes the JSON list columns; malformed values are treated as empty.
+func DecodeSubscriptionProfileLists(p *models.SubscriptionProfile) SubscriptionProfileLists {
+	var lists SubscriptionProfileLists
+	for _, col := range []struct {
+		raw string
+		dst *[]string
+	}{{p.Regions, &lists.Regions}, {p.Protocols, &lists.Protocols}, {p.ProtocolOrder, &lists.ProtocolOrder}} {
+		if col.raw != "" {
+			json.Unmarshal([]byte(col.raw), col.dst)
+		}
+		if *col.dst == nil {
+			*col.dst = []string{}
+		}
+	}
+	return lists
+}
+
+// EncodeSubscriptionProfileList encodes a list column, trimming blanks and duplicates; an empty list is stored as "".
+func EncodeSubscriptionProfileList(items []string) string {
+	var cleaned []string
+	seen := make(map[string]bool)
+	for _, item := range items {
+		item = strings.TrimSpace(item)
+		if item == "" || seen[item] {
+			continue
+		}
+		seen[item] = true
+		cleaned = append(cleaned, item)
+	}
+	if len(cleaned) == 0 {
+		return ""
+	}
+	b, _ := json.Marshal(cleaned)
+	return string(b)
+}
+
+// ValidateSubscriptionProfile checks the name, regular expressions and sort option of a profile.
+func ValidateSubscriptionProfile(p *models.SubscriptionProfile) error {
+	name := strings.TrimSpace(p.Name)
+	if name == "" {
+		return errors.New("方案名称不能为空")
+	}
+	if len([]rune(name)) > 50 {
+		return errors.New("方案名称不能超过 50 个字符")
+	}
+	for label, pattern := range map[string]string{"包含": p.Include, "排除": p.Exclude} {
+		if len(pattern) > 255 {
+			return fmt.Errorf("%s正则过长", label)
+		}
+		if _, err := regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("%s正则无效: %v", label, err)
+		}
+	}
+	for _, s := range SubscriptionProfileSorts {
+		if p.SortBy == s {
+			return nil
+		}
+	}
+	return fmt.Errorf("不支持的排序方式: %s", p.SortBy)
+}
+
+// NodeRegion prefers the stored region and falls back to detecting it from the node name.
+func NodeRegion(node models.Node) string {
+	if node.Region != "" {
+		return node.Region
+	}
+	return DetectRegion(node.Name)
+}
+
+// ApplySubscriptionProfile filters and orders real (non-info) nodes according to a profile.
+func ApplySubscriptionProfile(nodes []models.Node, p *models.SubscriptionProfile) []models.Node {
+	if p == nil {
+		return nodes
+	}
+	lists := DecodeSubscriptionProfileLists(p)
+	regions := make(map[string]int, len(lists.Regions))
+	for i, r := range lists.Regions {
+		regions[r] = i
+	}
+	protocols := make(map[string]bool, len(lists.Protocols))
+	for _, proto := range lists.Protocols {
+		protocols[strings.ToLower(proto)] = true
+	}
+	// 正则在保存时已校验，这里编译失败时忽略该条件
+	include, _ := regexp.Compile(p.Include)
+	exclude, _ := regexp.Compile(p.Exclude)
+
+	result := make([]models.Node, 0, len(nodes))
+	for _, node := range nodes {
+		if len(regions) > 0 {
+			if _, ok := regions[NodeRegion(node)]; !ok {
+				continue
+			}
+		}
+		if len(protocols) > 0 && !protocols[strings.ToLower(node.Type)] {
+			continue
+		}
+		if p.Include != "" && include != nil && !include.MatchString(node.Name) {
+			continue
+		}
+		if p.Exclude != "" && exclude != nil && exclude.MatchString(node.Name) {
+			continue
+		}
+		result = append(result, node)
+	}
+
+	protoRank := make(map[string]int, len(lists.ProtocolOrder))
+	for i, proto := range lists.ProtocolOrder {
+		protoRank[strings.ToLower(proto)] = i
+	}
+	rank := func(n models.Node) int {
+		if r, ok := protoRank[strings.ToLower(n.Type)]; ok {
+			return r
+		}
+		return len(protoRank)
+	}
+	less := func(a, b models.Node) bool {
+		switch p.SortBy {
+		case "name":
+			return a.Name < b.Name
+		case "latency":
+			// 未测速（0）的节点排在最后
+			if (a.Latency == 0) != (b.Latency == 0) {
+				return b.Latency == 0
+			}
+			return a.Latency < b.Latency
+		case "region":
+			ra, rb := NodeRegion(a), NodeRegion(b)
+			if ia, ok := regions[ra]; ok {
+				if ib, ok := regions[rb]; ok {
+					return ia < ib
+				}
+			}
+			return ra < rb
+		}
+		return false
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		if ri, rj := rank(result[i]), rank(result[j]); ri != rj {
+			return ri < rj
+		}
+		return less(result[i], result[j])
+	})
+	return result
+}
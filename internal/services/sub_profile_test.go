@@ -0,0 +1,74 @@
+package services
+
+import (
+	"testing"
+
+	"cboard/v2/internal/models"
+)
+
+func subProfileTestNodes() []models.Node {
+	return []models.Node{
+		{Name: "香港 01", Type: "vmess", Region: "香港", Latency: 80},
+		{Name: "日本 01", Type: "hysteria2", Region: "日本", Latency: 0},
+		{Name: "香港 02 IPLC", Type: "hysteria2", Region: "香港", Latency: 30},
+		{Name: "美国 01", Type: "trojan", Region: "美国", Latency: 150},
+	}
+}
+
+func subProfileNodeNames(nodes []models.Node) []string {
+	names := make([]string, len(nodes))
+	for i, n := range nodes {
+		names[i] = n.Name
+	}
+	return names
+}
+
+func TestApplySubscriptionProfileFilters(t *testing.T) {
+	p := &models.SubscriptionProfile{
+		Regions:   EncodeSubscriptionProfileList([]string{"香港", "日本"}),
+		Protocols: EncodeSubscriptionProfileList([]string{"Hysteria2", "vmess"}),
+		Exclude:   "IPLC",
+	}
+	got := subProfileNodeNames(ApplySubscriptionProfile(subProfileTestNodes(), p))
+	want := []string{"香港 01", "日本 01"}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Fatalf("filtered nodes = %v, want %v", got, want)
+	}
+
+	p = &models.SubscriptionProfile{Include: "^香港"}
+	if got := ApplySubscriptionProfile(subProfileTestNodes(), p); len(got) != 2 {
+		t.Fatalf("include filter kept %d nodes, want 2", len(got))
+	}
+}
+
+func TestApplySubscriptionProfileOrder(t *testing.T) {
+	p := &models.SubscriptionProfile{
+		ProtocolOrder: EncodeSubscriptionProfileList([]string{"hysteria2"}),
+		SortBy:        "latency",
+	}
+	got := subProfileNodeNames(ApplySubscriptionProfile(subProfileTestNodes(), p))
+	// hysteria2 优先（其中未测速的排后），其余按延迟升序
+	want := []string{"香港 02 IPLC", "日本 01", "香港 01", "美国 01"}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("ordered nodes = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestValidateSubscriptionProfile(t *testing.T) {
+	cases := []struct {
+		p  models.SubscriptionProfile
+		ok bool
+	}{
+		{models.SubscriptionProfile{Name: "流媒体", Include: "(?i)netflix", SortBy: "region"}, true},
+		{models.SubscriptionProfile{Name: "  "}, false},
+		{models.SubscriptionProfile{Name: "bad", Exclude: "("}, false},
+		{models.SubscriptionProfile{Name: "bad", SortBy: "speed"}, false},
+	}
+	for _, tc := range cases {
+		if err := ValidateSubscriptionProfile(&tc.p); (err == nil) != tc.ok {
+			t.Fatalf("ValidateSubscriptionProfile(%+v) error = %v, want ok=%v", tc.p, err, tc.ok)
+		}
+	}
+}
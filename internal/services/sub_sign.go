@@ -0,0 +1,134 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"cboard/v2/internal/config"
+	"cboard/v2/internal/models"
+	"cboard/v2/internal/utils"
+)
+
+// ── 签名订阅链接 ──
+// 静态订阅 token 在用户重置前永久有效，泄露后可被长期使用。开启 sub_signed_url_enabled 后，
+// 下发给用户的链接改为带有效期（及可选设备 ID）的 HMAC 签名 token：
+//   s1.<订阅ID>.<过期时间戳>.<设备ID>.<签名>
+// 签名同时覆盖订阅当前的静态 token，重置订阅地址即可作废所有已签发链接。
+// 旧的静态 token 默认仍可使用，管理员可通过 sub_legacy_token_enabled=false 停用。
+
+const signedTokenPrefix = "s1."
+
+var (
+	ErrSignedLinkInvalid = errors.New("订阅链接签名无效")
+	ErrSignedLinkExpired = errors.New("订阅链接已过期")
+
+	signedDeviceIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+)
+
+// SignedLink is a parsed signed subscription token.
+type SignedLink struct {
+	SubscriptionID uint
+	ExpiresAt      time.Time
+	DeviceID       string
+	Signature      string
+}
+
+// SignedLinksEnabled reports whether new subscription links should be signed.
+func SignedLinksEnabled() bool {
+	return utils.IsBoolSetting("sub_signed_url_enabled")
+}
+
+// LegacyTokenAllowed reports whether static subscription tokens are still accepted.
+func LegacyTokenAllowed() bool {
+	return utils.IsBoolSettingDefault("sub_legacy_token_enabled", true)
+}
+
+// SignedLinkTTL returns the validity period of newly issued signed links.
+func SignedLinkTTL() time.Duration {
+	days := utils.GetIntSetting("sub_signed_url_ttl_days", 30)
+	if days < 1 {
+		days = 30
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// IsSignedToken reports whether a subscription token uses the signed format.
+func IsSignedToken(token string) bool {
+	return strings.HasPrefix(token, signedTokenPrefix)
+}
+
+// ValidSignedDeviceID reports whether a device ID can be embedded in a signed token.
+func ValidSignedDeviceID(deviceID string) bool {
+	return signedDeviceIDPattern.MatchString(deviceID)
+}
+
+func signSubscriptionLink(key []byte, subID uint, expiresAt int64, deviceID, staticToken string) string {
+	mac := hmac.New(sha256.New, key)
+	fmt.Fprintf(mac, "%s%d|%d|%s|%s", signedTokenPrefix, subID, expiresAt, deviceID, staticToken)
+	return hex.EncodeToString(mac.Sum(nil))[:32]
+}
+
+// SignSubscriptionToken issues a signed token for the subscription; deviceID may be empty.
+func SignSubscriptionToken(sub *models.Subscription, deviceID string, expiresAt time.Time) string {
+	if !ValidSignedDeviceID(deviceID) {
+		deviceID = ""
+	}
+	exp := expiresAt.Unix()
+	sig := signSubscriptionLink([]byte(config.GetSecretKey()), sub.ID, exp, deviceID, sub.SubscriptionURL)
+	return fmt.Sprintf("%s%d.%d.%s.%s", signedTokenPrefix, sub.ID, exp, deviceID, sig)
+}
+
+// SubscriptionLinkToken returns the token to embed in links handed to the user:
+// a signed token when signed links are enabled, otherwise the static token.
+func SubscriptionLinkToken(sub *models.Subscription, deviceID string) string {
+	if !SignedLinksEnabled() {
+		return sub.SubscriptionURL
+	}
+	return SignSubscriptionToken(sub, deviceID, time.Now().Add(SignedLinkTTL()))
+}
+
+// ParseSignedToken splits a signed token without verifying it.
+func ParseSignedToken(token string) (*SignedLink, error) {
+	if !IsSignedToken(token) {
+		return nil, ErrSignedLinkInvalid
+	}
+	parts := strings.Split(strings.TrimPrefix(token, signedTokenPrefix), ".")
+	if len(parts) != 4 {
+		return nil, ErrSignedLinkInvalid
+	}
+	subID, err := strconv.ParseUint(parts[0], 10, 64)
+	if err != nil || subID == 0 {
+		return nil, ErrSignedLinkInvalid
+	}
+	exp, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return nil, ErrSignedLinkInvalid
+	}
+	if parts[2] != "" && !ValidSignedDeviceID(parts[2]) {
+		return nil, ErrSignedLinkInvalid
+	}
+	return &SignedLink{SubscriptionID: uint(subID), ExpiresAt: time.Unix(exp, 0), DeviceID: parts[2], Signature: parts[3]}, nil
+}
+
+// VerifySignedLink checks the signature against the subscription and then the expiry.
+func VerifySignedLink(link *SignedLink, sub *models.Subscription) error {
+	return verifySignedLink([]byte(config.GetSecretKey()), link, sub, time.Now())
+}
+
+func verifySignedLink(key []byte, link *SignedLink, sub *models.Subscription, now time.Time) error {
+	want := signSubscriptionLink(key, sub.ID, link.ExpiresAt.Unix(), link.DeviceID, sub.SubscriptionURL)
+	if !hmac.Equal([]byte(want), []byte(link.Signature)) {
+		return ErrSignedLinkInvalid
+	}
+	if now.After(link.ExpiresAt) {
+		return ErrSignedLinkExpired
+	}
+	return nil
+}
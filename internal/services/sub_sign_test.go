@@ -0,0 +1,48 @@
+package services
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"cboard/v2/internal/models"
+)
+
+func TestSignedLinkRoundTrip(t *testing.T) {
+	key := []byte("0123456789abcdef0123456789abcdef")
+	sub := &models.Subscription{ID: 42, SubscriptionURL: "abcdef"}
+	now := time.Unix(1700000000, 0)
+	exp := now.Add(time.Hour).Unix()
+	token := "s1.42." + strconv.FormatInt(exp, 10) + ".phone-1." + signSubscriptionLink(key, 42, exp, "phone-1", sub.SubscriptionURL)
+
+	link, err := ParseSignedToken(token)
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	if link.SubscriptionID != 42 || link.DeviceID != "phone-1" {
+		t.Fatalf("unexpected link %+v", link)
+	}
+	if err := verifySignedLink(key, link, sub, now); err != nil {
+		t.Fatalf("expected valid link, got %v", err)
+	}
+	if err := verifySignedLink(key, link, sub, now.Add(2*time.Hour)); err != ErrSignedLinkExpired {
+		t.Fatalf("expected expired, got %v", err)
+	}
+	// 重置订阅地址后旧签名失效
+	reset := &models.Subscription{ID: 42, SubscriptionURL: "fedcba"}
+	if err := verifySignedLink(key, link, reset, now); err != ErrSignedLinkInvalid {
+		t.Fatalf("expected invalid after reset, got %v", err)
+	}
+	link.DeviceID = "other"
+	if err := verifySignedLink(key, link, sub, now); err != ErrSignedLinkInvalid {
+		t.Fatalf("expected invalid for tampered device, got %v", err)
+	}
+}
+
+func TestParseSignedTokenRejectsMalformed(t *testing.T) {
+	for _, token := range []string{"abcdef", "s1.", "s1.0.1..sig", "s1.x.1..sig", "s1.1.1.bad/dev.sig", "s1.1.1.sig"} {
+		if _, err := ParseSignedToken(token); err == nil {
+			t.Fatalf("expected %q to be rejected", token)
+		}
+	}
+}
@@ -26,6 +26,9 @@ func ActivateSubscription(db *gorm.DB, order *models.Order, paymentMethod string
 
 	var deviceLimit int
 	var durationDays int
+	var trafficQuota int64
+	var speedLimit int
+	var trafficResetMode string
 	var pkgName string
 
 	if order.PackageID == 0 && order.ExtraData != nil {
@@ -34,8 +37,11 @@ func ActivateSubscription(db *gorm.DB, order *models.Order, paymentMethod string
 			return fmt.Errorf("解析订单额外数据失败: %w", err)
 		}
 		if extra["type"] == "subscription_upgrade" {
-			var sub models.Subscription
-			if err := db.Where("user_id = ?", order.UserID).First(&sub).Error; err != nil {
+			sub, err := ResolveOrderSubscription(db, order)
+			if err == nil && sub == nil {
+				err = gorm.ErrRecordNotFound
+			}
+			if err != nil {
 				return fmt.Errorf("查找用户订阅失败: %w", err)
 			}
 			addDevices := 0
@@ -51,14 +57,15 @@ func ActivateSubscription(db *gorm.DB, order *models.Order, paymentMethod string
 			if extendMonths > 0 {
 				newExpire = newExpire.AddDate(0, extendMonths, 0)
 			}
-			if err := db.Model(&sub).Updates(map[string]interface{}{
+			if err := db.Model(sub).Updates(PreservePauseState(sub, map[string]interface{}{
 				"device_limit": newLimit,
 				"expire_time":  newExpire,
 				"is_active":    true,
 				"status":       "active",
-			}).Error; err != nil {
+			})).Error; err != nil {
 				return fmt.Errorf("更新升级订阅失败: %w", err)
 			}
+			BindOrderSubscription(db, order, sub.ID)
 			pkgName = fmt.Sprintf("订阅升级: +%d设备", addDevices)
 			if extendMonths > 0 {
 				pkgName = fmt.Sprintf("订阅升级: +%d设备, 续期%d月", addDevices, extendMonths)
@@ -70,11 +77,8 @@ func ActivateSubscription(db *gorm.DB, order *models.Order, paymentMethod string
 					payAmount = fmt.Sprintf("%.2f", *order.FinalAmount)
 				}
 				var subURL string
-				var userSub models.Subscription
-				if db.Where("user_id = ?", order.UserID).First(&userSub).Error == nil {
-					if siteURL := GetSiteURL(); siteURL != "" {
-						subURL = siteURL + "/api/v1/client/subscribe?token=" + userSub.SubscriptionURL
-					}
+				if siteURL := GetSiteURL(); siteURL != "" {
+					subURL = siteURL + "/api/v1/client/subscribe?token=" + SubscriptionLinkToken(sub, "")
 				}
 				emailSubject, emailBody := RenderEmail("payment_success", map[string]string{
 					"username": user.Username, "order_no": order.OrderNo, "amount": payAmount, "package_name": pkgName, "subscription_url": subURL,
@@ -102,21 +106,38 @@ func ActivateSubscription(db *gorm.DB, order *models.Order, paymentMethod string
 		}
 		deviceLimit = pkg.DeviceLimit
 		durationDays = pkg.DurationDays
+		trafficQuota = TrafficGBToBytes(pkg.TrafficGB)
+		speedLimit = pkg.SpeedLimit
+		trafficResetMode = pkg.TrafficResetMode
 		pkgName = pkg.Name
 	}
 
+	target, err := ResolveOrderSubscription(db, order)
+	if err != nil {
+		return fmt.Errorf("查找续期订阅失败: %w", err)
+	}
 	var sub models.Subscription
-	if err := db.Where("user_id = ?", order.UserID).First(&sub).Error; err != nil {
+	if target == nil {
+		// 下单后可能又通过其他订单新建了订阅，网关回调时再次检查数量上限
+		if order.NewSubscription {
+			if err := CheckSubscriptionQuota(db, order.UserID); err != nil {
+				return err
+			}
+		}
 		// Create new subscription
 		fmt.Printf("[subscription] 创建新订阅: user_id=%d, device_limit=%d, duration_days=%d\n",
 			order.UserID, deviceLimit, durationDays)
 		sub = models.Subscription{
-			UserID:          order.UserID,
-			SubscriptionURL: utils.GenerateHexToken(),
-			DeviceLimit:     deviceLimit,
-			IsActive:        true,
-			Status:          "active",
-			ExpireTime:      time.Now().AddDate(0, 0, durationDays),
+			UserID:           order.UserID,
+			SubscriptionURL:  utils.GenerateHexToken(),
+			DeviceLimit:      deviceLimit,
+			TrafficQuota:     trafficQuota,
+			SpeedLimit:       speedLimit,
+			TrafficResetMode: NormalizeTrafficResetMode(trafficResetMode),
+			TrafficResetDay:  time.Now().Day(),
+			IsActive:         true,
+			Status:           "active",
+			ExpireTime:       time.Now().AddDate(0, 0, durationDays),
 		}
 		if order.PackageID > 0 {
 			pkgID := int64(order.PackageID)
@@ -130,6 +151,7 @@ func ActivateSubscription(db *gorm.DB, order *models.Order, paymentMethod string
 		fmt.Printf("[subscription] 订阅创建成功: subscription_id=%d\n", sub.ID)
 	} else {
 		// Extend existing subscription
+		sub = *target
 		fmt.Printf("[subscription] 续期现有订阅: subscription_id=%d, old_expire=%s, add_days=%d\n",
 			sub.ID, sub.ExpireTime.Format("2006-01-02"), durationDays)
 		newExpire := sub.ExpireTime
@@ -137,22 +159,32 @@ func ActivateSubscription(db *gorm.DB, order *models.Order, paymentMethod string
 			newExpire = time.Now()
 		}
 		newExpire = newExpire.AddDate(0, 0, durationDays)
+		// 续费即开始新的计费周期：按新套餐设置配额并清零已用流量
 		updates := map[string]interface{}{
-			"device_limit": deviceLimit,
-			"expire_time":  newExpire,
-			"is_active":    true,
-			"status":       "active",
+			"device_limit":       deviceLimit,
+			"expire_time":        newExpire,
+			"is_active":          true,
+			"status":             "active",
+			"traffic_quota":      trafficQuota,
+			"speed_limit":        speedLimit,
+			"upload_traffic":     0,
+			"download_traffic":   0,
+			"traffic_reset_mode": NormalizeTrafficResetMode(trafficResetMode),
+			"traffic_reset_day":  time.Now().Day(),
+			"last_traffic_reset": time.Now(),
+			"traffic_exhausted":  false,
 		}
 		if order.PackageID > 0 {
 			pkgID := int64(order.PackageID)
 			updates["package_id"] = &pkgID
 		}
-		if err := db.Model(&sub).Updates(updates).Error; err != nil {
+		if err := db.Model(&sub).Updates(PreservePauseState(&sub, updates)).Error; err != nil {
 			return fmt.Errorf("更新订阅失败: %w", err)
 		}
 		utils.CreateSubscriptionLog(sub.ID, order.UserID, "extend", "system", nil, fmt.Sprintf("购买套餐续期订阅: %s, +%d天", pkgName, durationDays), nil, nil)
 		fmt.Printf("[subscription] 订阅续期成功: subscription_id=%d, new_expire=%s\n", sub.ID, newExpire.Format("2006-01-02"))
 	}
+	BindOrderSubscription(db, order, sub.ID)
 
 	var user models.User
 	if db.First(&user, order.UserID).Error == nil {
@@ -161,11 +193,8 @@ func ActivateSubscription(db *gorm.DB, order *models.Order, paymentMethod string
 			payAmount = fmt.Sprintf("%.2f", *order.FinalAmount)
 		}
 		var subURL string
-		var userSub models.Subscription
-		if db.Where("user_id = ?", order.UserID).First(&userSub).Error == nil {
-			if siteURL := GetSiteURL(); siteURL != "" {
-				subURL = siteURL + "/api/v1/client/subscribe?token=" + userSub.SubscriptionURL
-			}
+		if siteURL := GetSiteURL(); siteURL != "" {
+			subURL = siteURL + "/api/v1/client/subscribe?token=" + SubscriptionLinkToken(&sub, "")
 		}
 		emailSubject, emailBody := RenderEmail("payment_success", map[string]string{
 			"username": user.Username, "order_no": order.OrderNo, "amount": payAmount, "package_name": pkgName, "subscription_url": subURL,
@@ -181,6 +210,14 @@ func ActivateSubscription(db *gorm.DB, order *models.Order, paymentMethod string
 	return nil
 }
 
+// TrafficGBToBytes converts a package traffic quota in GB to bytes (0 = unlimited).
+func TrafficGBToBytes(gb int) int64 {
+	if gb <= 0 {
+		return 0
+	}
+	return int64(gb) << 30
+}
+
 func distributeInviteCommission(db *gorm.DB, order *models.Order) {
 	var relation models.InviteRelation
 	if err := db.Where("invitee_id = ?", order.UserID).First(&relation).Error; err != nil {
@@ -293,41 +330,9 @@ func formatSafeSingBoxTag(name string) string {
 	return cleaned
 }
 
-// GenerateSurgeConfig generates Surge-compatible proxy list
+// GenerateSurgeConfig generates a Surge profile with the default template.
 func GenerateSurgeConfig(nodes []models.Node, siteName string) string {
-	var sb strings.Builder
-	sb.WriteString(fmt.Sprintf("# %s Surge Config\n", siteName))
-	sb.WriteString("[Proxy]\n")
-	sb.WriteString("DIRECT = direct\n")
-	var names []string
-	for _, node := range nodes {
-		if node.Config == nil || *node.Config == "" {
-			continue
-		}
-		if strings.Contains(*node.Config, "baidu.com") {
-			continue
-		}
-		line := convertNodeToSurgeLine(node)
-		if line != "" {
-			sb.WriteString(line + "\n")
-			names = append(names, formatSafeCommaName(node.Name))
-		}
-	}
-	sb.WriteString("\n[Proxy Group]\n")
-	if len(names) > 0 {
-		sb.WriteString("Proxy = select, AutoTest, DIRECT, " + strings.Join(names, ", ") + "\n")
-		sb.WriteString("AutoTest = url-test, " + strings.Join(names, ", ") + ", url=http://www.gstatic.com/generate_204, interval=300, tolerance=50\n")
-	} else {
-		sb.WriteString("Proxy = select, DIRECT\n")
-	}
-	sb.WriteString("\n[Rule]\n")
-	sb.WriteString("DOMAIN-SET,https://cdn.jsdelivr.net/gh/Loyalsoldier/surge-rules@release/reject.txt,REJECT\n")
-	sb.WriteString("DOMAIN-SET,https://cdn.jsdelivr.net/gh/Loyalsoldier/surge-rules@release/proxy.txt,Proxy\n")
-	sb.WriteString("DOMAIN-SET,https://cdn.jsdelivr.net/gh/Loyalsoldier/surge-rules@release/direct.txt,DIRECT\n")
-	sb.WriteString("RULE-SET,https://cdn.jsdelivr.net/gh/Loyalsoldier/surge-rules@release/cncidr.txt,DIRECT\n")
-	sb.WriteString("GEOIP,CN,DIRECT,no-resolve\n")
-	sb.WriteString("FINAL,Proxy,dns-failed\n")
-	return sb.String()
+	return GenerateSurgeConfigWithTemplate(nodes, siteName, "")
 }
 
 func convertNodeToSurgeLine(node models.Node) string {
@@ -385,61 +390,25 @@ func GenerateShadowrocketBase64(nodes []models.Node) string {
 	return GenerateUniversalBase64(nodes)
 }
 
-// GenerateQuantumultXConfig generates a full QuantumultX configuration profile.
+// GenerateQuantumultXConfig generates a full QuantumultX configuration profile with the default template.
 func GenerateQuantumultXConfig(nodes []models.Node) string {
-	var proxyLines []string
-	for _, node := range nodes {
-		if node.Config == nil || *node.Config == "" {
-			continue
-		}
-		if strings.Contains(*node.Config, "baidu.com") {
-			continue
-		}
-		config := *node.Config
-		name := formatSafeCommaName(node.Name)
-		var line string
-		if strings.HasPrefix(config, "ss://") {
-			line = convertSSToQuantumultX(name, config)
-		} else if strings.HasPrefix(config, "trojan://") {
-			line = convertTrojanToQuantumultX(name, config)
-		} else {
-			m, err := NodeConfigToClashMap(node.Type, config, node.Name)
-			if err == nil {
-				line = clashMapToQuantumultXLine(name, m)
-			}
-		}
-		if line != "" {
-			proxyLines = append(proxyLines, line)
-		}
-	}
-
-	var sb strings.Builder
-	sb.WriteString("[general]\n")
-	sb.WriteString("network_check_url = http://www.gstatic.com/generate_204\n")
-	sb.WriteString("server_check_url = http://www.gstatic.com/generate_204\n")
-	sb.WriteString("geo_location_checker = http://www.gstatic.com/generate_204\n\n")
+	return GenerateQuantumultXConfigWithTemplate(nodes, "", "")
+}
 
-	sb.WriteString("[server_local]\n")
-	for _, l := range proxyLines {
-		sb.WriteString(l + "\n")
+// quantumultXLine converts a node to a QuantumultX server_local line.
+func quantumultXLine(node models.Node, name string) string {
+	config := *node.Config
+	if strings.HasPrefix(config, "ss://") {
+		return convertSSToQuantumultX(name, config)
 	}
-	sb.WriteString("\n")
-
-	sb.WriteString("[filter_remote]\n")
-	sb.WriteString("https://raw.githubusercontent.com/blackmatrix7/ios_rule_script/master/rule/QuantumultX/Advertising/Advertising.list, tag=Advertising, force-policy=REJECT, update-interval=86400, opt-parser=true, enabled=true\n")
-	sb.WriteString("https://raw.githubusercontent.com/blackmatrix7/ios_rule_script/master/rule/QuantumultX/Global/Global.list, tag=Global, force-policy=Proxy, update-interval=86400, opt-parser=true, enabled=true\n")
-	sb.WriteString("https://raw.githubusercontent.com/blackmatrix7/ios_rule_script/master/rule/QuantumultX/China/China.list, tag=China, force-policy=DIRECT, update-interval=86400, opt-parser=true, enabled=true\n\n")
-
-	sb.WriteString("[filter_local]\n")
-	sb.WriteString("GEOIP,CN,DIRECT\n")
-	sb.WriteString("FINAL,Proxy\n\n")
-
-	sb.WriteString("[rewrite_remote]\n\n")
-	sb.WriteString("[rewrite_local]\n\n")
-	sb.WriteString("[task_local]\n\n")
-	sb.WriteString("[mitm]\n")
-	sb.WriteString("skip_validating_cert = true\n")
-	return sb.String()
+	if strings.HasPrefix(config, "trojan://") {
+		return convertTrojanToQuantumultX(name, config)
+	}
+	m, err := NodeConfigToClashMap(node.Type, config, node.Name)
+	if err != nil {
+		return ""
+	}
+	return clashMapToQuantumultXLine(name, m)
 }
 
 func convertSSToQuantumultX(name, config string) string {
@@ -541,49 +510,9 @@ func clashMapToQuantumultXLine(name string, m map[string]interface{}) string {
 	return ""
 }
 
-// GenerateLoonConfig generates Loon-compatible proxy configuration.
+// GenerateLoonConfig generates a Loon profile with the default template.
 func GenerateLoonConfig(nodes []models.Node, siteName string) string {
-	var proxyLines []string
-	var proxyNames []string
-	for _, node := range nodes {
-		if node.Config == nil || *node.Config == "" {
-			continue
-		}
-		// Skip info nodes (server = baidu.com placeholder)
-		if strings.Contains(*node.Config, "baidu.com") {
-			continue
-		}
-		m, err := NodeConfigToClashMap(node.Type, *node.Config, node.Name)
-		if err != nil {
-			continue
-		}
-		name := formatSafeCommaName(node.Name)
-		line := clashMapToLoonLine(name, m)
-		if line != "" {
-			proxyLines = append(proxyLines, line)
-			proxyNames = append(proxyNames, name)
-		}
-	}
-	var sb strings.Builder
-	sb.WriteString(fmt.Sprintf("# %s - Loon Config\n\n", siteName))
-	sb.WriteString("[Proxy]\n")
-	for _, l := range proxyLines {
-		sb.WriteString(l + "\n")
-	}
-	sb.WriteString("\n[Proxy Group]\n")
-	if len(proxyNames) > 0 {
-		sb.WriteString("Proxy = select, " + strings.Join(proxyNames, ", ") + "\n")
-	} else {
-		sb.WriteString("Proxy = select, DIRECT\n")
-	}
-	sb.WriteString("\n[Remote Rule]\n")
-	sb.WriteString("https://raw.githubusercontent.com/blackmatrix7/ios_rule_script/master/rule/Loon/Advertising/Advertising.list, policy=REJECT, tag=Advertising, enabled=true\n")
-	sb.WriteString("https://raw.githubusercontent.com/blackmatrix7/ios_rule_script/master/rule/Loon/Global/Global.list, policy=Proxy, tag=Global, enabled=true\n")
-	sb.WriteString("https://raw.githubusercontent.com/blackmatrix7/ios_rule_script/master/rule/Loon/ChinaMax/ChinaMax.list, policy=DIRECT, tag=ChinaMax, enabled=true\n")
-	sb.WriteString("\n[Rule]\n")
-	sb.WriteString("GEOIP,CN,DIRECT,no-resolve\n")
-	sb.WriteString("FINAL,Proxy\n")
-	return sb.String()
+	return GenerateLoonConfigWithTemplate(nodes, siteName, "")
 }
 
 func clashMapToLoonLine(name string, m map[string]interface{}) string {
@@ -637,86 +566,9 @@ func clashMapToLoonLine(name string, m map[string]interface{}) string {
 	return ""
 }
 
-// GenerateSingBoxConfig generates SingBox JSON outbound configuration.
+// GenerateSingBoxConfig generates SingBox JSON configuration with the default template.
 func GenerateSingBoxConfig(nodes []models.Node) string {
-	var outbounds []map[string]interface{}
-	var proxyNames []string
-	for _, node := range nodes {
-		if node.Config == nil || *node.Config == "" {
-			continue
-		}
-		if strings.Contains(*node.Config, "baidu.com") {
-			continue
-		}
-		m, err := NodeConfigToClashMap(node.Type, *node.Config, node.Name)
-		if err != nil {
-			continue
-		}
-		tagName := formatSafeSingBoxTag(node.Name)
-		ob := clashMapToSingBoxOutbound(tagName, m)
-		if ob != nil {
-			outbounds = append(outbounds, ob)
-			proxyNames = append(proxyNames, tagName)
-		}
-	}
-	selectorOut := append([]string{}, proxyNames...)
-	selectorOut = append(selectorOut, "direct")
-	allOutbounds := []map[string]interface{}{
-		{"type": "selector", "tag": "Proxy", "outbounds": selectorOut},
-	}
-	allOutbounds = append(allOutbounds, outbounds...)
-	allOutbounds = append(allOutbounds,
-		map[string]interface{}{"type": "direct", "tag": "direct"},
-		map[string]interface{}{"type": "block", "tag": "block"},
-		map[string]interface{}{"type": "dns", "tag": "dns-out"},
-	)
-
-	config := map[string]interface{}{
-		"dns": map[string]interface{}{
-			"servers": []interface{}{
-				map[string]interface{}{"tag": "dns-direct", "address": "223.5.5.5", "strategy": "ipv4_only"},
-				map[string]interface{}{"tag": "dns-remote", "address": "8.8.8.8", "strategy": "ipv4_only", "detour": "Proxy"},
-			},
-			"rules": []interface{}{
-				map[string]interface{}{"outbound": "any", "server": "dns-direct"},
-				map[string]interface{}{"rule_set": "geosite-cn", "server": "dns-direct"},
-			},
-			"final": "dns-remote",
-		},
-		"outbounds": allOutbounds,
-		"route": map[string]interface{}{
-			"rule_set": []interface{}{
-				map[string]interface{}{
-					"tag": "geosite-ads", "type": "remote", "format": "binary",
-					"url":              "https://raw.githubusercontent.com/SagerNet/sing-geosite/rule-set/geosite-category-ads-all.srs",
-					"download_detour": "direct",
-				},
-				map[string]interface{}{
-					"tag": "geosite-cn", "type": "remote", "format": "binary",
-					"url":              "https://raw.githubusercontent.com/SagerNet/sing-geosite/rule-set/geosite-cn.srs",
-					"download_detour": "direct",
-				},
-				map[string]interface{}{
-					"tag": "geoip-cn", "type": "remote", "format": "binary",
-					"url":              "https://raw.githubusercontent.com/SagerNet/sing-geoip/rule-set/geoip-cn.srs",
-					"download_detour": "direct",
-				},
-			},
-			"rules": []interface{}{
-				map[string]interface{}{"protocol": "dns", "outbound": "dns-out"},
-				map[string]interface{}{"rule_set": []string{"geosite-ads"}, "outbound": "block"},
-				map[string]interface{}{"rule_set": []string{"geosite-cn", "geoip-cn"}, "outbound": "direct"},
-			},
-			"final":                  "Proxy",
-			"auto_detect_interface": true,
-		},
-		"cache_file": map[string]interface{}{"enabled": true},
-	}
-	b, err := json.MarshalIndent(config, "", "  ")
-	if err != nil {
-		return "{}"
-	}
-	return string(b)
+	return GenerateSingBoxConfigWithTemplate(nodes, "")
 }
 
 func clashMapToSingBoxOutbound(name string, m map[string]interface{}) map[string]interface{} {
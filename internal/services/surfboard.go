@@ -0,0 +1,127 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+
+	"cboard/v2/internal/models"
+)
+
+// ── Surfboard ──
+// Surfboard 的配置语法与 Surge 相近，但只支持 ss（含 obfs）、vmess、trojan、http、socks5，
+// 传输层仅 tcp / ws，其余节点在生成时跳过。模板占位符与 Surge 相同（见 profile_template.go）。
+
+const defaultSurfboardTemplate = `# {{site}} Surfboard Config
+[General]
+dns-server = system, 223.5.5.5, 119.29.29.29
+skip-proxy = 127.0.0.1, 192.168.0.0/16, 10.0.0.0/8, 172.16.0.0/12, 100.64.0.0/10, localhost, *.local
+proxy-test-url = http://www.gstatic.com/generate_204
+internet-test-url = http://www.gstatic.com/generate_204
+test-timeout = 5
+
+[Proxy]
+{{proxies}}
+
+[Proxy Group]
+Proxy = select, AutoTest, {{regions}}, DIRECT, {{all}}
+AutoTest = url-test, {{all}}, url=http://www.gstatic.com/generate_204, interval=600, tolerance=50
+{{region_groups}}
+
+[Rule]
+DOMAIN-SET,https://cdn.jsdelivr.net/gh/Loyalsoldier/surge-rules@release/reject.txt,REJECT
+DOMAIN-SET,https://cdn.jsdelivr.net/gh/Loyalsoldier/surge-rules@release/proxy.txt,Proxy
+DOMAIN-SET,https://cdn.jsdelivr.net/gh/Loyalsoldier/surge-rules@release/direct.txt,DIRECT
+RULE-SET,https://cdn.jsdelivr.net/gh/Loyalsoldier/surge-rules@release/cncidr.txt,DIRECT
+GEOIP,CN,DIRECT
+FINAL,Proxy
+`
+
+// GenerateSurfboardConfigWithTemplate generates a Surfboard profile; an empty template uses surfboard_temp.conf or the built-in default.
+func GenerateSurfboardConfigWithTemplate(nodes []models.Node, siteName, template string) string {
+	return generateProfileConfig(ConfigTemplateFormatSurfboard, nodes, siteName, template)
+}
+
+// surfboardLine converts a node to a Surfboard [Proxy] line, or "" when Surfboard cannot use it.
+func surfboardLine(node models.Node, name string) string {
+	m, err := NodeConfigToClashMap(node.Type, *node.Config, node.Name)
+	if err != nil {
+		return ""
+	}
+	typ, _ := m["type"].(string)
+	server, _ := m["server"].(string)
+	port := clashMapPortStr(m)
+	if server == "" || port == "" {
+		return ""
+	}
+	parts := []string{}
+	switch typ {
+	case "ss":
+		cipher, _ := m["cipher"].(string)
+		password, _ := m["password"].(string)
+		parts = append(parts, "ss", server, port, "encrypt-method="+cipher, "password="+password)
+		if plugin, _ := m["plugin"].(string); plugin != "" {
+			opts, _ := m["plugin-opts"].(map[string]interface{})
+			mode, _ := opts["mode"].(string)
+			if plugin != "obfs" || (mode != "http" && mode != "tls") {
+				return ""
+			}
+			parts = append(parts, "obfs="+mode)
+			if host, _ := opts["host"].(string); host != "" {
+				parts = append(parts, "obfs-host="+host)
+			}
+		}
+		parts = append(parts, "udp-relay=true")
+	case "vmess":
+		uuid, _ := m["uuid"].(string)
+		parts = append(parts, "vmess", server, port, "username="+uuid)
+		if clashMapIntField(m, "alterId") == 0 {
+			parts = append(parts, "vmess-aead=true")
+		}
+		if tls, _ := m["tls"].(bool); tls {
+			parts = append(parts, "tls=true")
+			if sni, _ := m["servername"].(string); sni != "" {
+				parts = append(parts, "sni="+sni)
+			}
+		}
+	case "trojan":
+		password, _ := m["password"].(string)
+		parts = append(parts, "trojan", server, port, "password="+password)
+		if sni := loonGetSNI(m); sni != "" {
+			parts = append(parts, "sni="+sni)
+		}
+	case "socks5", "http":
+		proto := typ
+		if tls, _ := m["tls"].(bool); tls {
+			if typ == "http" {
+				proto = "https"
+			} else {
+				proto = "socks5-tls"
+			}
+		}
+		parts = append(parts, proto, server, port)
+		if user, _ := m["username"].(string); user != "" {
+			password, _ := m["password"].(string)
+			parts = append(parts, user, password)
+		}
+	default:
+		return ""
+	}
+
+	if typ == "vmess" || typ == "trojan" {
+		switch network, _ := m["network"].(string); network {
+		case "", "tcp":
+		case "ws":
+			path, host := extractWSParams(m)
+			parts = append(parts, "ws=true", "ws-path="+path)
+			if host != "" {
+				parts = append(parts, "ws-headers=Host:"+host)
+			}
+		default:
+			return ""
+		}
+	}
+	if skip, _ := m["skip-cert-verify"].(bool); skip {
+		parts = append(parts, "skip-cert-verify=true")
+	}
+	return fmt.Sprintf("%s = %s", name, strings.Join(parts, ", "))
+}
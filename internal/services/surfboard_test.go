@@ -0,0 +1,55 @@
+package services
+
+import (
+	"strings"
+	"testing"
+
+	"cboard/v2/internal/models"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestSurfboardLine(t *testing.T) {
+	vmess := "vmess://eyJ2IjoiMiIsInBzIjoiSEsiLCJhZGQiOiJoay5leGFtcGxlLmNvbSIsInBvcnQiOiI0NDMiLCJpZCI6IjExMTExMTExLTIyMjItMzMzMy00NDQ0LTU1NTU1NTU1NTU1NSIsImFpZCI6IjAiLCJuZXQiOiJ3cyIsInBhdGgiOiIvd3MiLCJob3N0IjoiY2RuLmV4YW1wbGUuY29tIiwidGxzIjoidGxzIiwic25pIjoiaGsuZXhhbXBsZS5jb20ifQ=="
+	line := surfboardLine(models.Node{Name: "HK", Type: "vmess", Config: &vmess}, "HK")
+	want := "HK = vmess, hk.example.com, 443, username=11111111-2222-3333-4444-555555555555, vmess-aead=true, tls=true, sni=hk.example.com, ws=true, ws-path=/ws, ws-headers=Host:cdn.example.com"
+	if line != want {
+		t.Fatalf("unexpected vmess line:\n got %s\nwant %s", line, want)
+	}
+	vless := "vless://11111111-2222-3333-4444-555555555555@v.example.com:443?security=tls&sni=v.example.com#V"
+	if line := surfboardLine(models.Node{Name: "V", Type: "vless", Config: &vless}, "V"); line != "" {
+		t.Fatalf("vless is not supported by Surfboard, got %s", line)
+	}
+	grpc := "trojan://pw@t.example.com:443?type=grpc&serviceName=svc#T"
+	if line := surfboardLine(models.Node{Name: "T", Type: "trojan", Config: &grpc}, "T"); line != "" {
+		t.Fatalf("trojan over grpc is not supported by Surfboard, got %s", line)
+	}
+}
+
+func TestGenerateEgernConfig(t *testing.T) {
+	hy2 := "hysteria2://secret@hy.example.com:8443?sni=hy.example.com#HY"
+	reality := "vless://11111111-2222-3333-4444-555555555555@r.example.com:443?security=reality&pbk=PK&sid=1#R"
+	obfs := "hysteria2://secret@hy2.example.com:8443?sni=hy2.example.com&obfs=salamander&obfs-password=mask#OB"
+	nodes := []models.Node{
+		{Name: "香港 01", Type: "hysteria2", Config: &hy2},
+		{Name: "日本 01", Type: "vless", Config: &reality},
+		{Name: "日本 02", Type: "hysteria2", Config: &obfs},
+	}
+	var cfg struct {
+		Proxies      []map[string]map[string]interface{} `yaml:"proxies"`
+		PolicyGroups []map[string]map[string]interface{} `yaml:"policy_groups"`
+	}
+	out := GenerateEgernConfig(nodes)
+	if err := yaml.Unmarshal([]byte(out), &cfg); err != nil {
+		t.Fatalf("invalid YAML: %v\n%s", err, out)
+	}
+	if len(cfg.Proxies) != 1 || cfg.Proxies[0]["hysteria2"]["auth"] != "secret" {
+		t.Fatalf("unexpected proxies: %v", cfg.Proxies)
+	}
+	if len(cfg.PolicyGroups) != 3 || cfg.PolicyGroups[2]["auto_test"]["name"] != "香港节点" {
+		t.Fatalf("unexpected policy groups: %v", cfg.PolicyGroups)
+	}
+	if !strings.Contains(out, "default:") {
+		t.Fatalf("missing default rule:\n%s", out)
+	}
+}
@@ -0,0 +1,21 @@
+package services
+
+import "cboard/v2/internal/models"
+
+// testTrojanNode builds a trojan node for host whose link carries the same name.
+func testTrojanNode(name, host string) models.Node {
+	link := "trojan://secret@" + host + ":443?sni=" + host + "#" + name
+	return models.Node{Name: name, Type: "trojan", Config: &link}
+}
+
+// templateTestNodes is the shared fixture of the profile generator tests: an info node (server baidu.com)
+// followed by one Hong Kong and one Japan node, then extra.
+func templateTestNodes(extra ...models.Node) []models.Node {
+	info := "ss://YWVzLTEyOC1nY206aW5mbw==@baidu.com:1234#info"
+	nodes := []models.Node{
+		{Name: "📢 官网", Type: "ss", Config: &info},
+		testTrojanNode("香港 01", "hk.example.com"),
+		testTrojanNode("日本 01", "jp.example.com"),
+	}
+	return append(nodes, extra...)
+}
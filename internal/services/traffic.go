@@ -0,0 +1,104 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	"cboard/v2/internal/models"
+	"cboard/v2/internal/utils"
+
+	"gorm.io/gorm"
+)
+
+// ── 流量配额与重置周期 ──
+
+const (
+	TrafficResetMonthly     = "monthly"     // 每月 1 日重置
+	TrafficResetAnniversary = "anniversary" // 按购买日每月重置
+	TrafficResetNever       = "never"       // 不重置（整个订阅周期共用配额）
+)
+
+// NormalizeTrafficResetMode maps unknown/empty values to the monthly default.
+func NormalizeTrafficResetMode(mode string) string {
+	switch mode {
+	case TrafficResetAnniversary, TrafficResetNever:
+		return mode
+	default:
+		return TrafficResetMonthly
+	}
+}
+
+// NextTrafficReset returns the first reset boundary strictly after `from`, or the zero time for "never".
+func NextTrafficReset(mode string, day int, from time.Time) time.Time {
+	switch NormalizeTrafficResetMode(mode) {
+	case TrafficResetNever:
+		return time.Time{}
+	case TrafficResetAnniversary:
+		if day < 1 {
+			day = 1
+		}
+		next := resetDayInMonth(from.Year(), from.Month(), day, from.Location())
+		if !next.After(from) {
+			next = resetDayInMonth(from.Year(), from.Month()+1, day, from.Location())
+		}
+		return next
+	default:
+		return time.Date(from.Year(), from.Month()+1, 1, 0, 0, 0, 0, from.Location())
+	}
+}
+
+// resetDayInMonth clamps the reset day to the month length (e.g. the 31st becomes Feb 28/29).
+func resetDayInMonth(year int, month time.Month, day int, loc *time.Location) time.Time {
+	lastDay := time.Date(year, month+1, 0, 0, 0, 0, 0, loc).Day()
+	if day > lastDay {
+		day = lastDay
+	}
+	return time.Date(year, month, day, 0, 0, 0, 0, loc)
+}
+
+// SubscriptionNextTrafficReset returns when the subscription's counters are reset next (zero = never).
+func SubscriptionNextTrafficReset(sub *models.Subscription) time.Time {
+	last := sub.CreatedAt
+	if sub.LastTrafficReset != nil {
+		last = *sub.LastTrafficReset
+	}
+	return NextTrafficReset(sub.TrafficResetMode, sub.TrafficResetDay, last)
+}
+
+// resetSubscriptionTraffic starts a new traffic period for sub and logs it. The counters read into sub are
+// subtracted rather than overwritten with 0, so traffic reported after they were read counts toward the new period.
+func resetSubscriptionTraffic(db *gorm.DB, sub *models.Subscription, now time.Time) error {
+	if err := db.Model(&models.Subscription{}).Where("id = ?", sub.ID).Updates(map[string]interface{}{
+		"upload_traffic":     gorm.Expr("CASE WHEN upload_traffic > ? THEN upload_traffic - ? ELSE 0 END", sub.UploadTraffic, sub.UploadTraffic),
+		"download_traffic":   gorm.Expr("CASE WHEN download_traffic > ? THEN download_traffic - ? ELSE 0 END", sub.DownloadTraffic, sub.DownloadTraffic),
+		"traffic_exhausted":  false,
+		"last_traffic_reset": now,
+	}).Error; err != nil {
+		return err
+	}
+	used := sub.UploadTraffic + sub.DownloadTraffic
+	utils.CreateSubscriptionLog(sub.ID, sub.UserID, "traffic_reset", "system", nil,
+		fmt.Sprintf("流量周期重置，上周期已用 %s", FormatTrafficBytes(used)),
+		map[string]interface{}{"upload_traffic": sub.UploadTraffic, "download_traffic": sub.DownloadTraffic},
+		map[string]interface{}{"upload_traffic": 0, "download_traffic": 0})
+	return nil
+}
+
+// IsTrafficExhausted reports whether a quota-limited subscription has used up its traffic.
+func IsTrafficExhausted(sub *models.Subscription) bool {
+	return sub.TrafficQuota > 0 && sub.UploadTraffic+sub.DownloadTraffic >= sub.TrafficQuota
+}
+
+// FormatTrafficBytes renders a byte count for info nodes and logs (e.g. "12.50 GB").
+func FormatTrafficBytes(n int64) string {
+	switch {
+	case n >= 1<<40:
+		return fmt.Sprintf("%.2f TB", float64(n)/(1<<40))
+	case n >= 1<<30:
+		return fmt.Sprintf("%.2f GB", float64(n)/(1<<30))
+	case n >= 1<<20:
+		return fmt.Sprintf("%.2f MB", float64(n)/(1<<20))
+	default:
+		return fmt.Sprintf("%.2f KB", float64(n)/(1<<10))
+	}
+}
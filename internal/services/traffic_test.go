@@ -0,0 +1,64 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"cboard/v2/internal/models"
+
+	"gorm.io/gorm"
+)
+
+func TestNextTrafficReset(t *testing.T) {
+	loc := time.UTC
+	from := time.Date(2026, 1, 31, 10, 0, 0, 0, loc)
+
+	if got := NextTrafficReset(TrafficResetMonthly, 0, from); !got.Equal(time.Date(2026, 2, 1, 0, 0, 0, 0, loc)) {
+		t.Fatalf("monthly: unexpected next reset %v", got)
+	}
+	// 31 日购买的订阅在 2 月按月末重置
+	if got := NextTrafficReset(TrafficResetAnniversary, 31, from); !got.Equal(time.Date(2026, 2, 28, 0, 0, 0, 0, loc)) {
+		t.Fatalf("anniversary: unexpected next reset %v", got)
+	}
+	if got := NextTrafficReset(TrafficResetAnniversary, 15, time.Date(2026, 3, 10, 0, 0, 0, 0, loc)); !got.Equal(time.Date(2026, 3, 15, 0, 0, 0, 0, loc)) {
+		t.Fatalf("anniversary same month: unexpected next reset %v", got)
+	}
+	if got := NextTrafficReset(TrafficResetNever, 0, from); !got.IsZero() {
+		t.Fatalf("never: expected zero time, got %v", got)
+	}
+}
+
+func TestResetSubscriptionTrafficKeepsLateTraffic(t *testing.T) {
+	db := setupPauseTestDB(t)
+	sub := models.Subscription{UserID: 1, SubscriptionURL: "traffic-reset-test", DeviceLimit: 3, Status: "active",
+		ExpireTime: time.Now().Add(24 * time.Hour), UploadTraffic: 100, DownloadTraffic: 200}
+	if err := db.Create(&sub).Error; err != nil {
+		t.Fatalf("create subscription: %v", err)
+	}
+	// 读取计数之后又上报了 50 字节上行
+	snapshot := sub
+	db.Model(&sub).Update("upload_traffic", gorm.Expr("upload_traffic + ?", 50))
+
+	if err := resetSubscriptionTraffic(db, &snapshot, time.Now()); err != nil {
+		t.Fatalf("reset: %v", err)
+	}
+	var got models.Subscription
+	db.First(&got, sub.ID)
+	if got.UploadTraffic != 50 || got.DownloadTraffic != 0 || got.LastTrafficReset == nil {
+		t.Fatalf("after reset: upload=%d download=%d last=%v", got.UploadTraffic, got.DownloadTraffic, got.LastTrafficReset)
+	}
+
+	// 无已用流量的周期同样记录日志
+	got.UploadTraffic = 0
+	db.Model(&got).Update("upload_traffic", 0)
+	if err := resetSubscriptionTraffic(db, &got, time.Now()); err != nil {
+		t.Fatalf("idle reset: %v", err)
+	}
+	var logs int64
+	for deadline := time.Now().Add(time.Second); logs < 2 && time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		db.Model(&models.SubscriptionLog{}).Where("subscription_id = ? AND action_type = ?", sub.ID, "traffic_reset").Count(&logs)
+	}
+	if logs != 2 {
+		t.Fatalf("expected a log entry for every reset, got %d", logs)
+	}
+}
@@ -5,7 +5,10 @@ import (
 	"fmt"
 	"regexp"
 	"sort"
+	"strconv"
 	"strings"
+
+	"cboard/v2/internal/utils"
 )
 
 // Pre-compiled regexps (avoid recompiling on every ParseUserAgent call)
@@ -32,7 +35,7 @@ type ClientInfo struct {
 	DeviceBrand      string
 	DeviceType       string // mobile, desktop, tablet, unknown
 	IsBrowser        bool
-	SubscriptionType string // clash, surge, shadowrocket, quantumult, v2ray
+	SubscriptionType string // clash, surge, surfboard, egern, shadowrocket, quantumult, v2ray, xray
 }
 
 var proxyKeywords = []string{
@@ -42,6 +45,7 @@ var proxyKeywords = []string{
 	"shadowsocksr", "ssr", "surfboard", "surge", "v2ray", "v2rayn",
 	"v2rayng", "v2rayu", "v2rayx", "stash", "anx", "anxray", "kitsunebi",
 	"pharos", "potatso", "karing", "neko", "nekoray", "nekobox", "sing-box",
+	"xray", "egern",
 }
 
 var browserKeywords = []string{
@@ -102,8 +106,8 @@ func detectSoftware(lower, ua string, info *ClientInfo) {
 		{"mihomo", "Mihomo"},
 		{"clash", "Clash"},
 		{"hiddify", "Hiddify"},
-		{"v2rayn", "v2rayN"},
 		{"v2rayng", "v2rayNG"},
+		{"v2rayn", "v2rayN"},
 		{"v2rayu", "V2RayU"},
 		{"v2rayx", "V2RayX"},
 		{"v2ray", "V2Ray"},
@@ -112,6 +116,7 @@ func detectSoftware(lower, ua string, info *ClientInfo) {
 		{"nekobox", "NekoBox"},
 		{"karing", "Karing"},
 		{"surfboard", "Surfboard"},
+		{"egern", "Egern"},
 		{"pharos", "Pharos"},
 		{"potatso", "Potatso"},
 		{"kitsunebi", "Kitsunebi"},
@@ -121,6 +126,7 @@ func detectSoftware(lower, ua string, info *ClientInfo) {
 		{"anxray", "AnXray"},
 		{"matsuri", "Matsuri"},
 		{"sagernet", "SagerNet"},
+		{"xray", "Xray"},
 	}
 	for _, r := range rules {
 		if strings.Contains(lower, r.keyword) {
@@ -341,12 +347,58 @@ func determineSubscriptionType(info *ClientInfo) string {
 	case "Loon":
 		return "loon"
 	case "Surfboard":
-		return "clash" // Surfboard supports Clash format
+		return "surfboard"
+	case "Egern":
+		return "egern"
+	case "Xray":
+		return "xray"
+	case "v2rayN", "v2rayNG":
+		if prefersXrayJSON(info) {
+			return "xray"
+		}
+		return "v2ray"
 	default:
 		return "v2ray"
 	}
 }
 
+// xrayJSONMinVersions are the first v2rayN / v2rayNG releases that import Xray JSON subscriptions as full configs.
+var xrayJSONMinVersions = map[string]string{
+	"v2rayN":  "7.0",
+	"v2rayNG": "1.9.0",
+}
+
+// prefersXrayJSON reports whether a v2rayN / v2rayNG client should get the Xray JSON format;
+// this is opt-in (sub_xray_auto_select) because older clients can only import share links.
+func prefersXrayJSON(info *ClientInfo) bool {
+	minVersion, ok := xrayJSONMinVersions[info.SoftwareName]
+	if !ok || info.SoftwareVersion == "" || !utils.IsBoolSetting("sub_xray_auto_select") {
+		return false
+	}
+	return compareVersions(info.SoftwareVersion, minVersion) >= 0
+}
+
+// compareVersions compares dotted numeric versions, treating missing parts as 0.
+func compareVersions(a, b string) int {
+	pa, pb := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(pa) || i < len(pb); i++ {
+		var na, nb int
+		if i < len(pa) {
+			na, _ = strconv.Atoi(pa[i])
+		}
+		if i < len(pb) {
+			nb, _ = strconv.Atoi(pb[i])
+		}
+		if na != nb {
+			if na < nb {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
 func isBrowserRequest(lower string) bool {
 	// Check proxy keywords first — proxy clients take priority
 	for _, kw := range proxyKeywords {
@@ -0,0 +1,445 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ── 用户自定义规则 ──
+// 规则使用 Clash 语法 "类型,内容,策略[,no-resolve]"，每行一条，下发时插入到模板规则之前：
+//   - Clash / Stash：rules 列表开头
+//   - Surge：[Rule] 段开头（DOMAIN-REGEX、GEOSITE 等无对应类型的规则跳过）
+//   - sing-box：route.rules 中 sniff / dns 等前置规则之后（GEOSITE、GEOIP、IP-ASN 跳过）
+// 策略可写 DIRECT、REJECT、PROXY（配置中的第一个 select 分组）或配置中已有的分组名，
+// 找不到对应分组的规则在该配置中跳过，避免客户端因未知策略拒绝加载整份配置。
+
+const (
+	// UserRulePolicyProxy resolves to the first select group of the rendered profile.
+	UserRulePolicyProxy = "PROXY"
+
+	userRuleMaxLength = 256
+)
+
+// UserRule is one parsed custom rule.
+type UserRule struct {
+	Type      string
+	Payload   string
+	Policy    string
+	NoResolve bool
+}
+
+// userRuleTypes lists the accepted Clash rule types; MATCH / RULE-SET / logic rules are not allowed
+// because they would shadow or depend on the template's own rules.
+var userRuleTypes = map[string]bool{
+	"DOMAIN": true, "DOMAIN-SUFFIX": true, "DOMAIN-KEYWORD": true, "DOMAIN-REGEX": true,
+	"GEOSITE": true, "GEOIP": true, "IP-CIDR": true, "IP-CIDR6": true, "IP-ASN": true, "SRC-IP-CIDR": true,
+	"DST-PORT": true, "SRC-PORT": true, "PROCESS-NAME": true, "PROCESS-PATH": true, "NETWORK": true,
+}
+
+var userRuleNamePattern = regexp.MustCompile(`^[A-Za-z0-9_.!@-]+$`)
+
+// String renders the rule in Clash syntax.
+func (r UserRule) String() string {
+	s := r.Type + "," + r.Payload + "," + r.Policy
+	if r.NoResolve {
+		s += ",no-resolve"
+	}
+	return s
+}
+
+// ParseUserRule parses and validates one rule line, normalizing the type and built-in policies to upper case.
+func ParseUserRule(line string) (UserRule, error) {
+	line = strings.TrimSpace(line)
+	if len(line) > userRuleMaxLength {
+		return UserRule{}, fmt.Errorf("规则长度不能超过 %d 个字符", userRuleMaxLength)
+	}
+	parts := strings.Split(line, ",")
+	for i := range parts {
+		parts[i] = strings.TrimSpace(parts[i])
+	}
+	if len(parts) < 3 || len(parts) > 4 {
+		return UserRule{}, errors.New("格式应为 类型,内容,策略[,no-resolve]")
+	}
+	r := UserRule{Type: strings.ToUpper(parts[0]), Payload: parts[1], Policy: parts[2]}
+	if !userRuleTypes[r.Type] {
+		return UserRule{}, fmt.Errorf("不支持的规则类型: %s", parts[0])
+	}
+	if len(parts) == 4 {
+		if !strings.EqualFold(parts[3], "no-resolve") {
+			return UserRule{}, fmt.Errorf("不支持的规则参数: %s", parts[3])
+		}
+		r.NoResolve = true
+	}
+	if r.Payload == "" {
+		return UserRule{}, errors.New("规则内容不能为空")
+	}
+	if r.Policy == "" {
+		return UserRule{}, errors.New("策略不能为空")
+	}
+	switch upper := strings.ToUpper(r.Policy); upper {
+	case "DIRECT", "REJECT", UserRulePolicyProxy:
+		r.Policy = upper
+	}
+
+	switch r.Type {
+	case "DOMAIN", "DOMAIN-SUFFIX", "DOMAIN-KEYWORD":
+		if strings.ContainsAny(r.Payload, " \t/") {
+			return UserRule{}, fmt.Errorf("无效的域名: %s", r.Payload)
+		}
+		r.Payload = strings.ToLower(r.Payload)
+	case "DOMAIN-REGEX":
+		if _, err := regexp.Compile(r.Payload); err != nil {
+			return UserRule{}, fmt.Errorf("无效的正则: %v", err)
+		}
+	case "GEOSITE", "GEOIP":
+		if !userRuleNamePattern.MatchString(r.Payload) {
+			return UserRule{}, fmt.Errorf("无效的分类名: %s", r.Payload)
+		}
+	case "IP-CIDR", "IP-CIDR6", "SRC-IP-CIDR":
+		ip, _, err := net.ParseCIDR(r.Payload)
+		if err != nil {
+			return UserRule{}, fmt.Errorf("无效的 CIDR: %s", r.Payload)
+		}
+		if r.Type == "IP-CIDR6" && ip.To4() != nil {
+			return UserRule{}, fmt.Errorf("IP-CIDR6 需要 IPv6 地址段: %s", r.Payload)
+		}
+	case "IP-ASN":
+		if _, err := strconv.ParseUint(r.Payload, 10, 32); err != nil {
+			return UserRule{}, fmt.Errorf("无效的 ASN: %s", r.Payload)
+		}
+	case "DST-PORT", "SRC-PORT":
+		if _, _, err := parseUserRulePort(r.Payload); err != nil {
+			return UserRule{}, err
+		}
+	case "NETWORK":
+		r.Payload = strings.ToLower(r.Payload)
+		if r.Payload != "tcp" && r.Payload != "udp" {
+			return UserRule{}, errors.New("NETWORK 只支持 tcp 或 udp")
+		}
+	}
+	return r, nil
+}
+
+// parseUserRulePort parses "443" or "1000-2000".
+func parseUserRulePort(s string) (from, to int, err error) {
+	lo, hi, isRange := strings.Cut(s, "-")
+	from, err1 := strconv.Atoi(lo)
+	to = from
+	var err2 error
+	if isRange {
+		to, err2 = strconv.Atoi(hi)
+	}
+	if err1 != nil || err2 != nil || from < 1 || to > 65535 || from > to {
+		return 0, 0, fmt.Errorf("无效的端口: %s", s)
+	}
+	return from, to, nil
+}
+
+// ValidateUserRules parses every non-empty, non-comment line and returns the normalized lines.
+func ValidateUserRules(lines []string, max int) ([]string, error) {
+	var cleaned []string
+	for i, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		r, err := ParseUserRule(line)
+		if err != nil {
+			return nil, fmt.Errorf("第 %d 行规则无效: %v", i+1, err)
+		}
+		cleaned = append(cleaned, r.String())
+	}
+	if max > 0 && len(cleaned) > max {
+		return nil, fmt.Errorf("自定义规则最多 %d 条", max)
+	}
+	return cleaned, nil
+}
+
+// DecodeUserRules parses stored rules (one per line), dropping lines that no longer validate.
+func DecodeUserRules(stored string) []UserRule {
+	var rules []UserRule
+	for _, line := range strings.Split(stored, "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if r, err := ParseUserRule(line); err == nil {
+			rules = append(rules, r)
+		}
+	}
+	return rules
+}
+
+// resolveUserRulePolicy maps a rule policy onto the rendered profile; ok is false when the target group is missing.
+func resolveUserRulePolicy(policy string, groups map[string]bool, firstSelect string) (string, bool) {
+	if groups[policy] {
+		return policy, true
+	}
+	switch policy {
+	case "DIRECT", "REJECT":
+		return policy, true
+	case UserRulePolicyProxy:
+		return firstSelect, firstSelect != ""
+	}
+	return "", false
+}
+
+// InjectClashRules prepends custom rules to the rules of a rendered Clash / Stash profile.
+func InjectClashRules(config string, rules []UserRule) string {
+	if len(rules) == 0 {
+		return config
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(config), &doc); err != nil || len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return config
+	}
+	root := doc.Content[0]
+
+	groups := make(map[string]bool)
+	firstSelect := ""
+	if groupsNode := yamlMappingValue(root, "proxy-groups"); groupsNode != nil {
+		for _, g := range groupsNode.Content {
+			name := yamlMappingValue(g, "name")
+			if name == nil {
+				continue
+			}
+			groups[name.Value] = true
+			if t := yamlMappingValue(g, "type"); firstSelect == "" && t != nil && t.Value == "select" {
+				firstSelect = name.Value
+			}
+		}
+	}
+
+	var injected []*yaml.Node
+	for _, r := range rules {
+		policy, ok := resolveUserRulePolicy(r.Policy, groups, firstSelect)
+		if !ok {
+			continue
+		}
+		r.Policy = policy
+		injected = append(injected, &yaml.Node{Kind: yaml.ScalarNode, Value: r.String(), Tag: "!!str"})
+	}
+	if len(injected) == 0 {
+		return config
+	}
+	if rulesNode := yamlMappingValue(root, "rules"); rulesNode != nil && rulesNode.Kind == yaml.SequenceNode {
+		rulesNode.Content = append(injected, rulesNode.Content...)
+	} else {
+		setYAMLMappingValue(root, "rules", &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq", Content: injected})
+	}
+	output, err := yaml.Marshal(&doc)
+	if err != nil {
+		return config
+	}
+	return unescapeUnicode(string(output))
+}
+
+// surgeUserRuleTypes maps Clash rule types to Surge; types without an equivalent (such as PROCESS-PATH,
+// whose full path never matches a Surge PROCESS-NAME) are skipped.
+var surgeUserRuleTypes = map[string]string{
+	"DOMAIN": "DOMAIN", "DOMAIN-SUFFIX": "DOMAIN-SUFFIX", "DOMAIN-KEYWORD": "DOMAIN-KEYWORD",
+	"GEOIP": "GEOIP", "IP-CIDR": "IP-CIDR", "IP-CIDR6": "IP-CIDR6", "IP-ASN": "IP-ASN", "SRC-IP-CIDR": "SRC-IP",
+	"DST-PORT": "DEST-PORT", "SRC-PORT": "SRC-PORT", "PROCESS-NAME": "PROCESS-NAME",
+	"NETWORK": "PROTOCOL",
+}
+
+// InjectSurgeRules inserts custom rules at the top of the [Rule] section of a rendered Surge profile.
+func InjectSurgeRules(config string, rules []UserRule) string {
+	if len(rules) == 0 {
+		return config
+	}
+	lines := strings.Split(config, "\n")
+	groups := make(map[string]bool)
+	firstSelect, section, ruleIdx := "", "", -1
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "[") && strings.HasSuffix(trimmed, "]") {
+			section = strings.ToLower(trimmed)
+			if section == "[rule]" && ruleIdx < 0 {
+				ruleIdx = i
+			}
+			continue
+		}
+		if section != "[proxy group]" || trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		name, def, ok := strings.Cut(trimmed, "=")
+		if !ok {
+			continue
+		}
+		name = strings.TrimSpace(name)
+		groups[name] = true
+		if kind, _, _ := strings.Cut(strings.TrimSpace(def), ","); firstSelect == "" && strings.TrimSpace(kind) == "select" {
+			firstSelect = name
+		}
+	}
+
+	var injected []string
+	for _, r := range rules {
+		ruleType, ok := surgeUserRuleTypes[r.Type]
+		if !ok {
+			continue
+		}
+		policy, ok := resolveUserRulePolicy(r.Policy, groups, firstSelect)
+		if !ok {
+			continue
+		}
+		payload := r.Payload
+		if r.Type == "NETWORK" {
+			payload = strings.ToUpper(payload)
+		}
+		line := ruleType + "," + payload + "," + policy
+		if r.NoResolve {
+			line += ",no-resolve"
+		}
+		injected = append(injected, line)
+	}
+	if len(injected) == 0 {
+		return config
+	}
+	if ruleIdx < 0 {
+		return strings.TrimRight(config, "\n") + "\n\n[Rule]\n" + strings.Join(injected, "\n") + "\n"
+	}
+	result := append([]string{}, lines[:ruleIdx+1]...)
+	result = append(result, injected...)
+	return strings.Join(append(result, lines[ruleIdx+1:]...), "\n")
+}
+
+// InjectSingBoxRules inserts custom rules into route.rules of a rendered sing-box profile, after the
+// leading sniff / DNS rules so that domain matching keeps working.
+func InjectSingBoxRules(config string, rules []UserRule) string {
+	if len(rules) == 0 {
+		return config
+	}
+	dec := json.NewDecoder(strings.NewReader(config))
+	dec.UseNumber()
+	var root map[string]interface{}
+	if err := dec.Decode(&root); err != nil || root == nil {
+		return config
+	}
+
+	groups := make(map[string]bool)
+	firstSelect, directTag, blockTag := "", "", ""
+	outbounds, _ := root["outbounds"].([]interface{})
+	for _, item := range outbounds {
+		ob, _ := item.(map[string]interface{})
+		tag, _ := ob["tag"].(string)
+		if tag == "" {
+			continue
+		}
+		groups[tag] = true
+		switch ob["type"] {
+		case "selector":
+			if firstSelect == "" {
+				firstSelect = tag
+			}
+		case "direct":
+			if directTag == "" {
+				directTag = tag
+			}
+		case "block":
+			if blockTag == "" {
+				blockTag = tag
+			}
+		}
+	}
+
+	var injected []interface{}
+	for _, r := range rules {
+		rule := singBoxUserRule(r)
+		if rule == nil {
+			continue
+		}
+		switch {
+		case groups[r.Policy]:
+			rule["outbound"] = r.Policy
+		case r.Policy == "DIRECT" && directTag != "":
+			rule["outbound"] = directTag
+		case r.Policy == "REJECT" && blockTag != "":
+			rule["outbound"] = blockTag
+		case r.Policy == "REJECT":
+			rule["action"] = "reject"
+		case r.Policy == UserRulePolicyProxy && firstSelect != "":
+			rule["outbound"] = firstSelect
+		default:
+			continue
+		}
+		injected = append(injected, rule)
+	}
+	if len(injected) == 0 {
+		return config
+	}
+
+	route, _ := root["route"].(map[string]interface{})
+	if route == nil {
+		route = make(map[string]interface{})
+		root["route"] = route
+	}
+	existing, _ := route["rules"].([]interface{})
+	pos := 0
+	for pos < len(existing) {
+		rule, _ := existing[pos].(map[string]interface{})
+		action, _ := rule["action"].(string)
+		if action != "sniff" && action != "resolve" && action != "hijack-dns" && rule["protocol"] != "dns" {
+			break
+		}
+		pos++
+	}
+	merged := append([]interface{}{}, existing[:pos]...)
+	merged = append(merged, injected...)
+	route["rules"] = append(merged, existing[pos:]...)
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(root); err != nil {
+		return config
+	}
+	return strings.TrimRight(buf.String(), "\n")
+}
+
+// singBoxUserRule converts a rule to a sing-box route rule without its outbound; nil when unsupported.
+func singBoxUserRule(r UserRule) map[string]interface{} {
+	switch r.Type {
+	case "DOMAIN":
+		return map[string]interface{}{"domain": []string{r.Payload}}
+	case "DOMAIN-SUFFIX":
+		return map[string]interface{}{"domain_suffix": []string{r.Payload}}
+	case "DOMAIN-KEYWORD":
+		return map[string]interface{}{"domain_keyword": []string{r.Payload}}
+	case "DOMAIN-REGEX":
+		return map[string]interface{}{"domain_regex": []string{r.Payload}}
+	case "IP-CIDR", "IP-CIDR6":
+		return map[string]interface{}{"ip_cidr": []string{r.Payload}}
+	case "SRC-IP-CIDR":
+		return map[string]interface{}{"source_ip_cidr": []string{r.Payload}}
+	case "DST-PORT", "SRC-PORT":
+		key := "port"
+		if r.Type == "SRC-PORT" {
+			key = "source_port"
+		}
+		from, to, err := parseUserRulePort(r.Payload)
+		if err != nil {
+			return nil
+		}
+		if from == to {
+			return map[string]interface{}{key: []int{from}}
+		}
+		return map[string]interface{}{key + "_range": []string{fmt.Sprintf("%d:%d", from, to)}}
+	case "PROCESS-NAME":
+		return map[string]interface{}{"process_name": []string{r.Payload}}
+	case "PROCESS-PATH":
+		return map[string]interface{}{"process_path": []string{r.Payload}}
+	case "NETWORK":
+		return map[string]interface{}{"network": []string{r.Payload}}
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package services
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateUserRules(t *testing.T) {
+	lines := []string{
+		"domain-suffix, Corp.Example.com, direct",
+		"# comment",
+		"",
+		"IP-CIDR,10.0.0.0/8,DIRECT,no-resolve",
+		"DST-PORT,1000-2000,Proxy",
+	}
+	got, err := ValidateUserRules(lines, 10)
+	if err != nil {
+		t.Fatalf("ValidateUserRules: %v", err)
+	}
+	want := []string{"DOMAIN-SUFFIX,corp.example.com,DIRECT", "IP-CIDR,10.0.0.0/8,DIRECT,no-resolve", "DST-PORT,1000-2000,PROXY"}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("normalized rules = %q, want %q", got, want)
+	}
+
+	for _, bad := range []string{"MATCH,,DIRECT", "RULE-SET,ads,REJECT", "DOMAIN,example.com", "IP-CIDR,10.0.0.1,DIRECT", "IP-CIDR6,10.0.0.0/8,DIRECT", "DST-PORT,70000,DIRECT", "DOMAIN,a.com,DIRECT,extra"} {
+		if _, err := ValidateUserRules([]string{bad}, 10); err == nil {
+			t.Fatalf("ValidateUserRules(%q) accepted an invalid rule", bad)
+		}
+	}
+	if _, err := ValidateUserRules([]string{"DOMAIN,a.com,DIRECT", "DOMAIN,b.com,DIRECT"}, 1); err == nil {
+		t.Fatalf("ValidateUserRules ignored the rule limit")
+	}
+}
+
+func TestInjectClashRules(t *testing.T) {
+	config := "proxy-groups:\n  - name: 节点选择\n    type: select\n    proxies: [DIRECT]\nrules:\n  - MATCH,节点选择\n"
+	rules := DecodeUserRules("DOMAIN-SUFFIX,corp.example.com,DIRECT\nDOMAIN,a.com,PROXY\nDOMAIN,b.com,Missing")
+	out := InjectClashRules(config, rules)
+	direct := strings.Index(out, "DOMAIN-SUFFIX,corp.example.com,DIRECT")
+	proxy := strings.Index(out, "DOMAIN,a.com,节点选择")
+	match := strings.Index(out, "MATCH,节点选择")
+	if direct < 0 || proxy < 0 || !(direct < proxy && proxy < match) {
+		t.Fatalf("rules not prepended in order:\n%s", out)
+	}
+	if strings.Contains(out, "b.com") {
+		t.Fatalf("rule with unknown policy was injected:\n%s", out)
+	}
+}
+
+func TestInjectSurgeRules(t *testing.T) {
+	config := "[Proxy Group]\nProxy = select, DIRECT\n\n[Rule]\nFINAL,Proxy\n"
+	rules := DecodeUserRules("DST-PORT,22,PROXY\nGEOSITE,google,DIRECT\nPROCESS-PATH,/Applications/Foo.app/Contents/MacOS/Foo,DIRECT\nSRC-IP-CIDR,192.168.1.0/24,DIRECT")
+	out := InjectSurgeRules(config, rules)
+	want := "[Rule]\nDEST-PORT,22,Proxy\nSRC-IP,192.168.1.0/24,DIRECT\nFINAL,Proxy\n"
+	if !strings.HasSuffix(out, want) {
+		t.Fatalf("InjectSurgeRules =\n%s\nwant suffix\n%s", out, want)
+	}
+}
+
+func TestInjectSingBoxRules(t *testing.T) {
+	config := `{"outbounds":[{"type":"selector","tag":"Proxy"},{"type":"direct","tag":"direct"}],` +
+		`"route":{"rules":[{"action":"sniff"},{"rule_set":["geosite-cn"],"outbound":"direct"}]}}`
+	rules := DecodeUserRules("DOMAIN-SUFFIX,corp.example.com,DIRECT\nDST-PORT,1000-2000,PROXY\nREJECT-ME,x,DIRECT\nDOMAIN,ads.com,REJECT")
+	out := InjectSingBoxRules(config, rules)
+	sniff := strings.Index(out, `"sniff"`)
+	corp := strings.Index(out, `"corp.example.com"`)
+	port := strings.Index(out, `"1000:2000"`)
+	reject := strings.Index(out, `"reject"`)
+	geosite := strings.Index(out, `"geosite-cn"`)
+	if sniff < 0 || corp < 0 || port < 0 || reject < 0 || !(sniff < corp && corp < port && port < reject && reject < geosite) {
+		t.Fatalf("rules not inserted after sniff:\n%s", out)
+	}
+}
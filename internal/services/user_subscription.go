@@ -0,0 +1,136 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	"cboard/v2/internal/models"
+	"cboard/v2/internal/utils"
+
+	"gorm.io/gorm"
+)
+
+// ── 多订阅支持 ──
+// 一个用户可以拥有多个相互独立的订阅（独立链接、设备数与到期时间）。
+// 未指定 subscription_id 的旧接口/旧订单统一作用于用户的主订阅（最早创建的订阅）。
+
+// FindUserSubscription loads one of the user's subscriptions; subID 0 selects the primary (oldest) one.
+func FindUserSubscription(db *gorm.DB, userID, subID uint) (*models.Subscription, error) {
+	var sub models.Subscription
+	q := db.Where("user_id = ?", userID)
+	if subID > 0 {
+		q = q.Where("id = ?", subID)
+	}
+	if err := q.Order("id ASC").First(&sub).Error; err != nil {
+		return nil, err
+	}
+	return &sub, nil
+}
+
+// ListUserSubscriptions returns all subscriptions of a user, primary first.
+func ListUserSubscriptions(db *gorm.DB, userID uint) []models.Subscription {
+	var subs []models.Subscription
+	db.Where("user_id = ?", userID).Order("id ASC").Find(&subs)
+	return subs
+}
+
+// CheckSubscriptionQuota returns an error when the user may not create another subscription.
+func CheckSubscriptionQuota(db *gorm.DB, userID uint) error {
+	max := utils.GetIntSetting("max_subscriptions_per_user", 5)
+	if max <= 0 {
+		return nil
+	}
+	var count int64
+	db.Model(&models.Subscription{}).Where("user_id = ?", userID).Count(&count)
+	if count >= int64(max) {
+		return fmt.Errorf("每个账户最多可拥有 %d 个订阅", max)
+	}
+	return nil
+}
+
+// ResolveOrderSubscription finds the subscription an order renews or upgrades.
+// 返回 nil, nil 表示应新建订阅：订单标记了 new_subscription，或用户还没有任何订阅。
+// 订单指定的 subscription_id 不属于该用户时返回 gorm.ErrRecordNotFound。
+func ResolveOrderSubscription(db *gorm.DB, order *models.Order) (*models.Subscription, error) {
+	if order.NewSubscription {
+		return nil, nil
+	}
+	var subID uint
+	if order.SubscriptionID != nil {
+		subID = *order.SubscriptionID
+	}
+	sub, err := FindUserSubscription(db, order.UserID, subID)
+	if err == gorm.ErrRecordNotFound && subID == 0 {
+		return nil, nil
+	}
+	return sub, err
+}
+
+// BindOrderSubscription records which subscription an order was applied to.
+func BindOrderSubscription(db *gorm.DB, order *models.Order, subID uint) {
+	if order.SubscriptionID != nil && *order.SubscriptionID == subID {
+		return
+	}
+	if err := db.Model(&models.Order{}).Where("id = ?", order.ID).Update("subscription_id", subID).Error; err != nil {
+		utils.SysError("subscription", fmt.Sprintf("记录订单关联订阅失败: order=%s sub=%d err=%v", order.OrderNo, subID, err))
+		return
+	}
+	order.SubscriptionID = &subID
+}
+
+// UserNodeScope restricts a node query to the groups granted by any of the user's active subscriptions.
+func UserNodeScope(db *gorm.DB, userID uint) func(*gorm.DB) *gorm.DB {
+	var pkgIDs []int64
+	db.Model(&models.Subscription{}).
+		Where("user_id = ? AND status = ? AND is_active = ? AND expire_time > ? AND package_id IS NOT NULL", userID, "active", true, time.Now()).
+		Pluck("package_id", &pkgIDs)
+	var groupIDs []uint
+	if len(pkgIDs) > 0 {
+		db.Model(&models.PackageNodeGroup{}).Where("package_id IN ?", pkgIDs).Distinct().Pluck("node_group_id", &groupIDs)
+	}
+	return func(q *gorm.DB) *gorm.DB {
+		if len(groupIDs) == 0 {
+			return q.Where("group_id IS NULL")
+		}
+		return q.Where("group_id IS NULL OR group_id IN ?", groupIDs)
+	}
+}
+
+// EnableUserSubscriptions re-activates all subscriptions of the given users, marking past-due ones as expired.
+// 暂停中的订阅保持暂停，由用户自行恢复或到期自动恢复。
+func EnableUserSubscriptions(db *gorm.DB, userIDs []uint) error {
+	now := time.Now()
+	if err := db.Model(&models.Subscription{}).Where("user_id IN ? AND status <> ? AND expire_time > ?", userIDs, "paused", now).
+		Updates(map[string]interface{}{"is_active": true, "status": "active"}).Error; err != nil {
+		return err
+	}
+	return db.Model(&models.Subscription{}).Where("user_id IN ? AND status <> ? AND expire_time <= ?", userIDs, "paused", now).
+		Updates(map[string]interface{}{"is_active": true, "status": "expired"}).Error
+}
+
+// DisableUserSubscriptions disables every subscription of the given users. Paused subscriptions are
+// unfrozen first: the paused time is credited to their expiry and the open pause row is closed, so that
+// re-enabling later does not lose or double-count it.
+func DisableUserSubscriptions(db *gorm.DB, userIDs []uint) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var paused []models.Subscription
+		if err := tx.Where("user_id IN ? AND status = ? AND paused_at IS NOT NULL", userIDs, "paused").Find(&paused).Error; err != nil {
+			return err
+		}
+		now := time.Now()
+		for i := range paused {
+			sub := &paused[i]
+			duration := pausedDuration(sub, now)
+			if err := tx.Model(sub).Updates(map[string]interface{}{
+				"expire_time": sub.ExpireTime.Add(duration), "paused_at": nil, "pause_resume_at": nil,
+			}).Error; err != nil {
+				return err
+			}
+			if err := closeSubscriptionPause(tx, sub.ID, now, duration, "disable"); err != nil {
+				return err
+			}
+		}
+		return tx.Model(&models.Subscription{}).Where("user_id IN ?", userIDs).
+			Updates(map[string]interface{}{"is_active": false, "status": "disabled"}).Error
+	})
+}
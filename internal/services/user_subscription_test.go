@@ -0,0 +1,37 @@
+package services
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"cboard/v2/internal/models"
+)
+
+func TestActivateSubscriptionRechecksQuota(t *testing.T) {
+	db := setupPauseTestDB(t)
+	if err := db.AutoMigrate(&models.Package{}, &models.SystemConfig{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	pkg := models.Package{Name: "月付", DurationDays: 30, DeviceLimit: 3}
+	if err := db.Create(&pkg).Error; err != nil {
+		t.Fatalf("create package: %v", err)
+	}
+	// 默认上限 5 个：待支付期间已通过其他订单建满
+	for i := 0; i < 5; i++ {
+		sub := models.Subscription{UserID: 7, SubscriptionURL: fmt.Sprintf("quota-test-%d", i), DeviceLimit: 3,
+			Status: "active", ExpireTime: time.Now().Add(24 * time.Hour)}
+		if err := db.Create(&sub).Error; err != nil {
+			t.Fatalf("create subscription: %v", err)
+		}
+	}
+	order := models.Order{UserID: 7, OrderNo: "quota-test", PackageID: pkg.ID, NewSubscription: true}
+	if err := ActivateSubscription(db, &order, "alipay"); err == nil {
+		t.Fatalf("new subscription activated beyond the quota")
+	}
+	var count int64
+	db.Model(&models.Subscription{}).Where("user_id = ?", 7).Count(&count)
+	if count != 5 {
+		t.Fatalf("expected 5 subscriptions, got %d", count)
+	}
+}
@@ -0,0 +1,348 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"cboard/v2/internal/models"
+	"cboard/v2/internal/utils"
+)
+
+// ── Xray-core JSON 订阅 ──
+// 输出 JSON 数组，v2rayN / v2rayNG 会将每个元素导入为一个完整的自定义配置：
+// 第一个为包含全部节点的负载均衡配置（leastPing），其后每个节点各一个独立配置。
+// 支持 REALITY、XHTTP；开启 xray_fragment_enabled 后 TLS 节点经 fragment 出站发送分片的 TLS 握手。
+
+const (
+	xrayProxyTagPrefix = "proxy-"
+	xrayProbeURL       = "https://www.gstatic.com/generate_204"
+)
+
+// xrayNode is a converted node outbound with its display name.
+type xrayNode struct {
+	name     string
+	outbound map[string]interface{}
+}
+
+// GenerateXrayConfig generates an Xray-core JSON subscription.
+func GenerateXrayConfig(nodes []models.Node, subscriptionName string) string {
+	fragment := xrayFragmentOutbound()
+	var converted []xrayNode
+	used := make(map[string]bool)
+	for _, node := range nodes {
+		if node.Config == nil || *node.Config == "" {
+			continue
+		}
+		if isInfoNode(node) {
+			continue
+		}
+		m, err := NodeConfigToClashMap(node.Type, *node.Config, node.Name)
+		if err != nil {
+			continue
+		}
+		name := formatSafeNodeName(node.Name)
+		for base, i := name, 1; used[name]; i++ {
+			name = fmt.Sprintf("%s_%d", base, i)
+		}
+		ob := clashMapToXrayOutbound(m, fragment != nil)
+		if ob == nil {
+			continue
+		}
+		used[name] = true
+		converted = append(converted, xrayNode{name: name, outbound: ob})
+	}
+
+	configs := make([]interface{}, 0, len(converted)+1)
+	if len(converted) > 0 {
+		title := "⚡ 自动选择"
+		if subscriptionName != "" {
+			title = subscriptionName + " " + title
+		}
+		configs = append(configs, buildXrayConfig(title, converted, fragment))
+	}
+	for _, n := range converted {
+		configs = append(configs, buildXrayConfig(n.name, []xrayNode{n}, fragment))
+	}
+	b, err := json.MarshalIndent(configs, "", "  ")
+	if err != nil {
+		return "[]"
+	}
+	return string(b)
+}
+
+// buildXrayConfig assembles a full client config; more than one node gets a leastPing balancer.
+func buildXrayConfig(remarks string, nodes []xrayNode, fragment map[string]interface{}) map[string]interface{} {
+	outbounds := make([]interface{}, 0, len(nodes)+3)
+	for i, n := range nodes {
+		ob := make(map[string]interface{}, len(n.outbound)+1)
+		for k, v := range n.outbound {
+			ob[k] = v
+		}
+		ob["tag"] = fmt.Sprintf("%s%d", xrayProxyTagPrefix, i+1)
+		outbounds = append(outbounds, ob)
+	}
+	outbounds = append(outbounds,
+		map[string]interface{}{"tag": "direct", "protocol": "freedom"},
+		map[string]interface{}{"tag": "block", "protocol": "blackhole"},
+	)
+	if fragment != nil {
+		outbounds = append(outbounds, fragment)
+	}
+
+	finalRule := map[string]interface{}{"type": "field", "network": "tcp,udp", "outboundTag": xrayProxyTagPrefix + "1"}
+	routing := map[string]interface{}{
+		"domainStrategy": "IPIfNonMatch",
+	}
+	config := map[string]interface{}{
+		"remarks": remarks,
+		"log":     map[string]interface{}{"loglevel": "warning"},
+		"dns": map[string]interface{}{
+			"servers": []interface{}{
+				map[string]interface{}{"address": "223.5.5.5", "domains": []string{"geosite:cn"}, "expectIPs": []string{"geoip:cn"}},
+				"https://1.1.1.1/dns-query",
+				"8.8.8.8",
+			},
+		},
+		"inbounds": []interface{}{
+			map[string]interface{}{
+				"tag": "socks", "listen": "127.0.0.1", "port": 10808, "protocol": "socks",
+				"settings": map[string]interface{}{"udp": true, "auth": "noauth"},
+				"sniffing": map[string]interface{}{"enabled": true, "destOverride": []string{"http", "tls", "quic"}, "routeOnly": true},
+			},
+			map[string]interface{}{
+				"tag": "http", "listen": "127.0.0.1", "port": 10809, "protocol": "http",
+				"sniffing": map[string]interface{}{"enabled": true, "destOverride": []string{"http", "tls"}, "routeOnly": true},
+			},
+		},
+		"outbounds": outbounds,
+	}
+	if len(nodes) > 1 {
+		delete(finalRule, "outboundTag")
+		finalRule["balancerTag"] = "balancer"
+		routing["balancers"] = []interface{}{
+			map[string]interface{}{
+				"tag":         "balancer",
+				"selector":    []string{xrayProxyTagPrefix},
+				"strategy":    map[string]interface{}{"type": "leastPing"},
+				"fallbackTag": xrayProxyTagPrefix + "1",
+			},
+		}
+		config["observatory"] = map[string]interface{}{
+			"subjectSelector":   []string{xrayProxyTagPrefix},
+			"probeURL":          xrayProbeURL,
+			"probeInterval":     "5m",
+			"enableConcurrency": true,
+		}
+	}
+	routing["rules"] = []interface{}{
+		map[string]interface{}{"type": "field", "domain": []string{"geosite:category-ads-all"}, "outboundTag": "block"},
+		map[string]interface{}{"type": "field", "domain": []string{"geosite:private", "geosite:cn"}, "outboundTag": "direct"},
+		map[string]interface{}{"type": "field", "ip": []string{"geoip:private", "geoip:cn"}, "outboundTag": "direct"},
+		finalRule,
+	}
+	config["routing"] = routing
+	return config
+}
+
+// xrayFragmentOutbound returns the freedom outbound used to fragment TLS handshakes, or nil when disabled.
+func xrayFragmentOutbound() map[string]interface{} {
+	if !utils.IsBoolSetting("xray_fragment_enabled") {
+		return nil
+	}
+	setting := func(key, def string) string {
+		if v := strings.TrimSpace(utils.GetSetting(key)); v != "" {
+			return v
+		}
+		return def
+	}
+	return map[string]interface{}{
+		"tag":      "fragment",
+		"protocol": "freedom",
+		"settings": map[string]interface{}{
+			"fragment": map[string]interface{}{
+				"packets":  setting("xray_fragment_packets", "tlshello"),
+				"length":   setting("xray_fragment_length", "100-200"),
+				"interval": setting("xray_fragment_interval", "10-20"),
+			},
+		},
+		"streamSettings": map[string]interface{}{
+			"sockopt": map[string]interface{}{"tcpNoDelay": true},
+		},
+	}
+}
+
+// clashMapToXrayOutbound converts a Clash proxy map to an Xray outbound (without tag).
+func clashMapToXrayOutbound(m map[string]interface{}, fragment bool) map[string]interface{} {
+	typ, _ := m["type"].(string)
+	server, _ := m["server"].(string)
+	port := clashMapPortInt(m)
+	if server == "" || port == 0 {
+		return nil
+	}
+	ob := map[string]interface{}{}
+	security := ""
+	switch typ {
+	case "vmess":
+		cipher, _ := m["cipher"].(string)
+		if cipher == "" {
+			cipher = "auto"
+		}
+		uuid, _ := m["uuid"].(string)
+		ob["protocol"] = "vmess"
+		ob["settings"] = map[string]interface{}{
+			"vnext": []interface{}{map[string]interface{}{
+				"address": server, "port": port,
+				"users": []interface{}{map[string]interface{}{"id": uuid, "alterId": clashMapIntField(m, "alterId"), "security": cipher}},
+			}},
+		}
+		if tls, _ := m["tls"].(bool); tls {
+			security = "tls"
+		}
+	case "vless":
+		uuid, _ := m["uuid"].(string)
+		user := map[string]interface{}{"id": uuid, "encryption": "none"}
+		if enc, _ := m["encryption"].(string); enc != "" {
+			user["encryption"] = enc
+		}
+		if flow, _ := m["flow"].(string); flow != "" {
+			user["flow"] = flow
+		}
+		ob["protocol"] = "vless"
+		ob["settings"] = map[string]interface{}{
+			"vnext": []interface{}{map[string]interface{}{"address": server, "port": port, "users": []interface{}{user}}},
+		}
+		if _, ok := m["reality-opts"]; ok {
+			security = "reality"
+		} else if tls, _ := m["tls"].(bool); tls {
+			security = "tls"
+		}
+	case "trojan":
+		password, _ := m["password"].(string)
+		ob["protocol"] = "trojan"
+		ob["settings"] = map[string]interface{}{
+			"servers": []interface{}{map[string]interface{}{"address": server, "port": port, "password": password}},
+		}
+		security = "tls"
+	case "ss":
+		if _, ok := m["plugin"]; ok {
+			return nil // Xray 不支持 SIP003 插件
+		}
+		cipher, _ := m["cipher"].(string)
+		password, _ := m["password"].(string)
+		ob["protocol"] = "shadowsocks"
+		ob["settings"] = map[string]interface{}{
+			"servers": []interface{}{map[string]interface{}{"address": server, "port": port, "method": cipher, "password": password}},
+		}
+	case "hysteria2":
+		// Xray-core 25.10+ 的 hysteria 出站（version 2）；不支持 salamander 混淆，带 obfs 的节点无法连接，跳过
+		if obfs, _ := m["obfs"].(string); obfs != "" {
+			return nil
+		}
+		password, _ := m["password"].(string)
+		ob["protocol"] = "hysteria"
+		ob["settings"] = map[string]interface{}{"version": 2, "address": server, "port": port}
+		stream := map[string]interface{}{
+			"network":          "hysteria",
+			"hysteriaSettings": map[string]interface{}{"version": 2, "auth": password},
+			"security":         "tls",
+			"tlsSettings":      xrayTLSSettings(m, server),
+		}
+		ob["streamSettings"] = stream
+		return ob
+	default:
+		return nil
+	}
+
+	stream, ok := xrayStreamSettings(m)
+	if !ok {
+		return nil
+	}
+	switch security {
+	case "tls":
+		stream["security"] = "tls"
+		stream["tlsSettings"] = xrayTLSSettings(m, server)
+		if fragment {
+			stream["sockopt"] = map[string]interface{}{"dialerProxy": "fragment"}
+		}
+	case "reality":
+		opts, _ := m["reality-opts"].(map[string]interface{})
+		publicKey, _ := opts["public-key"].(string)
+		shortID, _ := opts["short-id"].(string)
+		fp, _ := m["client-fingerprint"].(string)
+		if fp == "" {
+			fp = "chrome"
+		}
+		stream["security"] = "reality"
+		stream["realitySettings"] = map[string]interface{}{
+			"serverName": xraySNI(m, server), "fingerprint": fp, "publicKey": publicKey, "shortId": shortID,
+		}
+	}
+	ob["streamSettings"] = stream
+	return ob
+}
+
+// xrayStreamSettings maps the Clash network options; ok is false for transports Xray cannot dial.
+func xrayStreamSettings(m map[string]interface{}) (map[string]interface{}, bool) {
+	network, _ := m["network"].(string)
+	switch network {
+	case "", "tcp", "raw":
+		return map[string]interface{}{"network": "raw"}, true
+	case "ws":
+		path, host := extractWSParams(m)
+		ws := map[string]interface{}{"path": path}
+		if host != "" {
+			ws["host"] = host
+		}
+		return map[string]interface{}{"network": "ws", "wsSettings": ws}, true
+	case "grpc":
+		opts, _ := m["grpc-opts"].(map[string]interface{})
+		serviceName, _ := opts["grpc-service-name"].(string)
+		return map[string]interface{}{"network": "grpc", "grpcSettings": map[string]interface{}{"serviceName": serviceName}}, true
+	case "httpupgrade":
+		opts, _ := m["httpupgrade-opts"].(map[string]interface{})
+		hu := map[string]interface{}{"path": "/"}
+		if p, _ := opts["path"].(string); p != "" {
+			hu["path"] = p
+		}
+		if h, _ := opts["host"].(string); h != "" {
+			hu["host"] = h
+		}
+		return map[string]interface{}{"network": "httpupgrade", "httpupgradeSettings": hu}, true
+	case "xhttp":
+		opts, _ := m["xhttp-opts"].(map[string]interface{})
+		xh := map[string]interface{}{"path": "/", "mode": "auto"}
+		if p, _ := opts["path"].(string); p != "" {
+			xh["path"] = p
+		}
+		if h, _ := opts["host"].(string); h != "" {
+			xh["host"] = h
+		}
+		if mode, _ := opts["mode"].(string); mode != "" {
+			xh["mode"] = mode
+		}
+		return map[string]interface{}{"network": "xhttp", "xhttpSettings": xh}, true
+	}
+	return nil, false
+}
+
+func xraySNI(m map[string]interface{}, server string) string {
+	if sni := loonGetSNI(m); sni != "" {
+		return sni
+	}
+	return server
+}
+
+func xrayTLSSettings(m map[string]interface{}, server string) map[string]interface{} {
+	tls := map[string]interface{}{"serverName": xraySNI(m, server)}
+	if skip, _ := m["skip-cert-verify"].(bool); skip {
+		tls["allowInsecure"] = true
+	}
+	if fp, _ := m["client-fingerprint"].(string); fp != "" {
+		tls["fingerprint"] = fp
+	}
+	if alpn := stringSliceFromValue(m["alpn"]); len(alpn) > 0 {
+		tls["alpn"] = alpn
+	}
+	return tls
+}
@@ -0,0 +1,113 @@
+package services
+
+import (
+	"testing"
+)
+
+func TestClashMapToXrayOutboundVLESSRealityXHTTP(t *testing.T) {
+	link := "vless://11111111-2222-3333-4444-555555555555@r.example.com:443?security=reality&sni=www.apple.com&pbk=PUBKEY&sid=ab12&fp=safari&type=xhttp&path=%2Fxh&mode=packet-up&flow=xtls-rprx-vision#R"
+	m, err := VlessLinkToClashMap(link, "R")
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	ob := clashMapToXrayOutbound(m, true)
+	if ob == nil || ob["protocol"] != "vless" {
+		t.Fatalf("unexpected outbound: %v", ob)
+	}
+	stream := ob["streamSettings"].(map[string]interface{})
+	if stream["network"] != "xhttp" || stream["security"] != "reality" {
+		t.Fatalf("unexpected stream settings: %v", stream)
+	}
+	xh := stream["xhttpSettings"].(map[string]interface{})
+	if xh["path"] != "/xh" || xh["mode"] != "packet-up" {
+		t.Fatalf("unexpected xhttp settings: %v", xh)
+	}
+	reality := stream["realitySettings"].(map[string]interface{})
+	if reality["publicKey"] != "PUBKEY" || reality["shortId"] != "ab12" || reality["serverName"] != "www.apple.com" || reality["fingerprint"] != "safari" {
+		t.Fatalf("unexpected reality settings: %v", reality)
+	}
+	if _, ok := stream["sockopt"]; ok {
+		t.Fatalf("REALITY outbounds must not be routed through fragment")
+	}
+	user := ob["settings"].(map[string]interface{})["vnext"].([]interface{})[0].(map[string]interface{})["users"].([]interface{})[0].(map[string]interface{})
+	if user["flow"] != "xtls-rprx-vision" {
+		t.Fatalf("flow not carried over: %v", user)
+	}
+}
+
+func TestClashMapToXrayOutboundTrojanWSFragment(t *testing.T) {
+	m, err := TrojanLinkToClashMap("trojan://pw@t.example.com:443?type=ws&path=%2Fws&host=cdn.example.com&sni=t.example.com#T", "T")
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	stream := clashMapToXrayOutbound(m, true)["streamSettings"].(map[string]interface{})
+	ws := stream["wsSettings"].(map[string]interface{})
+	if ws["path"] != "/ws" || ws["host"] != "cdn.example.com" {
+		t.Fatalf("unexpected ws settings: %v", ws)
+	}
+	if stream["sockopt"].(map[string]interface{})["dialerProxy"] != "fragment" {
+		t.Fatalf("TLS outbound should dial through fragment: %v", stream)
+	}
+}
+
+func TestClashMapToXrayOutboundSkipsObfsHysteria2(t *testing.T) {
+	plain, err := Hysteria2LinkToClashMap("hysteria2://secret@hy.example.com:8443?sni=hy.example.com#HY", "HY")
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	if ob := clashMapToXrayOutbound(plain, false); ob == nil || ob["protocol"] != "hysteria" {
+		t.Fatalf("unexpected outbound: %v", ob)
+	}
+	obfs, err := Hysteria2LinkToClashMap("hysteria2://secret@hy.example.com:8443?sni=hy.example.com&obfs=salamander&obfs-password=mask#HY", "HY")
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	if ob := clashMapToXrayOutbound(obfs, false); ob != nil {
+		t.Fatalf("obfuscated hysteria2 node should be skipped, got %v", ob)
+	}
+}
+
+func TestBuildXrayConfigBalancer(t *testing.T) {
+	nodes := []xrayNode{
+		{name: "a", outbound: map[string]interface{}{"protocol": "vless"}},
+		{name: "b", outbound: map[string]interface{}{"protocol": "trojan"}},
+	}
+	multi := buildXrayConfig("auto", nodes, nil)
+	routing := multi["routing"].(map[string]interface{})
+	if _, ok := routing["balancers"]; !ok || multi["observatory"] == nil {
+		t.Fatalf("multi-node config should have a balancer and observatory")
+	}
+	rules := routing["rules"].([]interface{})
+	if rules[len(rules)-1].(map[string]interface{})["balancerTag"] != "balancer" {
+		t.Fatalf("final rule should use the balancer: %v", rules)
+	}
+	single := buildXrayConfig("a", nodes[:1], nil)
+	if _, ok := single["routing"].(map[string]interface{})["balancers"]; ok {
+		t.Fatalf("single-node config should not have a balancer")
+	}
+	if nodes[0].outbound["tag"] != nil {
+		t.Fatalf("buildXrayConfig must not mutate the shared outbound")
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	cases := []struct {
+		a, b string
+		want int
+	}{
+		{"7.0", "7.0", 0},
+		{"7.10.2", "7.9", 1},
+		{"1.8.38", "1.9.0", -1},
+		{"1.9", "1.9.0", 0},
+	}
+	for _, c := range cases {
+		if got := compareVersions(c.a, c.b); got != c.want {
+			t.Fatalf("compareVersions(%q, %q) = %d, want %d", c.a, c.b, got, c.want)
+		}
+	}
+	info := &ClientInfo{}
+	detectSoftware("v2rayng/1.9.1", "v2rayNG/1.9.1", info)
+	if info.SoftwareName != "v2rayNG" || info.SoftwareVersion != "1.9.1" {
+		t.Fatalf("v2rayNG detected as %q %q", info.SoftwareName, info.SoftwareVersion)
+	}
+}